@@ -8,6 +8,7 @@ import (
 
 	"github.com/danigrb.dev/user-service/internal/database"
 	"github.com/danigrb.dev/user-service/internal/server"
+	"github.com/danigrb.dev/user-service/internal/services"
 )
 
 func main() {
@@ -19,6 +20,19 @@ func main() {
 	// Connect to database BEFORE initializing server
 	database.ConnectDatabase()
 
+	// Tokens can't be signed until the signing key encryption key is configured
+	if err := services.CheckSigningKeyEncryption(); err != nil {
+		log.Fatal("Invalid SIGNING_KEY_ENCRYPTION_KEY: ", err)
+	}
+
+	// Make sure the built-in roles exist before serving requests
+	if err := services.NewRBACService().SeedBuiltInRoles(); err != nil {
+		log.Fatal("Failed to seed roles: ", err)
+	}
+
+	// Erase deleted accounts whose grace period has ended
+	services.NewAccountPurger().Start()
+
 	// Initialize Gin router with all routes configured
 	server := server.CreateNewServer()
 
@@ -30,4 +44,7 @@ func main() {
 
 	log.Printf("Starting user-service on port %s", port)
 	server.Run()
+
+	// Write the audit events of the last requests before exiting
+	services.GetAuditService().Close()
 }
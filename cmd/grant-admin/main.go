@@ -0,0 +1,40 @@
+// Command grant-admin assigns the admin role to an existing account. It is meant to be run
+// once by an operator to create the first admin; later admins are assigned through the API.
+//
+//	go run ./cmd/grant-admin -user-id 42
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/services"
+)
+
+func main() {
+	userID := flag.Uint("user-id", 0, "ID of the user to make an admin")
+	flag.Parse()
+	if *userID == 0 {
+		log.Fatal("Usage: grant-admin -user-id <id>")
+	}
+
+	// Load environment variables from .env if present
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	database.ConnectDatabase()
+
+	rbac := services.NewRBACService()
+	if err := rbac.SeedBuiltInRoles(); err != nil {
+		log.Fatal("Failed to seed roles: ", err)
+	}
+	if err := rbac.BootstrapAdmin(*userID); err != nil {
+		log.Fatal("Failed to grant the admin role: ", err)
+	}
+
+	log.Printf("User %d is now an admin", *userID)
+}
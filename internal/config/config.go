@@ -0,0 +1,71 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the value of the environment variable key, or def when it is unset
+func String(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// Duration parses the environment variable key as a time.Duration (e.g. "15m"),
+// falling back to def when it is unset or invalid
+func Duration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s (%q), using default %s", key, value, def)
+		return def
+	}
+	return parsed
+}
+
+// List splits the comma separated environment variable key into trimmed, non-empty values
+func List(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Bool parses the environment variable key as a boolean, falling back to def when it is unset or invalid
+func Bool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s (%q), using default %t", key, value, def)
+		return def
+	}
+	return parsed
+}
+
+// Int parses the environment variable key as an integer, falling back to def when it is unset or invalid
+func Int(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s (%q), using default %d", key, value, def)
+		return def
+	}
+	return parsed
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AccountLinkController handles routes that attach and detach login methods
+type AccountLinkController struct {
+	accountLinks  *services.AccountLinkService
+	oidcService   *services.OIDCService
+	appleVerifier *services.AppleVerifier
+}
+
+// NewAccountLinkController creates a new AccountLinkController instance
+func NewAccountLinkController() *AccountLinkController {
+	return &AccountLinkController{
+		accountLinks:  services.NewAccountLinkService(),
+		oidcService:   services.NewOIDCService(),
+		appleVerifier: services.NewAppleVerifier(),
+	}
+}
+
+// LinkAppleRequest defines the request body for linking an Apple ID
+type LinkAppleRequest struct {
+	IdentityToken string `json:"identity_token" binding:"required"`
+	Nonce         string `json:"nonce"`
+}
+
+// GetLoginMethods handles GET /user/login-methods
+func (lc *AccountLinkController) GetLoginMethods(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	methods, err := lc.accountLinks.Methods(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, methods)
+}
+
+// LinkApple handles POST /user/identities/apple
+func (lc *AccountLinkController) LinkApple(ctx *gin.Context) {
+	claims, ok := requireRecentAuthentication(ctx)
+	if !ok {
+		return
+	}
+
+	var req LinkAppleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	identity, err := lc.appleVerifier.Verify(req.IdentityToken, req.Nonce)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Apple identity token"})
+		return
+	}
+
+	linked, err := lc.accountLinks.LinkIdentity(claims.UserID, &services.FederatedIdentity{
+		Provider:      "apple",
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Claims: map[string]any{
+			"sub":              identity.Subject,
+			"email":            identity.Email,
+			"email_verified":   identity.EmailVerified,
+			"is_private_email": identity.IsPrivateEmail,
+		},
+	})
+	if err != nil {
+		respondLinkError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionIdentityLinked, linked.UserID, models.AuditMetadata{
+		"identity_id": linked.ID,
+		"provider":    linked.Provider,
+	})
+	ctx.JSON(http.StatusCreated, linked)
+}
+
+// BeginOIDCLink handles POST /user/identities/oidc/:provider/authorize
+func (lc *AccountLinkController) BeginOIDCLink(ctx *gin.Context) {
+	claims, ok := requireRecentAuthentication(ctx)
+	if !ok {
+		return
+	}
+
+	var req OIDCAuthorizeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authorization, err := lc.oidcService.BeginLink(claims.UserID, ctx.Param("provider"), req.RedirectURI)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownOIDCProvider) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, authorization)
+}
+
+// CompleteOIDCLink handles POST /user/identities/oidc/:provider/callback
+func (lc *AccountLinkController) CompleteOIDCLink(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req OIDCCallbackRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	identity, err := lc.oidcService.CompleteLink(ctx.Request.Context(), userID, ctx.Param("provider"), req.Code, req.State)
+	if err != nil {
+		respondIdentityError(ctx, err)
+		return
+	}
+
+	linked, err := lc.accountLinks.LinkIdentity(userID, identity)
+	if err != nil {
+		respondLinkError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionIdentityLinked, linked.UserID, models.AuditMetadata{
+		"identity_id": linked.ID,
+		"provider":    linked.Provider,
+	})
+	ctx.JSON(http.StatusCreated, linked)
+}
+
+// UnlinkIdentity handles DELETE /user/identities/:id
+func (lc *AccountLinkController) UnlinkIdentity(ctx *gin.Context) {
+	claims, ok := requireRecentAuthentication(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid identity id"})
+		return
+	}
+
+	if err := lc.accountLinks.UnlinkIdentity(claims.UserID, uint(id)); err != nil {
+		respondLinkError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionIdentityUnlinked, claims.UserID, models.AuditMetadata{"identity_id": id})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Identity unlinked"})
+}
+
+// RemovePassword handles DELETE /user/password
+// A password can be added back with PUT /user/password after a fresh login.
+func (lc *AccountLinkController) RemovePassword(ctx *gin.Context) {
+	claims, ok := requireRecentAuthentication(ctx)
+	if !ok {
+		return
+	}
+
+	if err := lc.accountLinks.RemovePassword(claims.UserID); err != nil {
+		respondLinkError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionPasswordRemoved, claims.UserID, nil)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password removed"})
+}
+
+// recentlyAuthenticated reports whether the token's user actively logged in within REAUTHENTICATION_MAX_AGE
+func recentlyAuthenticated(claims *services.Claims) bool {
+	return claims.AuthenticatedWithin(config.Duration("REAUTHENTICATION_MAX_AGE", 5*time.Minute))
+}
+
+// requireRecentAuthentication returns the request's claims, or responds with 403 and
+// false when the user has to log in again before changing how they sign in
+func requireRecentAuthentication(ctx *gin.Context) (*services.Claims, bool) {
+	claims, ok := middleware.ExtractClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return nil, false
+	}
+	if !recentlyAuthenticated(claims) {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error": services.ErrReauthenticationRequired.Error(),
+			"code":  "reauthentication_required",
+		})
+		return nil, false
+	}
+	return claims, true
+}
+
+// respondLinkError maps account linking errors to HTTP responses
+func respondLinkError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrLastLoginMethod):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "last_login_method"})
+	case errors.Is(err, services.ErrIdentityAlreadyLinked):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "identity_already_linked"})
+	case errors.Is(err, services.ErrIdentityNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoPassword):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update login methods"})
+	}
+}
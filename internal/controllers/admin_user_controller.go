@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminUserController handles the user management routes used by support staff
+type AdminUserController struct {
+	adminUsers *services.AdminUserService
+}
+
+// NewAdminUserController creates a new AdminUserController instance
+func NewAdminUserController() *AdminUserController {
+	return &AdminUserController{
+		adminUsers: services.NewAdminUserService(),
+	}
+}
+
+// ListUsersQuery defines the query parameters of the user listing
+type ListUsersQuery struct {
+	Query          string     `form:"q"`
+	EmailPrefix    string     `form:"email_prefix"`
+	UsernamePrefix string     `form:"username_prefix"`
+	CreatedFrom    *time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo      *time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Status         string     `form:"status" binding:"omitempty,oneof=pending active suspended banned deleted"`
+	Role           string     `form:"role"`
+	EmailVerified  *bool      `form:"email_verified"`
+	Sort           string     `form:"sort" binding:"omitempty,oneof=created_at id email username"`
+	Order          string     `form:"order" binding:"omitempty,oneof=asc desc"`
+	Cursor         string     `form:"cursor"`
+	Limit          int        `form:"limit" binding:"omitempty,min=1"`
+}
+
+// SuspendUserRequest defines the request body for suspending a user
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// SetUserStatusRequest defines the request body for changing the status of a user
+type SetUserStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=active suspended banned deleted"`
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// ListUsers handles GET /admin/users
+// Results are paginated with the opaque next_cursor of the previous page, which must be
+// sent together with the same sort and order.
+func (ac *AdminUserController) ListUsers(ctx *gin.Context) {
+	var query ListUsersQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := interfaces.UserFilter{
+		Query:          query.Query,
+		EmailPrefix:    query.EmailPrefix,
+		UsernamePrefix: query.UsernamePrefix,
+		CreatedFrom:    query.CreatedFrom,
+		CreatedTo:      query.CreatedTo,
+		Status:         models.UserStatus(query.Status),
+		Role:           query.Role,
+		EmailVerified:  query.EmailVerified,
+	}
+	sort := interfaces.UserSort{Field: query.Sort, Descending: query.Order == "desc"}
+
+	page, err := ac.adminUsers.ListUsers(ctx.Request.Context(), filter, sort, query.Cursor, query.Limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// GetUser handles GET /admin/users/:id
+func (ac *AdminUserController) GetUser(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	user, err := ac.adminUsers.GetUser(userID)
+	if err != nil {
+		respondAdminUserError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// UpdateUser handles PATCH /admin/users/:id
+func (ac *AdminUserController) UpdateUser(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	var req services.AdminUserUpdate
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := ac.auditSnapshot(userID)
+	user, err := ac.adminUsers.UpdateUser(userID, &req)
+	if err != nil {
+		respondAdminUserError(ctx, err)
+		return
+	}
+
+	ac.recordUserChanges(ctx, services.AuditActionAdminUserUpdated, before, user, nil)
+	ctx.JSON(http.StatusOK, user)
+}
+
+// SetStatus handles PUT /admin/users/:id/status
+func (ac *AdminUserController) SetStatus(ctx *gin.Context) {
+	var req SetUserStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ac.setStatus(ctx, models.UserStatus(req.Status), req.Reason)
+}
+
+// SuspendUser handles POST /admin/users/:id/suspend
+func (ac *AdminUserController) SuspendUser(ctx *gin.Context) {
+	// The body is optional
+	var req SuspendUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ac.setStatus(ctx, models.UserStatusSuspended, req.Reason)
+}
+
+// UnsuspendUser handles POST /admin/users/:id/unsuspend
+func (ac *AdminUserController) UnsuspendUser(ctx *gin.Context) {
+	ac.setStatus(ctx, models.UserStatusActive, "")
+}
+
+// GetStatusHistory handles GET /admin/users/:id/status-history
+func (ac *AdminUserController) GetStatusHistory(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	transitions, err := ac.adminUsers.StatusHistory(userID)
+	if err != nil {
+		respondAdminUserError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"transitions": transitions})
+}
+
+// setStatus changes the status of the user in the path on behalf of the caller
+func (ac *AdminUserController) setStatus(ctx *gin.Context, status models.UserStatus, reason string) {
+	actorID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	before := ac.auditSnapshot(userID)
+	user, err := ac.adminUsers.SetStatus(actorID, userID, status, reason)
+	if err != nil {
+		respondAdminUserError(ctx, err)
+		return
+	}
+
+	ac.recordUserChanges(ctx, services.AuditActionAdminStatusChanged, before, user, models.AuditMetadata{"reason": reason})
+	ctx.JSON(http.StatusOK, user)
+}
+
+// ForcePasswordReset handles POST /admin/users/:id/password-reset
+func (ac *AdminUserController) ForcePasswordReset(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := ac.adminUsers.ForcePasswordReset(userID); err != nil {
+		respondAdminUserError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionAdminPasswordForced, userID, nil)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password invalidated and reset email sent"})
+}
+
+// UnlockUser handles POST /admin/users/:id/unlock
+func (ac *AdminUserController) UnlockUser(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := ac.adminUsers.UnlockUser(userID); err != nil {
+		respondAdminUserError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionAdminUserUnlocked, userID, nil)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
+
+// DeleteUser handles DELETE /admin/users/:id
+// The account is purged once the deletion grace period ends.
+func (ac *AdminUserController) DeleteUser(ctx *gin.Context) {
+	actorID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	before := ac.auditSnapshot(userID)
+	user, err := ac.adminUsers.DeleteUser(actorID, userID)
+	if err != nil {
+		respondAdminUserError(ctx, err)
+		return
+	}
+
+	ac.recordUserChanges(ctx, services.AuditActionAdminUserDeleted, before, user, models.AuditMetadata{"purge_at": user.PurgeAt})
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "User scheduled for deletion",
+		"purge_at": user.PurgeAt,
+	})
+}
+
+// RestoreUser handles POST /admin/users/:id/restore
+func (ac *AdminUserController) RestoreUser(ctx *gin.Context) {
+	actorID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	before := ac.auditSnapshot(userID)
+	user, err := ac.adminUsers.RestoreUser(actorID, userID)
+	if err != nil {
+		respondAdminUserError(ctx, err)
+		return
+	}
+
+	ac.recordUserChanges(ctx, services.AuditActionAdminUserRestored, before, user, nil)
+	ctx.JSON(http.StatusOK, user)
+}
+
+// auditSnapshot returns a copy of the user to diff against after a change, or nil if it can't be loaded
+func (ac *AdminUserController) auditSnapshot(userID uint) *models.User {
+	details, err := ac.adminUsers.GetUser(userID)
+	if err != nil {
+		return nil
+	}
+	return services.CloneUser(details.User)
+}
+
+// recordUserChanges records an admin operation on a user with the fields it changed
+func (ac *AdminUserController) recordUserChanges(ctx *gin.Context, action string, before, after *models.User, metadata models.AuditMetadata) {
+	var changes models.AuditChanges
+	if before != nil {
+		changes = services.UserChanges(before, after)
+	}
+	recordAuditChanges(ctx, action, after.ID, changes, metadata)
+}
+
+// respondAdminUserError maps user management errors to HTTP responses
+func respondAdminUserError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCannotModifySelf):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidStatusTransition):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "invalid_status_transition"})
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
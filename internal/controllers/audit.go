@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit queues an audit event for the request. The actor is the authenticated caller;
+// for unauthenticated requests about a known user (logins), it is that user.
+// targetUserID is 0 when the request isn't about a known user.
+func recordAudit(ctx *gin.Context, action string, targetUserID uint, metadata models.AuditMetadata) {
+	recordAuditChanges(ctx, action, targetUserID, nil, metadata)
+}
+
+// recordAuditChanges is recordAudit for operations that changed fields of the target
+func recordAuditChanges(ctx *gin.Context, action string, targetUserID uint, changes models.AuditChanges, metadata models.AuditMetadata) {
+	event := models.AuditEvent{
+		Action:    action,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		RequestID: middleware.ExtractRequestID(ctx),
+		Changes:   changes,
+		Metadata:  metadata,
+	}
+	if targetUserID != 0 {
+		event.TargetUserID = &targetUserID
+	}
+	if actorID, ok := middleware.ExtractUserID(ctx); ok {
+		event.ActorID = &actorID
+	} else {
+		event.ActorID = event.TargetUserID
+	}
+
+	services.GetAuditService().Record(event)
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditController handles the admin routes for reading the audit log
+type AuditController struct {
+	auditService *services.AuditService
+}
+
+// NewAuditController creates a new AuditController instance
+func NewAuditController() *AuditController {
+	return &AuditController{
+		auditService: services.GetAuditService(),
+	}
+}
+
+// AuditEventsQuery defines the filters of the audit log listing and export
+type AuditEventsQuery struct {
+	Action       string     `form:"action"` // Comma separated
+	ActorID      *uint      `form:"actor_id"`
+	TargetUserID *uint      `form:"target_user_id"`
+	UserID       *uint      `form:"user_id"` // Actor or target
+	IP           string     `form:"ip"`
+	RequestID    string     `form:"request_id"`
+	From         *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To           *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// ListAuditEventsQuery defines the query parameters of the audit log listing
+type ListAuditEventsQuery struct {
+	AuditEventsQuery
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ExportAuditEventsQuery defines the query parameters of the audit log export
+type ExportAuditEventsQuery struct {
+	AuditEventsQuery
+	Format string `form:"format" binding:"omitempty,oneof=jsonl csv"`
+}
+
+// Filter converts the query to a repository filter
+func (q *AuditEventsQuery) Filter() interfaces.AuditEventFilter {
+	filter := interfaces.AuditEventFilter{
+		ActorID:      q.ActorID,
+		TargetUserID: q.TargetUserID,
+		UserID:       q.UserID,
+		IP:           q.IP,
+		RequestID:    q.RequestID,
+		From:         q.From,
+		To:           q.To,
+	}
+	for _, action := range strings.Split(q.Action, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			filter.Actions = append(filter.Actions, action)
+		}
+	}
+	return filter
+}
+
+// ListEvents handles GET /admin/audit-events
+// Events are returned newest first and paginated with the next_cursor of the previous page.
+func (ac *AuditController) ListEvents(ctx *gin.Context) {
+	var query ListAuditEventsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := ac.auditService.List(ctx.Request.Context(), query.Filter(), query.Cursor, query.Limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAuditCursor) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// ExportEvents handles GET /admin/audit-events/export
+// It streams every matching event as JSON lines (default) or CSV.
+func (ac *AuditController) ExportEvents(ctx *gin.Context) {
+	var query ExportAuditEventsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	write := ac.jsonLinesWriter(ctx)
+	contentType, extension := "application/x-ndjson", "jsonl"
+	if query.Format == "csv" {
+		write = ac.csvWriter(ctx)
+		contentType, extension = "text/csv", "csv"
+	}
+
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-events-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), extension))
+	ctx.Status(http.StatusOK)
+
+	// The status is already sent, so a failure can only cut the export short
+	if err := ac.auditService.Export(ctx.Request.Context(), query.Filter(), write); err != nil {
+		log.Printf("Failed to export audit events: %v", err)
+	}
+}
+
+// jsonLinesWriter writes each event as a JSON document on its own line
+func (ac *AuditController) jsonLinesWriter(ctx *gin.Context) func([]models.AuditEvent) error {
+	encoder := json.NewEncoder(ctx.Writer)
+	return func(events []models.AuditEvent) error {
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+		ctx.Writer.Flush()
+		return nil
+	}
+}
+
+// csvWriter writes events as CSV rows after a header, with changes and metadata as JSON
+func (ac *AuditController) csvWriter(ctx *gin.Context) func([]models.AuditEvent) error {
+	writer := csv.NewWriter(ctx.Writer)
+	headerWritten := false
+	return func(events []models.AuditEvent) error {
+		if !headerWritten {
+			headerWritten = true
+			if err := writer.Write([]string{"id", "created_at", "action", "actor_id", "target_user_id", "ip", "user_agent", "request_id", "changes", "metadata"}); err != nil {
+				return err
+			}
+		}
+		for _, event := range events {
+			changes, err := json.Marshal(event.Changes)
+			if err != nil {
+				return err
+			}
+			metadata, err := json.Marshal(event.Metadata)
+			if err != nil {
+				return err
+			}
+			err = writer.Write([]string{
+				strconv.FormatUint(uint64(event.ID), 10),
+				event.CreatedAt.UTC().Format(time.RFC3339Nano),
+				event.Action,
+				optionalID(event.ActorID),
+				optionalID(event.TargetUserID),
+				event.IP,
+				event.UserAgent,
+				event.RequestID,
+				string(changes),
+				string(metadata),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		ctx.Writer.Flush()
+		return writer.Error()
+	}
+}
+
+// optionalID formats an optional user ID for CSV, empty when nil
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
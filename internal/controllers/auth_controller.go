@@ -1,26 +1,52 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"io"
+	"log"
+	"math"
 	"net/http"
-	"os"
-	"time"
+	"strconv"
 
 	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
 	"github.com/danigrb.dev/user-service/internal/services"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // AuthController handles authentication-related routes
 type AuthController struct {
-	userService *services.UserService
+	userService         *services.UserService
+	authService         *services.AuthService
+	refreshTokenService *services.RefreshTokenService
+	revocationService   *services.RevocationService
+	sessionService      *services.SessionService
+	loginThrottle       *services.LoginThrottle
+	appleVerifier       *services.AppleVerifier
+	emailVerification   *services.EmailVerificationService
+	mfaService          *services.MFAService
+	webAuthnService     *services.WebAuthnService
+	magicLinkService    *services.MagicLinkService
+	identityService     *services.IdentityService
+	oidcService         *services.OIDCService
 }
 
 // NewAuthController creates a new AuthController instance
 func NewAuthController() *AuthController {
 	return &AuthController{
-		userService: services.NewUserService(),
+		userService:         services.NewUserService(),
+		authService:         services.NewAuthService(),
+		refreshTokenService: services.NewRefreshTokenService(),
+		revocationService:   services.GetRevocationService(),
+		sessionService:      services.NewSessionService(),
+		loginThrottle:       services.GetLoginThrottle(),
+		appleVerifier:       services.NewAppleVerifier(),
+		emailVerification:   services.NewEmailVerificationService(),
+		mfaService:          services.NewMFAService(),
+		webAuthnService:     services.NewWebAuthnService(),
+		magicLinkService:    services.NewMagicLinkService(),
+		identityService:     services.NewIdentityService(),
+		oidcService:         services.NewOIDCService(),
 	}
 }
 
@@ -37,6 +63,46 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// VerifyMFARequest defines the request body for completing a login with a second factor.
+// Either a TOTP code or a recovery code must be provided.
+type VerifyMFARequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MagicLinkRequest defines the request body for requesting a login link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ConsumeMagicLinkRequest defines the request body for logging in with a link.
+// The nonce is the value returned when the link was requested.
+type ConsumeMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+	Nonce string `json:"nonce" binding:"required"`
+}
+
+// RefreshTokenRequest defines the request body for token refresh
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RestoreAccountRequest defines the request body for restoring an account pending deletion
+type RestoreAccountRequest struct {
+	RestoreToken string `json:"restore_token" binding:"required"`
+}
+
+// UnlockAccountRequest defines the request body for unlocking an account with an emailed link
+type UnlockAccountRequest struct {
+	UnlockToken string `json:"unlock_token" binding:"required"`
+}
+
+// LogoutRequest defines the optional request body for logout
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 // Register handles user registration
 func (ac *AuthController) Register(ctx *gin.Context) {
 	var req RegisterRequest
@@ -50,28 +116,32 @@ func (ac *AuthController) Register(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// Generate JWT token
-	secret := []byte(os.Getenv("JWT_SECRET"))
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"email":    user.Email,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
-		"iat":      time.Now().Unix(),                     // Issued at
-	}).SignedString(secret)
 
+	ac.emailVerification.SendVerificationEmailAsync(user)
+	recordAudit(ctx, services.AuditActionRegister, user.ID, models.AuditMetadata{"method": "password"})
+
+	// Unverified accounts cannot log in under the "login" policy, so don't hand out tokens yet
+	if services.CurrentEmailVerificationPolicy() == services.EmailVerificationPolicyLogin {
+		ctx.JSON(http.StatusCreated, gin.H{
+			"user":    user,
+			"message": "Check your inbox to verify your email address before logging in",
+		})
+		return
+	}
+
+	// Generate access and refresh tokens
+	tokens, err := ac.authService.IssueTokens(user, sessionInfo(ctx, "password"))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
-		"user":  user,
-		"token": token,
-	})
+	ctx.JSON(http.StatusCreated, tokenResponse(tokens))
 }
 
 // Login handles user login
+// Attempts are throttled per account, address and subnet; refused attempts get a 429
+// with Retry-After, without the password being checked.
 func (ac *AuthController) Login(ctx *gin.Context) {
 	var req LoginRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -79,126 +149,478 @@ func (ac *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
+	// The attempt counts as a failure until the password turns out to be right
+	locked, err := ac.loginThrottle.Reserve(req.Email, ctx.ClientIP())
+	if err != nil {
+		if respondLoginThrottled(ctx, err) {
+			recordAudit(ctx, services.AuditActionLoginFailed, 0, models.AuditMetadata{
+				"method": "password",
+				"email":  req.Email,
+				"reason": "throttled",
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login attempts"})
+		return
+	}
+
 	user, err := ac.userService.VerifyUserCredentials(req.Email, req.Password)
 	if err != nil {
+		reason := services.AccountStatusCode(err)
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			if err := ac.loginThrottle.RecordFailure(ctx.ClientIP()); err != nil {
+				log.Printf("Failed to record login failure: %v", err)
+			}
+			if locked {
+				ac.loginThrottle.NotifyAccountLocked(req.Email)
+				recordAudit(ctx, services.AuditActionAccountLocked, 0, models.AuditMetadata{"email": req.Email})
+			}
+		case reason != "":
+			// The password was right, the account just can't sign in
+			if err := ac.loginThrottle.RecordSuccess(req.Email); err != nil {
+				log.Printf("Failed to reset login failures: %v", err)
+			}
+		}
+		if reason == "" {
+			reason = "invalid_credentials"
+		}
+		recordAudit(ctx, services.AuditActionLoginFailed, 0, models.AuditMetadata{
+			"method": "password",
+			"email":  req.Email,
+			"reason": reason,
+		})
+		if respondAccountStatusError(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate JWT token
-	secret := []byte(os.Getenv("JWT_SECRET"))
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"email":    user.Email,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
-		"iat":      time.Now().Unix(),                     // Issued at
-	}).SignedString(secret)
+	if err := ac.loginThrottle.RecordSuccess(req.Email); err != nil {
+		log.Printf("Failed to reset login failures: %v", err)
+	}
+	ac.completeLogin(ctx, user, "password")
+}
 
+// UnlockAccount handles POST /auth/unlock
+// The token comes from the email sent when the account was locked after failed logins.
+func (ac *AuthController) UnlockAccount(ctx *gin.Context) {
+	var req UnlockAccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := ac.loginThrottle.UnlockWithToken(req.UnlockToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUnlockToken) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionAccountUnlocked, user.ID, nil)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Account unlocked, you can sign in again"})
+}
+
+// completeLogin responds to a successful first-factor login: users with a TOTP enrollment
+// or a registered passkey get a challenge, everyone else gets tokens.
+// method names the first factor in the audit log.
+func (ac *AuthController) completeLogin(ctx *gin.Context, user *models.User, method string) {
+	if rejectInactiveLogin(ctx, user) || rejectUnverifiedLogin(ctx, user) {
+		recordAudit(ctx, services.AuditActionLoginFailed, user.ID, models.AuditMetadata{
+			"method": method,
+			"reason": "login_not_allowed",
+		})
+		return
+	}
+
+	// Users with a second factor (TOTP or a passkey) get a challenge instead of tokens
+	methods, err := ac.secondFactorMethods(user.ID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(methods) > 0 {
+		challenge, err := ac.mfaService.IssueChallenge(user)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		recordAudit(ctx, services.AuditActionLogin, user.ID, models.AuditMetadata{"method": method, "mfa_required": true})
+		ctx.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+			"methods":      methods,
+		})
+		return
+	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": token,
-	})
+	// Generate access and refresh tokens
+	tokens, err := ac.authService.IssueTokens(user, sessionInfo(ctx, method))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionLogin, user.ID, models.AuditMetadata{"method": method})
+	ctx.JSON(http.StatusOK, tokenResponse(tokens))
 }
 
-// RefreshToken handles JWT token refresh
-func (ac *AuthController) RefreshToken(ctx *gin.Context) {
-	// Apply the JWTAuth middleware directly to ensure a valid token
-	middleware.JWTAuth()(ctx)
+// secondFactorMethods lists the second factors the user can complete an MFA challenge with;
+// none means the login needs no second factor
+func (ac *AuthController) secondFactorMethods(userID uint) ([]string, error) {
+	var methods []string
+	totpEnabled, err := ac.mfaService.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if totpEnabled {
+		methods = append(methods, "totp", "recovery_code")
+	}
+	hasPasskeys, err := ac.webAuthnService.HasCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	if hasPasskeys {
+		methods = append(methods, "webauthn")
+	}
+	return methods, nil
+}
 
-	// If the middleware aborted the request, return early
-	if ctx.IsAborted() {
+// VerifyMFA exchanges the challenge token from Login and a TOTP or recovery code for real tokens
+func (ac *AuthController) VerifyMFA(ctx *gin.Context) {
+	var req VerifyMFARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Extract user ID using the utility function
-	userID, ok := middleware.ExtractUserID(ctx)
-	if !ok {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to extract user ID"})
+	user, err := ac.mfaService.CompleteChallenge(req.MFAToken, req.Code, req.RecoveryCode)
+	if err != nil {
+		recordMFAFailure(ctx, ac.mfaService, req.MFAToken, err)
+		if respondMFAThrottled(ctx, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidMFAChallenge):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrMFANotEnabled):
+			// Users with only passkeys complete the challenge through /auth/webauthn/mfa
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Authentication codes are not enabled for this account", "code": "mfa_method_unavailable"})
+		case errors.Is(err, services.ErrInvalidMFACode):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify authentication code"})
+		}
 		return
 	}
 
-	// Extract other user information from context set by middleware
-	email, _ := ctx.Get("email")
-	username, _ := ctx.Get("username")
+	// The account may have been suspended while the challenge was pending
+	if rejectInactiveLogin(ctx, user) {
+		return
+	}
 
-	// Generate new token with refreshed expiry time
-	secret := []byte(os.Getenv("JWT_SECRET"))
-	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  userID,
-		"email":    email,
-		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
-		"iat":      time.Now().Unix(),
-	})
+	method := "totp"
+	if req.RecoveryCode != "" {
+		method = "recovery_code"
+	}
 
-	signedToken, err := newToken.SignedString(secret)
+	// Generate access and refresh tokens
+	tokens, err := ac.authService.IssueTokens(user, sessionInfo(ctx, method))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"token": signedToken,
+	recordAudit(ctx, services.AuditActionMFAVerified, user.ID, models.AuditMetadata{"method": method})
+	ctx.JSON(http.StatusOK, tokenResponse(tokens))
+}
+
+// RequestMagicLink handles POST /auth/magic-link
+// The response carries a nonce the client must keep: the emailed link only works together
+// with it, which binds the link to the device that requested it. Like ForgotPassword,
+// the email is sent in the background and the response never reveals whether an account exists.
+func (ac *AuthController) RequestMagicLink(ctx *gin.Context) {
+	var req MagicLinkRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	nonce, err := ac.magicLinkService.BeginRequest(req.Email)
+	if err != nil {
+		if errors.Is(err, services.ErrMagicLinkRateLimited) {
+			ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request login link"})
+		return
+	}
+
+	go func(email string) {
+		if err := ac.magicLinkService.SendLink(email, nonce); err != nil {
+			log.Printf("Failed to send login link: %v", err)
+		}
+	}(req.Email)
+
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message": "If this email can sign in, a login link has been sent",
+		"nonce":   nonce,
 	})
 }
 
-// extractBearerToken extracts the JWT token from the Authorization header
-func extractBearerToken(ctx *gin.Context) (string, error) {
-	authHeader := ctx.GetHeader("Authorization")
-	if authHeader == "" {
-		return "", fmt.Errorf("Missing token")
+// ConsumeMagicLink handles POST /auth/magic-link/consume
+// It responds like Login, including the MFA challenge for users with two-factor authentication.
+func (ac *AuthController) ConsumeMagicLink(ctx *gin.Context) {
+	var req ConsumeMagicLinkRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := ac.magicLinkService.Consume(req.Token, req.Nonce)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMagicLink) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in with link"})
+		return
+	}
+
+	ac.completeLogin(ctx, user, "magic_link")
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a rotated refresh token.
+// Replaying a refresh token that was already rotated revokes every token in its family.
+func (ac *AuthController) RefreshToken(ctx *gin.Context) {
+	var req RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := ac.authService.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			recordAudit(ctx, services.AuditActionRefreshTokenReused, 0, nil)
+		}
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if respondAccountStatusError(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	response := tokenResponse(tokens)
+	delete(response, "user")
+	ctx.JSON(http.StatusOK, response)
+}
+
+// RestoreAccount handles POST /auth/restore
+// Logging in to an account pending deletion responds with a restore token; exchanging it
+// cancels the deletion and continues the login like Login does.
+func (ac *AuthController) RestoreAccount(ctx *gin.Context) {
+	var req RestoreAccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		return authHeader[7:], nil
+
+	user, err := services.GetUserStatusService().RestoreWithToken(req.RestoreToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRestoreToken) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore account"})
+		return
 	}
-	return "", fmt.Errorf("Invalid token format")
+
+	recordAudit(ctx, services.AuditActionAccountRestored, user.ID, nil)
+	ac.completeLogin(ctx, user, "restore_token")
 }
 
-// parseJWTClaims parses and validates the JWT token and returns its claims
-func parseJWTClaims(tokenString string) (jwt.MapClaims, error) {
-	parsedToken, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrTokenMalformed
+// Logout ends the session of the access token used for the request and revokes the token.
+// Tokens from before sessions were tracked don't name their session, so their clients
+// should send the refresh token to revoke its family.
+func (ac *AuthController) Logout(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// The body is optional; clients that hold a refresh token should send it
+	var req LogoutRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RefreshToken != "" {
+		if err := ac.refreshTokenService.RevokeToken(req.RefreshToken, userID); err != nil {
+			if errors.Is(err, services.ErrInvalidRefreshToken) {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke refresh token"})
+			return
 		}
-		secret := []byte(os.Getenv("JWT_SECRET"))
-		return secret, nil
-	})
+	}
 
-	if err != nil || !parsedToken.Valid {
-		return nil, fmt.Errorf("Invalid or expired token")
+	if claims, ok := middleware.ExtractClaims(ctx); ok && claims.SessionID != 0 {
+		if err := ac.sessionService.Revoke(userID, claims.SessionID); err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
 	}
 
-	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	jti := ctx.GetString("jti")
+	expiresAt := ctx.GetTime("token_expires_at")
+	if jti != "" {
+		if err := ac.revocationService.RevokeToken(jti, userID, expiresAt); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+			return
+		}
+	}
+
+	recordAudit(ctx, services.AuditActionLogout, userID, nil)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll signs the user out of every session by revoking all of their
+// refresh tokens and every access token issued so far
+func (ac *AuthController) LogoutAll(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
 	if !ok {
-		return nil, fmt.Errorf("Invalid token claims")
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := ac.refreshTokenService.RevokeAllForUser(userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := ac.revocationService.RevokeAllForUser(userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionLogoutAll, userID, nil)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// rejectInactiveLogin responds with 403 and returns true when the account's status
+// doesn't allow signing in
+func rejectInactiveLogin(ctx *gin.Context, user *models.User) bool {
+	return respondAccountStatusError(ctx, services.AccountStatusError(user))
+}
+
+// respondAccountStatusError responds with 403 and the status code when err is an account
+// status error, and reports whether it did. Signing in to an account pending deletion
+// also returns a restore token for POST /auth/restore.
+func respondAccountStatusError(ctx *gin.Context, err error) bool {
+	code := services.AccountStatusCode(err)
+	if code == "" {
+		return false
+	}
+
+	var pendingDeletion *services.PendingDeletionError
+	if errors.As(err, &pendingDeletion) {
+		restoreToken, err := services.GetUserStatusService().IssueRestoreToken(pendingDeletion.UserID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return true
+		}
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error":         "Account is scheduled for deletion",
+			"code":          code,
+			"purge_at":      pendingDeletion.PurgeAt,
+			"restore_token": restoreToken,
+		})
+		return true
+	}
+
+	ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": code})
+	return true
+}
+
+// respondLoginThrottled responds with 429 and a Retry-After header when err refuses a login
+// because of earlier failures, and reports whether it did
+func respondLoginThrottled(ctx *gin.Context, err error) bool {
+	var throttled *services.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	code := "too_many_attempts"
+	if throttled.AccountLocked {
+		code = "account_locked"
+	}
+	ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "code": code, "retry_after": retryAfter})
+	return true
+}
+
+// recordMFAFailure records a failed second factor for the user of the challenge, if it is still valid
+func recordMFAFailure(ctx *gin.Context, mfaService *services.MFAService, challenge string, err error) {
+	var reason string
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		reason = "invalid_code"
+	case errors.Is(err, services.ErrInvalidMFAChallenge), errors.Is(err, services.ErrMFANotEnabled):
+		reason = "invalid_challenge"
+	case errors.Is(err, services.ErrTooManyMFAAttempts):
+		reason = "throttled"
+	default:
+		return
+	}
+
+	var userID uint
+	if id, challengeErr := mfaService.ChallengeUserID(challenge); challengeErr == nil {
+		userID = id
 	}
-	return claims, nil
+	recordAudit(ctx, services.AuditActionMFAFailed, userID, models.AuditMetadata{"reason": reason})
 }
 
-// generateJWTToken creates a new JWT token with updated expiry using the provided claims
-func generateJWTToken(claims jwt.MapClaims) (string, error) {
-	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  claims["user_id"],
-		"email":    claims["email"],
-		"username": claims["username"],
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
-		"iat":      time.Now().Unix(),
+// rejectUnverifiedLogin responds with 403 and returns true when the email verification
+// policy forbids the user from logging in
+func rejectUnverifiedLogin(ctx *gin.Context, user *models.User) bool {
+	if user.IsEmailVerified() || services.CurrentEmailVerificationPolicy() != services.EmailVerificationPolicyLogin {
+		return false
+	}
+	ctx.JSON(http.StatusForbidden, gin.H{
+		"error": "Email address has not been verified",
+		"code":  "email_not_verified",
 	})
-	secret := []byte(os.Getenv("JWT_SECRET"))
-	return newToken.SignedString(secret)
+	return true
+}
+
+// tokenResponse builds the JSON body returned after a successful authentication
+func tokenResponse(tokens *services.TokenPair) gin.H {
+	return gin.H{
+		"user":          tokens.User,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    int(tokens.ExpiresIn.Seconds()),
+		"session_id":    tokens.SessionID,
+	}
 }
 
 // AppleLoginRequest defines the request body for Apple login
 type AppleLoginRequest struct {
 	IdentityToken string `json:"identity_token" binding:"required"`
-	UserID        string `json:"user_id" binding:"required"`
-	Email         string `json:"email"`
+	Nonce         string `json:"nonce"` // Raw nonce whose SHA-256 hash was sent to Apple
 	Username      string `json:"username"`
 }
 
@@ -210,39 +632,106 @@ func (ac *AuthController) AppleLogin(ctx *gin.Context) {
 		return
 	}
 
-	// Verify Apple token (would be implemented in a real application)
-	// For this example, we'll assume the token is valid
+	// Verify the identity token; the Apple user ID and email come from its claims only
+	identity, err := ac.appleVerifier.Verify(req.IdentityToken, req.Nonce)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Apple identity token"})
+		return
+	}
 
-	// Use a default username if not provided
-	username := req.Username
-	if username == "" {
-		username = "user_" + req.UserID[:8]
+	// Create or get the user linked to the Apple ID
+	user, err := ac.identityService.SignIn(&services.FederatedIdentity{
+		Provider:          "apple",
+		Subject:           identity.Subject,
+		Email:             identity.Email,
+		EmailVerified:     identity.EmailVerified,
+		PreferredUsername: req.Username,
+		Claims: map[string]any{
+			"sub":              identity.Subject,
+			"email":            identity.Email,
+			"email_verified":   identity.EmailVerified,
+			"is_private_email": identity.IsPrivateEmail,
+		},
+	})
+	if err != nil {
+		respondIdentityError(ctx, err)
+		return
 	}
 
-	// Create or get existing user with Apple credentials
-	user, err := ac.userService.CreateAppleUser(req.UserID, req.Email, username)
+	ac.completeLogin(ctx, user, "apple")
+}
+
+// OIDCAuthorizeRequest defines the optional request body for starting a login with an external provider
+type OIDCAuthorizeRequest struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// OIDCCallbackRequest defines the request body for completing a login with an external provider
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// OIDCProviders handles GET /auth/oidc/providers
+func (ac *AuthController) OIDCProviders(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"providers": ac.oidcService.Providers()})
+}
+
+// OIDCAuthorize handles POST /auth/oidc/:provider/authorize
+// The client sends the user to authorization_url and keeps state to match the callback.
+func (ac *AuthController) OIDCAuthorize(ctx *gin.Context) {
+	var req OIDCAuthorizeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	authorization, err := ac.oidcService.BeginAuthorization(ctx.Param("provider"), req.RedirectURI)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrUnknownOIDCProvider) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Generate JWT token
-	secret := []byte(os.Getenv("JWT_SECRET"))
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"email":    user.Email,
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
-		"iat":      time.Now().Unix(),                     // Issued at
-	}).SignedString(secret)
+	ctx.JSON(http.StatusOK, authorization)
+}
+
+// OIDCCallback handles POST /auth/oidc/:provider/callback
+// The client forwards the code and state the provider redirected back with.
+// It responds like Login, including the MFA challenge for users with two-factor authentication.
+func (ac *AuthController) OIDCCallback(ctx *gin.Context) {
+	var req OIDCCallbackRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	user, err := ac.oidcService.CompleteAuthorization(ctx.Request.Context(), ctx.Param("provider"), req.Code, req.State)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondIdentityError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": token,
-	})
+	ac.completeLogin(ctx, user, "oidc:"+ctx.Param("provider"))
+}
+
+// respondIdentityError maps external sign in errors to HTTP responses
+func respondIdentityError(ctx *gin.Context, err error) {
+	if respondAccountStatusError(ctx, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrUnknownOIDCProvider):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidOIDCState), errors.Is(err, services.ErrInvalidIDToken):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrIdentityEmailInUse):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "identity_email_in_use"})
+	default:
+		log.Printf("External sign in failed: %v", err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Failed to sign in with the identity provider"})
+	}
 }
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// DataExportController handles the routes users get a copy of their data through
+type DataExportController struct {
+	exportService *services.DataExportService
+}
+
+// NewDataExportController creates a new DataExportController instance
+func NewDataExportController() *DataExportController {
+	return &DataExportController{
+		exportService: services.GetDataExportService(),
+	}
+}
+
+// RequestExportRequest defines the optional request body for requesting a data export
+type RequestExportRequest struct {
+	Format string `json:"format"` // "json" (default) or "zip"
+}
+
+// RequestExport handles POST /user/export
+// The export is assembled in the background; poll GET /user/exports/:id until it is ready.
+func (dc *DataExportController) RequestExport(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// The body is optional
+	var req RequestExportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	export, err := dc.exportService.RequestExport(userID, req.Format)
+	if err != nil {
+		respondDataExportError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, export)
+}
+
+// ListExports handles GET /user/exports
+func (dc *DataExportController) ListExports(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	exports, err := dc.exportService.List(userID)
+	if err != nil {
+		respondDataExportError(ctx, err)
+		return
+	}
+	if exports == nil {
+		exports = []models.DataExport{}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"exports": exports})
+}
+
+// GetExport handles GET /user/exports/:id
+func (dc *DataExportController) GetExport(ctx *gin.Context) {
+	userID, id, ok := dc.exportParams(ctx)
+	if !ok {
+		return
+	}
+
+	export, err := dc.exportService.Get(userID, id)
+	if err != nil {
+		respondDataExportError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, export)
+}
+
+// CreateDownloadLink handles POST /user/exports/:id/link
+// The link works without authentication, so it can be opened in a browser, until it expires.
+func (dc *DataExportController) CreateDownloadLink(ctx *gin.Context) {
+	userID, id, ok := dc.exportParams(ctx)
+	if !ok {
+		return
+	}
+
+	link, expiresAt, err := dc.exportService.CreateDownloadLink(userID, id)
+	if err != nil {
+		respondDataExportError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"download_url": link,
+		"expires_at":   expiresAt,
+	})
+}
+
+// Download handles GET /user/exports/download?token=...
+func (dc *DataExportController) Download(ctx *gin.Context) {
+	export, err := dc.exportService.Download(ctx.Query("token"))
+	if err != nil {
+		respondDataExportError(ctx, err)
+		return
+	}
+
+	contentType := "application/json"
+	if export.Format == services.DataExportFormatZip {
+		contentType = "application/zip"
+	}
+	ctx.Header("Content-Disposition", `attachment; filename="`+dc.exportService.FileName(export)+`"`)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(http.StatusOK, contentType, export.Archive)
+}
+
+// exportParams returns the caller and the export ID in the path, responding with an error
+// and false when either is missing
+func (dc *DataExportController) exportParams(ctx *gin.Context) (uint, uint, bool) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid export id"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+// respondDataExportError maps data export errors to HTTP responses
+func respondDataExportError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDataExportFormat):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDataExportNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDataExportInProgress):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "export_in_progress"})
+	case errors.Is(err, services.ErrDataExportNotReady):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "export_not_ready"})
+	case errors.Is(err, services.ErrInvalidDownloadToken):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process data export"})
+	}
+}
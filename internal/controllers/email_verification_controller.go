@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailVerificationController handles email verification routes
+type EmailVerificationController struct {
+	emailVerification *services.EmailVerificationService
+}
+
+// NewEmailVerificationController creates a new EmailVerificationController instance
+func NewEmailVerificationController() *EmailVerificationController {
+	return &EmailVerificationController{
+		emailVerification: services.NewEmailVerificationService(),
+	}
+}
+
+// VerifyEmailRequest defines the request body for email verification
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest defines the request body for resending the verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyEmail handles POST /auth/verify-email
+func (vc *EmailVerificationController) VerifyEmail(ctx *gin.Context) {
+	var req VerifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := vc.emailVerification.Verify(req.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+		"user":    user,
+	})
+}
+
+// ResendVerificationEmail handles POST /auth/verify-email/resend
+// It always responds with 202 so the endpoint cannot be used to probe for accounts.
+func (vc *EmailVerificationController) ResendVerificationEmail(ctx *gin.Context) {
+	var req ResendVerificationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := vc.emailVerification.Resend(req.Email); err != nil {
+		log.Printf("Failed to resend verification email: %v", err)
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message": "If an unverified account exists for this email, a verification link has been sent",
+	})
+}
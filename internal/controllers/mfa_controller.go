@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// MFAController handles two-factor authentication management routes
+type MFAController struct {
+	userService *services.UserService
+	mfaService  *services.MFAService
+}
+
+// NewMFAController creates a new MFAController instance
+func NewMFAController() *MFAController {
+	return &MFAController{
+		userService: services.NewUserService(),
+		mfaService:  services.NewMFAService(),
+	}
+}
+
+// MFACodeRequest defines the request body for operations that need a TOTP code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTOTPRequest defines the request body for disabling TOTP.
+// Either a TOTP code or a recovery code must be provided.
+type DisableTOTPRequest struct {
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// GetStatus handles GET /user/mfa
+func (mc *MFAController) GetStatus(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	status, err := mc.mfaService.Status(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
+
+// EnrollTOTP handles POST /user/mfa/totp
+// It returns the secret and otpauth:// URI to show as a QR code.
+func (mc *MFAController) EnrollTOTP(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	user, err := mc.userService.GetUserByID(userID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := mc.mfaService.BeginTOTPEnrollment(user)
+	if err != nil {
+		respondMFAError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP handles POST /user/mfa/totp/confirm
+func (mc *MFAController) ConfirmTOTP(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := mc.mfaService.ConfirmTOTPEnrollment(userID, req.Code)
+	if err != nil {
+		respondMFAError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionMFAEnabled, userID, models.AuditMetadata{"method": "totp"})
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP handles DELETE /user/mfa/totp
+func (mc *MFAController) DisableTOTP(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req DisableTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := mc.mfaService.DisableTOTP(userID, req.Code, req.RecoveryCode); err != nil {
+		respondMFAError(ctx, err)
+		return
+	}
+
+	verifiedWith := "totp"
+	if req.RecoveryCode != "" {
+		verifiedWith = "recovery_code"
+	}
+	recordAudit(ctx, services.AuditActionMFADisabled, userID, models.AuditMetadata{"method": "totp", "verified_with": verifiedWith})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes handles POST /user/mfa/recovery-codes
+func (mc *MFAController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := mc.mfaService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		respondMFAError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionRecoveryCodesReset, userID, nil)
+	ctx.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// respondMFAError maps MFA service errors to HTTP responses
+func respondMFAError(ctx *gin.Context, err error) {
+	if respondMFAThrottled(ctx, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEncryptionNotConfigured):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Two-factor authentication is not available"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process two-factor authentication request"})
+	}
+}
+
+// respondMFAThrottled responds with 429 and Retry-After and returns true when a second factor
+// was refused because the user's second factor is locked
+func respondMFAThrottled(ctx *gin.Context, err error) bool {
+	var throttled *services.MFAThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "code": "too_many_attempts", "retry_after": retryAfter})
+	return true
+}
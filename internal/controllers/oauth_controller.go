@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthController handles the OAuth 2.0 / OpenID Connect provider endpoints
+type OAuthController struct {
+	oauthService *services.OAuthService
+}
+
+// NewOAuthController creates a new OAuthController instance
+func NewOAuthController() *OAuthController {
+	return &OAuthController{
+		oauthService: services.NewOAuthService(),
+	}
+}
+
+// AuthorizeDecisionRequest is an authorization request submitted by our login UI,
+// with the user's consent decision once the consent screen has been shown
+type AuthorizeDecisionRequest struct {
+	services.AuthorizationRequest
+	Consent string `json:"consent"`
+}
+
+// RegisterClient handles POST /oauth/clients
+// It requires the initial access token configured in OAUTH_REGISTRATION_TOKEN.
+func (oc *OAuthController) RegisterClient(ctx *gin.Context) {
+	if !oc.oauthService.AuthorizeRegistration(bearerToken(ctx)) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	var req services.ClientRegistration
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client_metadata", "error_description": err.Error()})
+		return
+	}
+
+	client, err := oc.oauthService.RegisterClient(&req)
+	if err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, client)
+}
+
+// Authorize handles GET /oauth/authorize
+// The client's user agent lands here; once the client and redirect URI check out it is
+// sent on to the login UI, which signs the user in and submits the request with POST.
+func (oc *OAuthController) Authorize(ctx *gin.Context) {
+	var req services.AuthorizationRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+
+	if _, _, err := oc.oauthService.ValidateClientRedirect(&req); err != nil {
+		respondAuthorizationError(ctx, err)
+		return
+	}
+
+	loginURL := oc.oauthService.LoginURL()
+	separator := "?"
+	if strings.Contains(loginURL, "?") {
+		separator = "&"
+	}
+	ctx.Redirect(http.StatusFound, loginURL+separator+ctx.Request.URL.RawQuery)
+}
+
+// AuthorizeDecision handles POST /oauth/authorize
+// It either returns the client redirect or asks the UI to show the consent screen.
+func (oc *OAuthController) AuthorizeDecision(ctx *gin.Context) {
+	claims, ok := middleware.ExtractClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req AuthorizeDecisionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Consent != "" && req.Consent != services.ConsentApprove && req.Consent != services.ConsentDeny {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "consent must be approve or deny"})
+		return
+	}
+	if claims.AuthTime == nil {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error": services.ErrReauthenticationRequired.Error(),
+			"code":  "reauthentication_required",
+		})
+		return
+	}
+
+	result, err := oc.oauthService.Authorize(claims.UserID, claims.AuthTime.Time, &req.AuthorizationRequest, req.Consent)
+	if err != nil {
+		if errors.Is(err, services.ErrReauthenticationRequired) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "reauthentication_required"})
+			return
+		}
+		respondAuthorizationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// Token handles POST /oauth/token
+// Clients authenticate with HTTP Basic or with client_id/client_secret form parameters.
+func (oc *OAuthController) Token(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+
+	req := &services.TokenRequest{
+		GrantType:    ctx.PostForm("grant_type"),
+		Code:         ctx.PostForm("code"),
+		RedirectURI:  ctx.PostForm("redirect_uri"),
+		CodeVerifier: ctx.PostForm("code_verifier"),
+		RefreshToken: ctx.PostForm("refresh_token"),
+		Scope:        ctx.PostForm("scope"),
+	}
+	if req.GrantType == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "grant_type is required"})
+		return
+	}
+	req.ClientID, req.ClientSecret = clientCredentials(ctx)
+
+	response, err := oc.oauthService.Token(req)
+	if err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// Introspect handles POST /oauth/introspect
+// The calling service authenticates with its client credentials.
+func (oc *OAuthController) Introspect(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+
+	clientID, clientSecret := clientCredentials(ctx)
+	response, err := oc.oauthService.Introspect(clientID, clientSecret, ctx.PostForm("token"), ctx.PostForm("token_type_hint"))
+	if err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// Revoke handles POST /oauth/revoke
+// It responds with 200 for unknown tokens too, as required by RFC 7009.
+func (oc *OAuthController) Revoke(ctx *gin.Context) {
+	clientID, clientSecret := clientCredentials(ctx)
+	if err := oc.oauthService.Revoke(clientID, clientSecret, ctx.PostForm("token"), ctx.PostForm("token_type_hint")); err != nil {
+		respondOAuthError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// UserInfo handles GET and POST /userinfo
+func (oc *OAuthController) UserInfo(ctx *gin.Context) {
+	claims, err := oc.oauthService.UserInfo(bearerToken(ctx))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrTokenRevoked) {
+			ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, claims)
+}
+
+// clientCredentials reads the client ID and secret from HTTP Basic auth or the form body
+func clientCredentials(ctx *gin.Context) (string, string) {
+	if clientID, clientSecret, ok := ctx.Request.BasicAuth(); ok {
+		return clientID, clientSecret
+	}
+	return ctx.PostForm("client_id"), ctx.PostForm("client_secret")
+}
+
+// bearerToken returns the token of a "Bearer" Authorization header, or ""
+func bearerToken(ctx *gin.Context) string {
+	scheme, token, found := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// respondAuthorizationError reports authorization errors that can't be sent back to the client
+func respondAuthorizationError(ctx *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidAuthorizationClient) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	log.Printf("Authorization request failed: %v", err)
+	ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+}
+
+// respondOAuthError maps errors to RFC 6749 error responses
+func respondOAuthError(ctx *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("OAuth request failed: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	body := gin.H{"error": oauthErr.Code}
+	if oauthErr.Description != "" {
+		body["error_description"] = oauthErr.Description
+	}
+	if oauthErr.Code == "invalid_client" {
+		ctx.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		ctx.JSON(http.StatusUnauthorized, body)
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, body)
+}
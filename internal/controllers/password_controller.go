@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordController handles password recovery routes
+type PasswordController struct {
+	passwordResetService *services.PasswordResetService
+}
+
+// NewPasswordController creates a new PasswordController instance
+func NewPasswordController() *PasswordController {
+	return &PasswordController{
+		passwordResetService: services.NewPasswordResetService(),
+	}
+}
+
+// ForgotPasswordRequest defines the request body for requesting a password reset
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest defines the request body for resetting a password
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// ForgotPassword handles POST /auth/password/forgot
+// The reset is processed in the background and the response is always 202, so neither
+// the status code nor the response time reveals whether an account exists.
+func (pc *PasswordController) ForgotPassword(ctx *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	go func(email string) {
+		if err := pc.passwordResetService.RequestReset(email); err != nil {
+			log.Printf("Failed to process password reset request: %v", err)
+		}
+	}(req.Email)
+
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message": "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// ResetPassword handles POST /auth/password/reset
+func (pc *PasswordController) ResetPassword(ctx *gin.Context) {
+	var req ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := pc.passwordResetService.ResetPassword(req.Token, req.Password)
+	if err != nil {
+		var policyErr *services.PasswordPolicyError
+		if errors.Is(err, services.ErrInvalidResetToken) || errors.As(err, &policyErr) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionPasswordReset, user.ID, nil)
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, please log in again"})
+}
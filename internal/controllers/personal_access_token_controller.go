@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// PersonalAccessTokenController handles routes for managing personal access tokens
+type PersonalAccessTokenController struct {
+	tokenService *services.PersonalAccessTokenService
+}
+
+// NewPersonalAccessTokenController creates a new PersonalAccessTokenController instance
+func NewPersonalAccessTokenController() *PersonalAccessTokenController {
+	return &PersonalAccessTokenController{
+		tokenService: services.NewPersonalAccessTokenService(),
+	}
+}
+
+// CreatePersonalAccessTokenRequest defines the request body for creating a personal access token
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ListTokens handles GET /user/tokens
+func (pc *PersonalAccessTokenController) ListTokens(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	tokens, err := pc.tokenService.List(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]gin.H, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, personalAccessTokenResponse(&token))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"tokens": response})
+}
+
+// CreateToken handles POST /user/tokens
+// The plaintext token is only returned in this response.
+func (pc *PersonalAccessTokenController) CreateToken(ctx *gin.Context) {
+	claims, ok := requireRecentAuthentication(ctx)
+	if !ok {
+		return
+	}
+
+	var req CreatePersonalAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plaintext, token, err := pc.tokenService.Create(claims.UserID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPersonalAccessTokenScope) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "allowed_scopes": services.PersonalAccessTokenScopes})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionTokenCreated, claims.UserID, models.AuditMetadata{
+		"token_id": token.ID,
+		"name":     token.Name,
+		"scopes":   token.ScopeList(),
+	})
+
+	response := personalAccessTokenResponse(token)
+	response["token"] = plaintext
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// DeleteToken handles DELETE /user/tokens/:id
+func (pc *PersonalAccessTokenController) DeleteToken(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid token id"})
+		return
+	}
+
+	if err := pc.tokenService.Delete(userID, uint(id)); err != nil {
+		if errors.Is(err, services.ErrPersonalAccessTokenNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete token"})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionTokenDeleted, userID, models.AuditMetadata{"token_id": id})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Token deleted"})
+}
+
+// personalAccessTokenResponse describes a token without its secret
+func personalAccessTokenResponse(token *models.PersonalAccessToken) gin.H {
+	return gin.H{
+		"id":           token.ID,
+		"name":         token.Name,
+		"prefix":       token.Prefix,
+		"scopes":       token.ScopeList(),
+		"expires_at":   token.ExpiresAt,
+		"last_used_at": token.LastUsedAt,
+		"created_at":   token.CreatedAt,
+	}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleController handles the administration of roles and role assignments
+type RoleController struct {
+	rbacService *services.RBACService
+}
+
+// NewRoleController creates a new RoleController instance
+func NewRoleController() *RoleController {
+	return &RoleController{
+		rbacService: services.NewRBACService(),
+	}
+}
+
+// ListRoles handles GET /admin/roles
+func (rc *RoleController) ListRoles(ctx *gin.Context) {
+	roles, err := rc.rbacService.ListRoles()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetUserRoles handles GET /admin/users/:id/roles
+func (rc *RoleController) GetUserRoles(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	roles, err := rc.rbacService.UserRoles(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// AssignRole handles PUT /admin/users/:id/roles/:role
+func (rc *RoleController) AssignRole(ctx *gin.Context) {
+	actorID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := rc.rbacService.AssignRole(actorID, userID, ctx.Param("role")); err != nil {
+		respondRoleError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionAdminRoleAssigned, userID, models.AuditMetadata{"role": ctx.Param("role")})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Role assigned"})
+}
+
+// UnassignRole handles DELETE /admin/users/:id/roles/:role
+func (rc *RoleController) UnassignRole(ctx *gin.Context) {
+	userID, ok := userIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := rc.rbacService.UnassignRole(userID, ctx.Param("role")); err != nil {
+		respondRoleError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionAdminRoleUnassigned, userID, models.AuditMetadata{"role": ctx.Param("role")})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Role removed"})
+}
+
+// userIDParam parses the :id path parameter, responding with 400 when it's invalid
+func userIDParam(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondRoleError maps role assignment errors to HTTP responses
+func respondRoleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound), errors.Is(err, services.ErrRoleNotAssigned), errors.Is(err, services.ErrUserNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLastAdmin):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "last_admin"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roles"})
+	}
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceNameHeader lets clients name the device a new session is started on.
+// Without it, the name is derived from the User-Agent.
+const DeviceNameHeader = "X-Device-Name"
+
+// SessionController handles the routes that let users see and end their sessions
+type SessionController struct {
+	sessionService *services.SessionService
+}
+
+// NewSessionController creates a new SessionController instance
+func NewSessionController() *SessionController {
+	return &SessionController{
+		sessionService: services.NewSessionService(),
+	}
+}
+
+// ListSessionsQuery defines the query parameters of the session listing
+type ListSessionsQuery struct {
+	IncludeEnded bool `form:"include_ended"` // Also list revoked and expired sessions, i.e. the login history
+}
+
+// ListSessions handles GET /user/sessions
+// The session of the access token used for the request is marked as current.
+func (sc *SessionController) ListSessions(ctx *gin.Context) {
+	claims, ok := middleware.ExtractClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var query ListSessionsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessions, err := sc.sessionService.List(claims.UserID, query.IncludeEnded)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse(&session, claims.SessionID))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+// RevokeSession handles DELETE /user/sessions/:id
+// The device is signed out: its refresh token stops working and its access tokens are rejected.
+func (sc *SessionController) RevokeSession(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+		return
+	}
+
+	if err := sc.sessionService.Revoke(userID, uint(id)); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionSessionRevoked, userID, models.AuditMetadata{"session_id": id})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// sessionResponse describes a session, marking the one with the ID of currentSessionID
+func sessionResponse(session *models.Session, currentSessionID uint) gin.H {
+	return gin.H{
+		"id":           session.ID,
+		"device_name":  session.DeviceName,
+		"user_agent":   session.UserAgent,
+		"ip":           session.IP,
+		"auth_method":  session.AuthMethod,
+		"created_at":   session.CreatedAt,
+		"last_seen_at": session.LastSeenAt,
+		"expires_at":   session.ExpiresAt,
+		"revoked_at":   session.RevokedAt,
+		"active":       session.IsActive(),
+		"current":      currentSessionID != 0 && session.ID == currentSessionID,
+	}
+}
+
+// sessionInfo describes the device of the request for a session started with the given method
+func sessionInfo(ctx *gin.Context, method string) services.SessionInfo {
+	return services.SessionInfo{
+		DeviceName: ctx.GetHeader(DeviceNameHeader),
+		UserAgent:  ctx.Request.UserAgent(),
+		IP:         ctx.ClientIP(),
+		AuthMethod: method,
+	}
+}
@@ -1,22 +1,32 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
 	"github.com/danigrb.dev/user-service/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 // UserController handles user-related routes
 type UserController struct {
-	userService *services.UserService
+	userService         *services.UserService
+	authService         *services.AuthService
+	refreshTokenService *services.RefreshTokenService
+	revocationService   *services.RevocationService
+	emailVerification   *services.EmailVerificationService
 }
 
 // NewUserController creates a new UserController instance
 func NewUserController() *UserController {
 	return &UserController{
-		userService: services.NewUserService(),
+		userService:         services.NewUserService(),
+		authService:         services.NewAuthService(),
+		refreshTokenService: services.NewRefreshTokenService(),
+		revocationService:   services.GetRevocationService(),
+		emailVerification:   services.NewEmailVerificationService(),
 	}
 }
 
@@ -28,6 +38,14 @@ type UpdateProfileRequest struct {
 	Preferences map[string]any `json:"preferences,omitempty"`
 }
 
+// ChangePasswordRequest defines the request body for changing the password.
+// CurrentPassword may be omitted by users who don't have a password yet.
+type ChangePasswordRequest struct {
+	CurrentPassword     string `json:"current_password"`
+	NewPassword         string `json:"new_password" binding:"required"`
+	LogoutOtherSessions bool   `json:"logout_other_sessions"`
+}
+
 // GetProfile handles GET /user/profile
 func (uc *UserController) GetProfile(ctx *gin.Context) {
 	// Extract user ID from JWT claims using the utility function
@@ -76,16 +94,42 @@ func (uc *UserController) UpdateProfile(ctx *gin.Context) {
 		updates["preferences"] = req.Preferences
 	}
 
+	// Keep the current profile to record what changed
+	before, err := uc.userService.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	before = services.CloneUser(before)
+
 	updatedUser, err := uc.userService.UpdateUserProfile(userID, updates)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	changes := services.UserChanges(before, updatedUser)
+	if len(changes) > 0 {
+		recordAuditChanges(ctx, services.AuditActionProfileUpdated, userID, changes, nil)
+	}
+	if change, ok := changes["email"]; ok {
+		recordAuditChanges(ctx, services.AuditActionEmailChanged, userID, models.AuditChanges{"email": change}, nil)
+	}
+
+	// A changed email address has to be verified again
+	if req.Email != "" && !updatedUser.IsEmailVerified() {
+		uc.emailVerification.SendVerificationEmailAsync(updatedUser)
+	}
+
 	ctx.JSON(http.StatusOK, updatedUser)
 }
 
 // DeleteUser handles DELETE /user/profile
+// The account is purged once the deletion grace period ends; logging in before restores it.
 func (uc *UserController) DeleteUser(ctx *gin.Context) {
 	// Extract user ID from JWT claims using the utility function
 	userID, ok := middleware.ExtractUserID(ctx)
@@ -94,10 +138,77 @@ func (uc *UserController) DeleteUser(ctx *gin.Context) {
 		return
 	}
 
-	if err := uc.userService.DeleteUser(userID); err != nil {
+	user, err := uc.userService.DeleteUser(userID)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	recordAudit(ctx, services.AuditActionAccountDeleted, userID, models.AuditMetadata{"purge_at": user.PurgeAt})
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "Account scheduled for deletion",
+		"purge_at": user.PurgeAt,
+	})
+}
+
+// ChangePassword handles PUT /user/password
+// When other sessions are logged out, a fresh token pair for the current device is returned.
+func (uc *UserController) ChangePassword(ctx *gin.Context) {
+	claims, ok := middleware.ExtractClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Setting an initial password without knowing an old one requires a fresh login
+	user, err := uc.userService.ChangePassword(claims.UserID, req.CurrentPassword, req.NewPassword, recentlyAuthenticated(claims))
+	if err != nil {
+		var policyErr *services.PasswordPolicyError
+		switch {
+		case errors.Is(err, services.ErrIncorrectPassword):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrReauthenticationRequired):
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "reauthentication_required"})
+		case errors.As(err, &policyErr):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionPasswordChanged, user.ID, models.AuditMetadata{
+		"logout_other_sessions": req.LogoutOtherSessions,
+	})
+
+	if !req.LogoutOtherSessions {
+		ctx.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+		return
+	}
+
+	// Revoke everything issued so far, including this session, then start a new session here
+	if err := uc.refreshTokenService.RevokeAllForUser(user.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := uc.revocationService.RevokeAllForUser(user.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	tokens, err := uc.authService.IssueTokens(user, sessionInfo(ctx, "password_change"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	response := tokenResponse(tokens)
+	response["message"] = "Password changed successfully, other sessions have been signed out"
+	ctx.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// WebAuthnController handles passkey registration and login routes
+type WebAuthnController struct {
+	userService     *services.UserService
+	authService     *services.AuthService
+	mfaService      *services.MFAService
+	webAuthnService *services.WebAuthnService
+	accountLinks    *services.AccountLinkService
+}
+
+// NewWebAuthnController creates a new WebAuthnController instance
+func NewWebAuthnController() *WebAuthnController {
+	return &WebAuthnController{
+		userService:     services.NewUserService(),
+		authService:     services.NewAuthService(),
+		mfaService:      services.NewMFAService(),
+		webAuthnService: services.NewWebAuthnService(),
+		accountLinks:    services.NewAccountLinkService(),
+	}
+}
+
+// FinishRegistrationRequest defines the request body for completing a passkey registration
+type FinishRegistrationRequest struct {
+	CeremonyToken string                         `json:"ceremony_token" binding:"required"`
+	Name          string                         `json:"name" binding:"max=64"`
+	Credential    *services.RegistrationResponse `json:"credential" binding:"required"`
+}
+
+// BeginWebAuthnLoginRequest defines the optional request body for starting a passwordless login
+type BeginWebAuthnLoginRequest struct {
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+// FinishWebAuthnLoginRequest defines the request body for completing a passwordless login
+type FinishWebAuthnLoginRequest struct {
+	CeremonyToken string                      `json:"ceremony_token" binding:"required"`
+	Credential    *services.AssertionResponse `json:"credential" binding:"required"`
+}
+
+// BeginWebAuthnMFARequest defines the request body for starting a passkey second factor
+type BeginWebAuthnMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// FinishWebAuthnMFARequest defines the request body for completing a login with a passkey second factor
+type FinishWebAuthnMFARequest struct {
+	MFAToken      string                      `json:"mfa_token" binding:"required"`
+	CeremonyToken string                      `json:"ceremony_token" binding:"required"`
+	Credential    *services.AssertionResponse `json:"credential" binding:"required"`
+}
+
+// BeginRegistration handles POST /auth/webauthn/register/begin
+func (wc *WebAuthnController) BeginRegistration(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	user, err := wc.userService.GetUserByID(userID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ceremony, err := wc.webAuthnService.BeginRegistration(user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ceremony)
+}
+
+// FinishRegistration handles POST /auth/webauthn/register/finish
+func (wc *WebAuthnController) FinishRegistration(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req FinishRegistrationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := wc.webAuthnService.FinishRegistration(userID, req.CeremonyToken, req.Credential, req.Name)
+	if err != nil {
+		respondWebAuthnError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionPasskeyRegistered, userID, models.AuditMetadata{
+		"credential_id": credential.ID,
+		"name":          credential.Name,
+		"aaguid":        credential.AAGUID,
+	})
+	ctx.JSON(http.StatusCreated, credential)
+}
+
+// BeginLogin handles POST /auth/webauthn/login/begin
+func (wc *WebAuthnController) BeginLogin(ctx *gin.Context) {
+	// The body is optional; without an email any discoverable passkey can be used
+	var req BeginWebAuthnLoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ceremony, err := wc.webAuthnService.BeginLogin(req.Email)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey login"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ceremony)
+}
+
+// FinishLogin handles POST /auth/webauthn/login/finish
+// A user-verified passkey already combines possession and a PIN or biometric,
+// so no additional second factor is requested.
+func (wc *WebAuthnController) FinishLogin(ctx *gin.Context) {
+	var req FinishWebAuthnLoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := wc.webAuthnService.FinishLogin(req.CeremonyToken, req.Credential)
+	if err != nil {
+		if isWebAuthnClientError(err) {
+			recordAudit(ctx, services.AuditActionLoginFailed, 0, models.AuditMetadata{
+				"method": "passkey",
+				"reason": "invalid_passkey",
+			})
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey verification failed"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify passkey"})
+		return
+	}
+
+	if rejectInactiveLogin(ctx, user) || rejectUnverifiedLogin(ctx, user) {
+		recordAudit(ctx, services.AuditActionLoginFailed, user.ID, models.AuditMetadata{
+			"method": "passkey",
+			"reason": "login_not_allowed",
+		})
+		return
+	}
+
+	// Generate access and refresh tokens
+	tokens, err := wc.authService.IssueTokens(user, sessionInfo(ctx, "passkey"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionLogin, user.ID, models.AuditMetadata{"method": "passkey"})
+	ctx.JSON(http.StatusOK, tokenResponse(tokens))
+}
+
+// BeginMFA handles POST /auth/webauthn/mfa/begin
+// It starts an assertion for the user of the challenge token returned by Login.
+func (wc *WebAuthnController) BeginMFA(ctx *gin.Context) {
+	var req BeginWebAuthnMFARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := wc.mfaService.ChallengeUserID(req.MFAToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": services.ErrInvalidMFAChallenge.Error()})
+		return
+	}
+
+	ceremony, err := wc.webAuthnService.BeginSecondFactor(userID)
+	if err != nil {
+		respondWebAuthnError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ceremony)
+}
+
+// FinishMFA handles POST /auth/webauthn/mfa/finish
+func (wc *WebAuthnController) FinishMFA(ctx *gin.Context) {
+	var req FinishWebAuthnMFARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := wc.mfaService.CompleteChallengeWith(req.MFAToken, func(userID uint) error {
+		err := wc.webAuthnService.VerifySecondFactor(userID, req.CeremonyToken, req.Credential)
+		if isWebAuthnClientError(err) {
+			// Answer failed assertions like wrong TOTP codes
+			return errors.Join(services.ErrInvalidMFACode, err)
+		}
+		return err
+	})
+	if err != nil {
+		recordMFAFailure(ctx, wc.mfaService, req.MFAToken, err)
+		if respondMFAThrottled(ctx, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidMFAChallenge):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidMFACode):
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey verification failed"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify passkey"})
+		}
+		return
+	}
+
+	// The account may have been suspended while the challenge was pending
+	if rejectInactiveLogin(ctx, user) {
+		return
+	}
+
+	// Generate access and refresh tokens
+	tokens, err := wc.authService.IssueTokens(user, sessionInfo(ctx, "webauthn"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionMFAVerified, user.ID, models.AuditMetadata{"method": "webauthn"})
+	ctx.JSON(http.StatusOK, tokenResponse(tokens))
+}
+
+// ListCredentials handles GET /user/webauthn/credentials
+func (wc *WebAuthnController) ListCredentials(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	credentials, err := wc.webAuthnService.ListCredentials(userID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+// DeleteCredential handles DELETE /user/webauthn/credentials/:id
+// The last passkey of an account without a password or linked identity can't be removed.
+func (wc *WebAuthnController) DeleteCredential(ctx *gin.Context) {
+	userID, ok := middleware.ExtractUserID(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential id"})
+		return
+	}
+
+	if err := wc.accountLinks.RemovePasskey(userID, uint(id)); err != nil {
+		if errors.Is(err, services.ErrLastLoginMethod) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "last_login_method"})
+			return
+		}
+		respondWebAuthnError(ctx, err)
+		return
+	}
+
+	recordAudit(ctx, services.AuditActionPasskeyRemoved, userID, models.AuditMetadata{"credential_id": id})
+	ctx.JSON(http.StatusOK, gin.H{"message": "Passkey removed"})
+}
+
+// isWebAuthnClientError reports whether err was caused by the client's input rather than the server
+func isWebAuthnClientError(err error) bool {
+	return errors.Is(err, services.ErrInvalidWebAuthnResponse) ||
+		errors.Is(err, services.ErrInvalidWebAuthnCeremony) ||
+		errors.Is(err, services.ErrWebAuthnCredentialNotFound)
+}
+
+// respondWebAuthnError maps WebAuthn service errors to HTTP responses
+func respondWebAuthnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebAuthnCredentialExists):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWebAuthnCredentialNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidWebAuthnCeremony), errors.Is(err, services.ErrInvalidWebAuthnResponse):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process passkey request"})
+	}
+}
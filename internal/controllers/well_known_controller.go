@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// WellKnownController serves public discovery documents under /.well-known
+type WellKnownController struct {
+	keyManager   *services.KeyManager
+	oauthService *services.OAuthService
+}
+
+// NewWellKnownController creates a new WellKnownController instance
+func NewWellKnownController() *WellKnownController {
+	return &WellKnownController{
+		keyManager:   services.GetKeyManager(),
+		oauthService: services.NewOAuthService(),
+	}
+}
+
+// JWKS handles GET /.well-known/jwks.json
+// It publishes the public keys that downstream services use to verify our tokens.
+func (wc *WellKnownController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, wc.keyManager.JWKS())
+}
+
+// OpenIDConfiguration handles GET /.well-known/openid-configuration
+// It publishes the OpenID Provider metadata for clients signing users in through us.
+func (wc *WellKnownController) OpenIDConfiguration(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, wc.oauthService.Discovery())
+}
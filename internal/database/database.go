@@ -41,9 +41,88 @@ func ConnectDatabase() {
 	DB = db
 	fmt.Println("✅ Connected to the database!")
 
+	// Runs before AutoMigrate, which would add the column without backfilling it
+	if err := migrateEmailVerification(db); err != nil {
+		log.Fatal("Failed to migrate email verification: ", err)
+	}
+
 	// Auto migrate models
-	err = db.AutoMigrate(&models.User{})
+	err = db.AutoMigrate(
+		&models.User{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.UserTokenRevocation{},
+		&models.SigningKey{},
+		&models.PasswordResetToken{},
+		&models.TOTPCredential{},
+		&models.RecoveryCode{},
+		&models.WebAuthnCredential{},
+		&models.UserIdentity{},
+		&models.OIDCAuthRequest{},
+		&models.OAuthClient{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthConsent{},
+		&models.PersonalAccessToken{},
+		&models.Permission{},
+		&models.Role{},
+		&models.UserRole{},
+		&models.UserStatusTransition{},
+		&models.DataExport{},
+		&models.AuditEvent{},
+		&models.Session{},
+		&models.LoginAttempt{},
+	)
 	if err != nil {
 		log.Fatal("Failed to automigrate: ", err)
 	}
+
+	if err := migrateAppleIdentities(db); err != nil {
+		log.Fatal("Failed to migrate Apple identities: ", err)
+	}
+}
+
+// migrateEmailVerification adds users.email_verified_at to existing databases and marks the
+// users that signed up before email verification existed as verified, so the verification
+// policy doesn't lock them out. It is a no-op once the column exists.
+func migrateEmailVerification(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.User{}) || db.Migrator().HasColumn(&models.User{}, "email_verified_at") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&models.User{}, "EmailVerifiedAt"); err != nil {
+			return err
+		}
+
+		// created_at is only added by AutoMigrate, so databases older than it use the migration time
+		verifiedAt := "CURRENT_TIMESTAMP"
+		if tx.Migrator().HasColumn(&models.User{}, "created_at") {
+			verifiedAt = "COALESCE(created_at, CURRENT_TIMESTAMP)"
+		}
+		return tx.Exec("UPDATE users SET email_verified_at = " + verifiedAt).Error
+	})
+}
+
+// migrateAppleIdentities moves the legacy users.apple_id/apple_email columns into
+// user_identities and drops them. It is a no-op once the columns are gone.
+func migrateAppleIdentities(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.User{}, "apple_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO user_identities (user_id, provider, subject, email, email_verified, raw_claims, created_at, updated_at)
+			SELECT id, 'apple', apple_id, COALESCE(apple_email, ''), email_verified_at IS NOT NULL, '{}', NOW(), NOW()
+			FROM users
+			WHERE apple_id IS NOT NULL
+			ON CONFLICT (provider, subject) DO NOTHING`).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn(&models.User{}, "apple_email"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&models.User{}, "apple_id")
+	})
 }
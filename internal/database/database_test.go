@@ -0,0 +1,128 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// baselineUser is the users table as it was before email verification and created_at existed
+type baselineUser struct {
+	ID           uint    `gorm:"primaryKey"`
+	Email        string  `gorm:"uniqueIndex;not null"`
+	PasswordHash *string `gorm:""`
+	Username     string  `gorm:"unique;not null"`
+	AvatarURL    string  `gorm:""`
+	AppleID      *string `gorm:"uniqueIndex"`
+	AppleEmail   *string `gorm:""`
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	return db
+}
+
+func countUnverified(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+
+	var unverified int64
+	if err := db.Table("users").Where("email_verified_at IS NULL").Count(&unverified).Error; err != nil {
+		t.Fatalf("count unverified users: %v", err)
+	}
+	return unverified
+}
+
+func TestMigrateEmailVerificationBaselineSchema(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&baselineUser{}); err != nil {
+		t.Fatalf("create baseline schema: %v", err)
+	}
+	users := []baselineUser{{Email: "a@example.com", Username: "a"}, {Email: "b@example.com", Username: "b"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatalf("create users: %v", err)
+	}
+
+	if err := migrateEmailVerification(db); err != nil {
+		t.Fatalf("migrateEmailVerification error: %v", err)
+	}
+	if !db.Migrator().HasColumn(&models.User{}, "email_verified_at") {
+		t.Fatal("email_verified_at was not added")
+	}
+	if unverified := countUnverified(t, db); unverified != 0 {
+		t.Errorf("%d existing users left unverified", unverified)
+	}
+}
+
+func TestMigrateEmailVerificationUsesCreatedAt(t *testing.T) {
+	type userWithCreatedAt struct {
+		ID        uint   `gorm:"primaryKey"`
+		Email     string `gorm:"uniqueIndex;not null"`
+		Username  string `gorm:"unique;not null"`
+		CreatedAt time.Time
+	}
+
+	db := openTestDB(t)
+	if err := db.Table("users").AutoMigrate(&userWithCreatedAt{}); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	user := userWithCreatedAt{Email: "a@example.com", Username: "a", CreatedAt: createdAt}
+	if err := db.Table("users").Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	if err := migrateEmailVerification(db); err != nil {
+		t.Fatalf("migrateEmailVerification error: %v", err)
+	}
+
+	var verifiedAt time.Time
+	if err := db.Table("users").Select("email_verified_at").Where("id = ?", user.ID).Scan(&verifiedAt).Error; err != nil {
+		t.Fatalf("read email_verified_at: %v", err)
+	}
+	if !verifiedAt.Equal(createdAt) {
+		t.Errorf("email_verified_at = %v, want %v", verifiedAt, createdAt)
+	}
+}
+
+func TestMigrateEmailVerificationSkipsMigratedDatabases(t *testing.T) {
+	db := openTestDB(t)
+
+	// A fresh database has no users table yet: AutoMigrate creates it later
+	if err := migrateEmailVerification(db); err != nil {
+		t.Fatalf("fresh database: %v", err)
+	}
+
+	type userWithVerification struct {
+		ID              uint   `gorm:"primaryKey"`
+		Email           string `gorm:"uniqueIndex;not null"`
+		Username        string `gorm:"unique;not null"`
+		EmailVerifiedAt *time.Time
+	}
+	if err := db.Table("users").AutoMigrate(&userWithVerification{}); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	unverified := userWithVerification{Email: "a@example.com", Username: "a"}
+	if err := db.Table("users").Create(&unverified).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	if err := migrateEmailVerification(db); err != nil {
+		t.Fatalf("migrateEmailVerification error: %v", err)
+	}
+	if got := countUnverified(t, db); got != 1 {
+		t.Errorf("%d unverified users, want 1: users who signed up since verification existed must stay unverified", got)
+	}
+}
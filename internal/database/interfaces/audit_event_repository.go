@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// AuditEventFilter narrows down audit event listings. Zero values don't filter.
+type AuditEventFilter struct {
+	Actions      []string
+	ActorID      *uint
+	TargetUserID *uint
+	UserID       *uint // Matches events where the user is either the actor or the target
+	IP           string
+	RequestID    string
+	From         *time.Time // Inclusive
+	To           *time.Time // Exclusive
+}
+
+// AuditEventRepository defines the interface for audit event database operations.
+// Events can only be appended and read.
+type AuditEventRepository interface {
+	// Append events in a single batch
+	CreateBatch(events []models.AuditEvent) error
+
+	// List up to limit events matching the filter, newest first, with IDs below beforeID (0 for the first page)
+	List(ctx context.Context, filter AuditEventFilter, beforeID uint, limit int) ([]models.AuditEvent, error)
+}
@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// DataExportRepository defines the interface for data export database operations
+type DataExportRepository interface {
+	// Create a new export
+	Create(export *models.DataExport) error
+
+	// Find an export of a user by ID
+	FindByID(id, userID uint) (*models.DataExport, error)
+
+	// Find an export by the hash of its download token
+	FindByDownloadTokenHash(hash string) (*models.DataExport, error)
+
+	// Find the exports of a user, newest first, without their archives
+	FindByUserID(userID uint) ([]models.DataExport, error)
+
+	// Update an export
+	Update(export *models.DataExport) error
+
+	// Replace the download token of an export
+	SetDownloadToken(id uint, hash string, expiresAt time.Time) error
+
+	// Delete exports that expired before the given time
+	DeleteExpired(before time.Time) error
+}
@@ -0,0 +1,37 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// IdentityRepository defines the interface for federated identity database operations
+type IdentityRepository interface {
+	// Create a new identity for an existing user
+	Create(identity *models.UserIdentity) error
+
+	// Create a new user together with its first identity
+	CreateWithUser(user *models.User, identity *models.UserIdentity) error
+
+	// Find an identity by provider and subject
+	FindByProviderSubject(provider, subject string) (*models.UserIdentity, error)
+
+	// Find all identities of a user
+	FindByUserID(userID uint) ([]models.UserIdentity, error)
+
+	// Update an identity
+	Update(identity *models.UserIdentity) error
+
+	// Delete an identity belonging to a user; returns false if there was none
+	Delete(id, userID uint) (bool, error)
+
+	// Store a pending authorization request
+	CreateAuthRequest(request *models.OIDCAuthRequest) error
+
+	// Atomically load and delete an unexpired authorization request by state hash
+	ConsumeAuthRequest(stateHash string) (*models.OIDCAuthRequest, error)
+
+	// Delete authorization requests that expired before the given time
+	DeleteExpiredAuthRequests(before time.Time) error
+}
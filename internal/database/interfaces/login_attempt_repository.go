@@ -0,0 +1,27 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// LoginAttemptRepository defines the interface for failed login counter database operations
+type LoginAttemptRepository interface {
+	// Find the counter of a key
+	Find(key string) (*models.LoginAttempt, error)
+
+	// Atomically count a failure at the given time, opening a new window if the last one expired.
+	// allow sees the counter as it was (zero for new keys) while it is locked; if it returns an
+	// error, nothing is recorded and the error is returned.
+	RecordFailure(key string, at time.Time, window time.Duration, allow func(current *models.LoginAttempt) error) (*models.LoginAttempt, error)
+
+	// Lock a key until the given time and start counting its failures again
+	Lock(key string, until time.Time) error
+
+	// Clear the failures and lock of a key
+	Reset(key string) error
+
+	// Delete the counters whose window and lock have both ended
+	DeleteExpired(now time.Time) error
+}
@@ -0,0 +1,32 @@
+package interfaces
+
+// LoginMethodRemoval is the outcome of removing one of a user's login methods
+type LoginMethodRemoval int
+
+const (
+	// LoginMethodRemoved means the method was removed
+	LoginMethodRemoved LoginMethodRemoval = iota
+
+	// LoginMethodNotFound means the user has no such method
+	LoginMethodNotFound
+
+	// LoginMethodLast means the method is the user's only way to sign in and was kept
+	LoginMethodLast
+
+	// LoginMethodUserNotFound means the user doesn't exist
+	LoginMethodUserNotFound
+)
+
+// LoginMethodRepository defines the interface for removing login methods (passwords, identities
+// and passkeys). Removals lock the user's row while counting the remaining methods, so
+// concurrent removals can't leave an account without a way to sign in.
+type LoginMethodRepository interface {
+	// Delete an identity of a user unless it is their last login method
+	DeleteIdentity(userID, identityID uint) (LoginMethodRemoval, error)
+
+	// Delete a WebAuthn credential of a user unless it is their last login method
+	DeletePasskey(userID, credentialID uint) (LoginMethodRemoval, error)
+
+	// Clear the password of a user unless it is their last login method
+	RemovePassword(userID uint) (LoginMethodRemoval, error)
+}
@@ -0,0 +1,34 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// MFARepository defines the interface for two-factor authentication database operations
+type MFARepository interface {
+	// Find the TOTP enrollment of a user
+	FindTOTPByUserID(userID uint) (*models.TOTPCredential, error)
+
+	// Create or replace the TOTP enrollment of a user
+	SaveTOTP(credential *models.TOTPCredential) error
+
+	// Record the time step of an accepted code; returns false if that step (or a later one) was already used
+	UpdateTOTPLastUsedStep(id uint, step int64) (bool, error)
+
+	// Mark a pending enrollment as confirmed without touching its other columns
+	ConfirmTOTP(id uint, confirmedAt time.Time) error
+
+	// Delete the TOTP enrollment and recovery codes of a user
+	DeleteTOTP(userID uint) error
+
+	// Replace all recovery codes of a user
+	ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error
+
+	// Find the unused recovery codes of a user
+	FindUnusedRecoveryCodes(userID uint) ([]models.RecoveryCode, error)
+
+	// Mark a recovery code as used; returns false if it was already used
+	MarkRecoveryCodeUsed(id uint) (bool, error)
+}
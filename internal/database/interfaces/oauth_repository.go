@@ -0,0 +1,40 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// OAuthRepository defines the interface for OAuth provider database operations
+type OAuthRepository interface {
+	// Create a new client
+	CreateClient(client *models.OAuthClient) error
+
+	// Find a client by its client ID
+	FindClientByClientID(clientID string) (*models.OAuthClient, error)
+
+	// Create a new authorization code
+	CreateAuthorizationCode(code *models.OAuthAuthorizationCode) error
+
+	// Find an authorization code by its hash
+	FindAuthorizationCodeByHash(codeHash string) (*models.OAuthAuthorizationCode, error)
+
+	// Atomically mark an authorization code as used; returns false if it already was
+	MarkAuthorizationCodeUsed(id uint) (bool, error)
+
+	// Remember the refresh token family issued for an authorization code
+	SetAuthorizationCodeFamily(id uint, familyID string) error
+
+	// Delete authorization codes that expired before the given time
+	DeleteExpiredAuthorizationCodes(before time.Time) error
+
+	// Find the consent a user gave a client
+	FindConsent(userID uint, clientID string) (*models.OAuthConsent, error)
+
+	// Find the consents a user gave to any client
+	FindConsentsByUserID(userID uint) ([]models.OAuthConsent, error)
+
+	// Create or update the consent a user gave a client
+	SaveConsent(consent *models.OAuthConsent) error
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// PasswordResetTokenRepository defines the interface for password reset token database operations
+type PasswordResetTokenRepository interface {
+	// Create a new reset token
+	Create(token *models.PasswordResetToken) error
+
+	// Find a reset token by the hash of its value
+	FindByHash(hash string) (*models.PasswordResetToken, error)
+
+	// Mark a token as used; returns false if it was already used
+	MarkUsed(id uint) (bool, error)
+
+	// Invalidate every unused token of a user
+	InvalidateForUser(userID uint) error
+}
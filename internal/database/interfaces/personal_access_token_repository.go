@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// PersonalAccessTokenRepository defines the interface for personal access token database operations
+type PersonalAccessTokenRepository interface {
+	// Create a new token
+	Create(token *models.PersonalAccessToken) error
+
+	// Find a token by the hash of its value
+	FindByHash(hash string) (*models.PersonalAccessToken, error)
+
+	// Find all tokens of a user
+	FindByUserID(userID uint) ([]models.PersonalAccessToken, error)
+
+	// Record that a token was used at the given time
+	UpdateLastUsed(id uint, usedAt time.Time) error
+
+	// Delete a token belonging to a user; returns false if there was none
+	Delete(id, userID uint) (bool, error)
+}
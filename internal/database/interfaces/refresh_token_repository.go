@@ -0,0 +1,26 @@
+package interfaces
+
+import (
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// RefreshTokenRepository defines the interface for refresh token database operations
+type RefreshTokenRepository interface {
+	// Create a new refresh token
+	Create(token *models.RefreshToken) error
+
+	// Find a refresh token by the hash of its value
+	FindByHash(hash string) (*models.RefreshToken, error)
+
+	// Find every token of a user, oldest first
+	FindByUserID(userID uint) ([]models.RefreshToken, error)
+
+	// Mark a token as rotated; returns false if it was already rotated or revoked
+	MarkRotated(id uint) (bool, error)
+
+	// Revoke every token in a family and end its session
+	RevokeFamily(familyID string) error
+
+	// Revoke every token belonging to a user and end their sessions
+	RevokeAllForUser(userID uint) error
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// RevocationRepository defines the interface for access token revocation database operations
+type RevocationRepository interface {
+	// Record a revoked token
+	RevokeToken(token *models.RevokedToken) error
+
+	// Check if a token ID has been revoked
+	IsTokenRevoked(jti string) (bool, error)
+
+	// Revoke all tokens of a user issued before the given time
+	RevokeUserTokensBefore(userID uint, before time.Time) error
+
+	// Find the user-wide revocation, if any
+	FindUserRevocation(userID uint) (*models.UserTokenRevocation, error)
+
+	// Delete revoked token records that have expired
+	DeleteExpired() error
+}
@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// RoleUnassignment is the outcome of removing a role that must keep at least one holder
+type RoleUnassignment int
+
+const (
+	// RoleUnassigned means the role was removed from the user
+	RoleUnassigned RoleUnassignment = iota
+
+	// RoleNotAssigned means the user doesn't have the role
+	RoleNotAssigned
+
+	// RoleLastHolder means the user is the role's only holder and kept it
+	RoleLastHolder
+)
+
+// RoleRepository defines the interface for role and permission database operations
+type RoleRepository interface {
+	// Create or update a role by name and set its permissions, creating missing permissions
+	UpsertRole(role *models.Role, permissions []models.Permission) error
+
+	// Find all roles with their permissions
+	FindAll() ([]models.Role, error)
+
+	// Find a role with its permissions by name
+	FindByName(name string) (*models.Role, error)
+
+	// Find the roles, with their permissions, assigned to a user
+	FindByUserID(userID uint) ([]models.Role, error)
+
+	// Assign a role to a user; assigning a role twice is not an error
+	Assign(assignment *models.UserRole) error
+
+	// Remove a role from a user; returns false if it wasn't assigned
+	Unassign(userID, roleID uint) (bool, error)
+
+	// Remove a role from a user unless they are its last holder. The role's assignments are
+	// locked while they are counted, so concurrent removals can't leave it without a holder.
+	UnassignUnlessLast(userID, roleID uint) (RoleUnassignment, error)
+}
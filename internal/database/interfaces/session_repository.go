@@ -0,0 +1,27 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// SessionRepository defines the interface for session database operations.
+// Sessions are ended together with their refresh token family by RefreshTokenRepository.
+type SessionRepository interface {
+	// Create a new session
+	Create(session *models.Session) error
+
+	// Find a session by ID
+	FindByID(id uint) (*models.Session, error)
+
+	// Find the session of a refresh token family
+	FindByFamilyID(familyID string) (*models.Session, error)
+
+	// Find the sessions of a user, most recently seen first.
+	// Revoked and expired sessions are only included if activeOnly is false.
+	FindByUserID(userID uint, activeOnly bool, limit int) ([]models.Session, error)
+
+	// Record that a session was refreshed at the given time and now expires at expiresAt
+	Touch(id uint, seenAt, expiresAt time.Time) error
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// SigningKeyRepository defines the interface for token signing key database operations
+type SigningKeyRepository interface {
+	// Create a new signing key
+	Create(key *models.SigningKey) error
+
+	// Find all keys that have not expired, newest first
+	FindUnexpired() ([]models.SigningKey, error)
+
+	// Mark every other unrotated key as rotated, keeping it verifiable until expiresAt
+	RotateAllExcept(kid string, expiresAt time.Time) error
+
+	// Replace the stored private key of a key
+	UpdatePrivateKey(id uint, privateKey string) error
+
+	// Delete keys that have expired
+	DeleteExpired() error
+}
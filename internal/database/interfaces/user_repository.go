@@ -1,9 +1,38 @@
 package interfaces
 
 import (
+	"context"
+	"time"
+
 	"github.com/danigrb.dev/user-service/internal/models"
 )
 
+// UserFilter narrows down user listings. Zero values don't filter.
+type UserFilter struct {
+	Query          string // Matches the start of the email or username, case-insensitively
+	EmailPrefix    string // Matches the start of the email, case-insensitively
+	UsernamePrefix string // Matches the start of the username, case-insensitively
+	CreatedFrom    *time.Time
+	CreatedTo      *time.Time // Exclusive
+	Status         models.UserStatus
+	Role           string // Name of a role the user must have
+	EmailVerified  *bool
+}
+
+// UserSort orders user listings. The ID always breaks ties so the order is stable.
+type UserSort struct {
+	Field      string // One of "created_at", "id", "email" or "username"; defaults to "created_at"
+	Descending bool
+}
+
+// UserCursor positions a keyset-paginated listing after the last user of the previous
+// page. The zero cursor (LastID 0) starts at the beginning.
+type UserCursor struct {
+	Sort      UserSort
+	LastValue string // Sort column value of the last user; RFC 3339 for created_at
+	LastID    uint
+}
+
 // UserRepository defines the interface for user database operations
 type UserRepository interface {
 	// Create a new user
@@ -18,15 +47,31 @@ type UserRepository interface {
 	// Find a user by username
 	FindByUsername(username string) (*models.User, error)
 
-	// Find a user by Apple ID
-	FindByAppleID(appleID string) (*models.User, error)
+	// Find a user by a linked identity at an external provider
+	FindByIdentity(provider, subject string) (*models.User, error)
+
+	// List up to limit users matching the filter, in cursor order, after the cursor position
+	List(ctx context.Context, filter UserFilter, cursor UserCursor, limit int) ([]models.User, error)
 
 	// Update a user
 	Update(user *models.User) error
 
+	// Change the status of a user and record the transition, atomically
+	UpdateStatus(user *models.User, transition *models.UserStatusTransition) error
+
+	// Find the status transitions of a user, oldest first
+	FindStatusTransitions(userID uint) ([]models.UserStatusTransition, error)
+
 	// Delete a user
 	Delete(id uint) error
 
+	// Find the IDs of up to limit deleted users whose purge time is before the given time
+	FindPurgeable(before time.Time, limit int) ([]uint, error)
+
+	// Permanently erase a deleted user whose purge time has passed and every record linked
+	// to them, atomically
+	Purge(id uint) error
+
 	// Check if email exists
 	EmailExists(email string) (bool, error)
 
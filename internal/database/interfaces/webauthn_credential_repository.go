@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// WebAuthnCredentialRepository defines the interface for WebAuthn credential database operations
+type WebAuthnCredentialRepository interface {
+	// Create a new credential
+	Create(credential *models.WebAuthnCredential) error
+
+	// Find a credential by its base64url encoded credential ID
+	FindByCredentialID(credentialID string) (*models.WebAuthnCredential, error)
+
+	// Find all credentials of a user
+	FindByUserID(userID uint) ([]models.WebAuthnCredential, error)
+
+	// Record a successful assertion; returns false if the stored sign count changed concurrently
+	UpdateSignCount(id uint, previous, current uint32, backupState bool) (bool, error)
+
+	// Delete a credential belonging to a user; returns false if there was none
+	Delete(id, userID uint) (bool, error)
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure AuditEventRepository implements interfaces.AuditEventRepository
+var _ interfaces.AuditEventRepository = (*AuditEventRepository)(nil)
+
+// AuditEventRepository implements the interfaces.AuditEventRepository interface
+// using PostgreSQL as the database
+type AuditEventRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditEventRepository creates a new AuditEventRepository instance
+func NewAuditEventRepository() *AuditEventRepository {
+	return &AuditEventRepository{
+		db: database.DB,
+	}
+}
+
+// CreateBatch appends events in a single insert
+func (r *AuditEventRepository) CreateBatch(events []models.AuditEvent) error {
+	return r.db.Create(&events).Error
+}
+
+// List lists events matching the filter, newest first, with keyset pagination over id
+func (r *AuditEventRepository) List(ctx context.Context, filter interfaces.AuditEventFilter, beforeID uint, limit int) ([]models.AuditEvent, error) {
+	query := r.db.WithContext(ctx).Model(&models.AuditEvent{})
+	if len(filter.Actions) > 0 {
+		query = query.Where("action IN ?", filter.Actions)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.TargetUserID != nil {
+		query = query.Where("target_user_id = ?", *filter.TargetUserID)
+	}
+	if filter.UserID != nil {
+		query = query.Where("(actor_id = ? OR target_user_id = ?)", *filter.UserID, *filter.UserID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if beforeID != 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+
+	var events []models.AuditEvent
+	err := query.Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure DataExportRepository implements interfaces.DataExportRepository
+var _ interfaces.DataExportRepository = (*DataExportRepository)(nil)
+
+// DataExportRepository implements the interfaces.DataExportRepository interface
+// using PostgreSQL as the database
+type DataExportRepository struct {
+	db *gorm.DB
+}
+
+// NewDataExportRepository creates a new DataExportRepository instance
+func NewDataExportRepository() *DataExportRepository {
+	return &DataExportRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new export in the database
+func (r *DataExportRepository) Create(export *models.DataExport) error {
+	return r.db.Create(export).Error
+}
+
+// FindByID finds an export of a user by ID
+func (r *DataExportRepository) FindByID(id, userID uint) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.Omit("archive").Where("id = ? AND user_id = ?", id, userID).First(&export).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Export not found, but no error
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// FindByDownloadTokenHash finds an export, including its archive, by the hash of its download token
+func (r *DataExportRepository) FindByDownloadTokenHash(hash string) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.Where("download_token_hash = ?", hash).First(&export).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Export not found, but no error
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// FindByUserID finds the exports of a user without loading their archives
+func (r *DataExportRepository) FindByUserID(userID uint) ([]models.DataExport, error) {
+	var exports []models.DataExport
+	err := r.db.Omit("archive").Where("user_id = ?", userID).Order("created_at DESC").Find(&exports).Error
+	return exports, err
+}
+
+// Update updates an export in the database
+func (r *DataExportRepository) Update(export *models.DataExport) error {
+	return r.db.Save(export).Error
+}
+
+// SetDownloadToken replaces the download token of an export
+func (r *DataExportRepository) SetDownloadToken(id uint, hash string, expiresAt time.Time) error {
+	return r.db.Model(&models.DataExport{}).Where("id = ?", id).Updates(map[string]any{
+		"download_token_hash": hash,
+		"download_expires_at": expiresAt,
+	}).Error
+}
+
+// DeleteExpired deletes exports that expired before the given time
+func (r *DataExportRepository) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&models.DataExport{}).Error
+}
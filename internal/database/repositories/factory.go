@@ -9,6 +9,51 @@ import (
 var (
 	userRepositoryInstance interfaces.UserRepository
 	userRepositoryOnce     sync.Once
+
+	refreshTokenRepositoryInstance interfaces.RefreshTokenRepository
+	refreshTokenRepositoryOnce     sync.Once
+
+	revocationRepositoryInstance interfaces.RevocationRepository
+	revocationRepositoryOnce     sync.Once
+
+	signingKeyRepositoryInstance interfaces.SigningKeyRepository
+	signingKeyRepositoryOnce     sync.Once
+
+	passwordResetTokenRepositoryInstance interfaces.PasswordResetTokenRepository
+	passwordResetTokenRepositoryOnce     sync.Once
+
+	mfaRepositoryInstance interfaces.MFARepository
+	mfaRepositoryOnce     sync.Once
+
+	webAuthnCredentialRepositoryInstance interfaces.WebAuthnCredentialRepository
+	webAuthnCredentialRepositoryOnce     sync.Once
+
+	identityRepositoryInstance interfaces.IdentityRepository
+	identityRepositoryOnce     sync.Once
+
+	oauthRepositoryInstance interfaces.OAuthRepository
+	oauthRepositoryOnce     sync.Once
+
+	personalAccessTokenRepositoryInstance interfaces.PersonalAccessTokenRepository
+	personalAccessTokenRepositoryOnce     sync.Once
+
+	roleRepositoryInstance interfaces.RoleRepository
+	roleRepositoryOnce     sync.Once
+
+	dataExportRepositoryInstance interfaces.DataExportRepository
+	dataExportRepositoryOnce     sync.Once
+
+	auditEventRepositoryInstance interfaces.AuditEventRepository
+	auditEventRepositoryOnce     sync.Once
+
+	sessionRepositoryInstance interfaces.SessionRepository
+	sessionRepositoryOnce     sync.Once
+
+	loginAttemptRepositoryInstance interfaces.LoginAttemptRepository
+	loginAttemptRepositoryOnce     sync.Once
+
+	loginMethodRepositoryInstance interfaces.LoginMethodRepository
+	loginMethodRepositoryOnce     sync.Once
 )
 
 // Factory provides a centralized way to get repository instances
@@ -36,3 +81,243 @@ func (f *Factory) SetUserRepository(repo interfaces.UserRepository) {
 	// Reset the once so GetUserRepository will use the new instance
 	userRepositoryOnce = sync.Once{}
 }
+
+// GetRefreshTokenRepository returns a RefreshTokenRepository instance
+func (f *Factory) GetRefreshTokenRepository() interfaces.RefreshTokenRepository {
+	refreshTokenRepositoryOnce.Do(func() {
+		refreshTokenRepositoryInstance = NewRefreshTokenRepository()
+	})
+	return refreshTokenRepositoryInstance
+}
+
+// SetRefreshTokenRepository allows setting a custom RefreshTokenRepository implementation
+func (f *Factory) SetRefreshTokenRepository(repo interfaces.RefreshTokenRepository) {
+	refreshTokenRepositoryOnce = sync.Once{}
+	refreshTokenRepositoryOnce.Do(func() {
+		refreshTokenRepositoryInstance = repo
+	})
+}
+
+// GetRevocationRepository returns a RevocationRepository instance
+func (f *Factory) GetRevocationRepository() interfaces.RevocationRepository {
+	revocationRepositoryOnce.Do(func() {
+		revocationRepositoryInstance = NewRevocationRepository()
+	})
+	return revocationRepositoryInstance
+}
+
+// SetRevocationRepository allows setting a custom RevocationRepository implementation
+func (f *Factory) SetRevocationRepository(repo interfaces.RevocationRepository) {
+	revocationRepositoryOnce = sync.Once{}
+	revocationRepositoryOnce.Do(func() {
+		revocationRepositoryInstance = repo
+	})
+}
+
+// GetSigningKeyRepository returns a SigningKeyRepository instance
+func (f *Factory) GetSigningKeyRepository() interfaces.SigningKeyRepository {
+	signingKeyRepositoryOnce.Do(func() {
+		signingKeyRepositoryInstance = NewSigningKeyRepository()
+	})
+	return signingKeyRepositoryInstance
+}
+
+// SetSigningKeyRepository allows setting a custom SigningKeyRepository implementation
+func (f *Factory) SetSigningKeyRepository(repo interfaces.SigningKeyRepository) {
+	signingKeyRepositoryOnce = sync.Once{}
+	signingKeyRepositoryOnce.Do(func() {
+		signingKeyRepositoryInstance = repo
+	})
+}
+
+// GetPasswordResetTokenRepository returns a PasswordResetTokenRepository instance
+func (f *Factory) GetPasswordResetTokenRepository() interfaces.PasswordResetTokenRepository {
+	passwordResetTokenRepositoryOnce.Do(func() {
+		passwordResetTokenRepositoryInstance = NewPasswordResetTokenRepository()
+	})
+	return passwordResetTokenRepositoryInstance
+}
+
+// SetPasswordResetTokenRepository allows setting a custom PasswordResetTokenRepository implementation
+func (f *Factory) SetPasswordResetTokenRepository(repo interfaces.PasswordResetTokenRepository) {
+	passwordResetTokenRepositoryOnce = sync.Once{}
+	passwordResetTokenRepositoryOnce.Do(func() {
+		passwordResetTokenRepositoryInstance = repo
+	})
+}
+
+// GetMFARepository returns a MFARepository instance
+func (f *Factory) GetMFARepository() interfaces.MFARepository {
+	mfaRepositoryOnce.Do(func() {
+		mfaRepositoryInstance = NewMFARepository()
+	})
+	return mfaRepositoryInstance
+}
+
+// SetMFARepository allows setting a custom MFARepository implementation
+func (f *Factory) SetMFARepository(repo interfaces.MFARepository) {
+	mfaRepositoryOnce = sync.Once{}
+	mfaRepositoryOnce.Do(func() {
+		mfaRepositoryInstance = repo
+	})
+}
+
+// GetWebAuthnCredentialRepository returns a WebAuthnCredentialRepository instance
+func (f *Factory) GetWebAuthnCredentialRepository() interfaces.WebAuthnCredentialRepository {
+	webAuthnCredentialRepositoryOnce.Do(func() {
+		webAuthnCredentialRepositoryInstance = NewWebAuthnCredentialRepository()
+	})
+	return webAuthnCredentialRepositoryInstance
+}
+
+// SetWebAuthnCredentialRepository allows setting a custom WebAuthnCredentialRepository implementation
+func (f *Factory) SetWebAuthnCredentialRepository(repo interfaces.WebAuthnCredentialRepository) {
+	webAuthnCredentialRepositoryOnce = sync.Once{}
+	webAuthnCredentialRepositoryOnce.Do(func() {
+		webAuthnCredentialRepositoryInstance = repo
+	})
+}
+
+// GetIdentityRepository returns an IdentityRepository instance
+func (f *Factory) GetIdentityRepository() interfaces.IdentityRepository {
+	identityRepositoryOnce.Do(func() {
+		identityRepositoryInstance = NewIdentityRepository()
+	})
+	return identityRepositoryInstance
+}
+
+// SetIdentityRepository allows setting a custom IdentityRepository implementation
+func (f *Factory) SetIdentityRepository(repo interfaces.IdentityRepository) {
+	identityRepositoryOnce = sync.Once{}
+	identityRepositoryOnce.Do(func() {
+		identityRepositoryInstance = repo
+	})
+}
+
+// GetOAuthRepository returns an OAuthRepository instance
+func (f *Factory) GetOAuthRepository() interfaces.OAuthRepository {
+	oauthRepositoryOnce.Do(func() {
+		oauthRepositoryInstance = NewOAuthRepository()
+	})
+	return oauthRepositoryInstance
+}
+
+// SetOAuthRepository allows setting a custom OAuthRepository implementation
+func (f *Factory) SetOAuthRepository(repo interfaces.OAuthRepository) {
+	oauthRepositoryOnce = sync.Once{}
+	oauthRepositoryOnce.Do(func() {
+		oauthRepositoryInstance = repo
+	})
+}
+
+// GetPersonalAccessTokenRepository returns a PersonalAccessTokenRepository instance
+func (f *Factory) GetPersonalAccessTokenRepository() interfaces.PersonalAccessTokenRepository {
+	personalAccessTokenRepositoryOnce.Do(func() {
+		personalAccessTokenRepositoryInstance = NewPersonalAccessTokenRepository()
+	})
+	return personalAccessTokenRepositoryInstance
+}
+
+// SetPersonalAccessTokenRepository allows setting a custom PersonalAccessTokenRepository implementation
+func (f *Factory) SetPersonalAccessTokenRepository(repo interfaces.PersonalAccessTokenRepository) {
+	personalAccessTokenRepositoryOnce = sync.Once{}
+	personalAccessTokenRepositoryOnce.Do(func() {
+		personalAccessTokenRepositoryInstance = repo
+	})
+}
+
+// GetRoleRepository returns a RoleRepository instance
+func (f *Factory) GetRoleRepository() interfaces.RoleRepository {
+	roleRepositoryOnce.Do(func() {
+		roleRepositoryInstance = NewRoleRepository()
+	})
+	return roleRepositoryInstance
+}
+
+// SetRoleRepository allows setting a custom RoleRepository implementation
+func (f *Factory) SetRoleRepository(repo interfaces.RoleRepository) {
+	roleRepositoryOnce = sync.Once{}
+	roleRepositoryOnce.Do(func() {
+		roleRepositoryInstance = repo
+	})
+}
+
+// GetDataExportRepository returns a DataExportRepository instance
+func (f *Factory) GetDataExportRepository() interfaces.DataExportRepository {
+	dataExportRepositoryOnce.Do(func() {
+		dataExportRepositoryInstance = NewDataExportRepository()
+	})
+	return dataExportRepositoryInstance
+}
+
+// SetDataExportRepository allows setting a custom DataExportRepository implementation
+func (f *Factory) SetDataExportRepository(repo interfaces.DataExportRepository) {
+	dataExportRepositoryOnce = sync.Once{}
+	dataExportRepositoryOnce.Do(func() {
+		dataExportRepositoryInstance = repo
+	})
+}
+
+// GetAuditEventRepository returns an AuditEventRepository instance
+func (f *Factory) GetAuditEventRepository() interfaces.AuditEventRepository {
+	auditEventRepositoryOnce.Do(func() {
+		auditEventRepositoryInstance = NewAuditEventRepository()
+	})
+	return auditEventRepositoryInstance
+}
+
+// SetAuditEventRepository allows setting a custom AuditEventRepository implementation
+func (f *Factory) SetAuditEventRepository(repo interfaces.AuditEventRepository) {
+	auditEventRepositoryOnce = sync.Once{}
+	auditEventRepositoryOnce.Do(func() {
+		auditEventRepositoryInstance = repo
+	})
+}
+
+// GetSessionRepository returns a SessionRepository instance
+func (f *Factory) GetSessionRepository() interfaces.SessionRepository {
+	sessionRepositoryOnce.Do(func() {
+		sessionRepositoryInstance = NewSessionRepository()
+	})
+	return sessionRepositoryInstance
+}
+
+// SetSessionRepository allows setting a custom SessionRepository implementation
+func (f *Factory) SetSessionRepository(repo interfaces.SessionRepository) {
+	sessionRepositoryOnce = sync.Once{}
+	sessionRepositoryOnce.Do(func() {
+		sessionRepositoryInstance = repo
+	})
+}
+
+// GetLoginAttemptRepository returns a LoginAttemptRepository instance
+func (f *Factory) GetLoginAttemptRepository() interfaces.LoginAttemptRepository {
+	loginAttemptRepositoryOnce.Do(func() {
+		loginAttemptRepositoryInstance = NewLoginAttemptRepository()
+	})
+	return loginAttemptRepositoryInstance
+}
+
+// SetLoginAttemptRepository allows setting a custom LoginAttemptRepository implementation
+func (f *Factory) SetLoginAttemptRepository(repo interfaces.LoginAttemptRepository) {
+	loginAttemptRepositoryOnce = sync.Once{}
+	loginAttemptRepositoryOnce.Do(func() {
+		loginAttemptRepositoryInstance = repo
+	})
+}
+
+// GetLoginMethodRepository returns a LoginMethodRepository instance
+func (f *Factory) GetLoginMethodRepository() interfaces.LoginMethodRepository {
+	loginMethodRepositoryOnce.Do(func() {
+		loginMethodRepositoryInstance = NewLoginMethodRepository()
+	})
+	return loginMethodRepositoryInstance
+}
+
+// SetLoginMethodRepository allows setting a custom LoginMethodRepository implementation
+func (f *Factory) SetLoginMethodRepository(repo interfaces.LoginMethodRepository) {
+	loginMethodRepositoryOnce = sync.Once{}
+	loginMethodRepositoryOnce.Do(func() {
+		loginMethodRepositoryInstance = repo
+	})
+}
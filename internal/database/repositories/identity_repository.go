@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ensure IdentityRepository implements interfaces.IdentityRepository
+var _ interfaces.IdentityRepository = (*IdentityRepository)(nil)
+
+// IdentityRepository implements the interfaces.IdentityRepository interface
+// using PostgreSQL as the database
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewIdentityRepository creates a new IdentityRepository instance
+func NewIdentityRepository() *IdentityRepository {
+	return &IdentityRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new identity in the database
+func (r *IdentityRepository) Create(identity *models.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateWithUser creates a user and its first identity in one transaction
+func (r *IdentityRepository) CreateWithUser(user *models.User, identity *models.UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// FindByProviderSubject finds an identity by provider and subject
+func (r *IdentityRepository) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Identity not found, but no error
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// FindByUserID finds all identities of a user
+func (r *IdentityRepository) FindByUserID(userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// Update updates an identity in the database
+func (r *IdentityRepository) Update(identity *models.UserIdentity) error {
+	return r.db.Save(identity).Error
+}
+
+// Delete deletes an identity belonging to a user
+func (r *IdentityRepository) Delete(id, userID uint) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.UserIdentity{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// CreateAuthRequest stores a pending authorization request
+func (r *IdentityRepository) CreateAuthRequest(request *models.OIDCAuthRequest) error {
+	return r.db.Create(request).Error
+}
+
+// ConsumeAuthRequest deletes an unexpired authorization request and returns it,
+// so concurrent callbacks with the same state can't both succeed
+func (r *IdentityRepository) ConsumeAuthRequest(stateHash string) (*models.OIDCAuthRequest, error) {
+	var requests []models.OIDCAuthRequest
+	err := r.db.Clauses(clause.Returning{}).
+		Where("state_hash = ? AND expires_at > ?", stateHash, time.Now()).
+		Delete(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, nil
+	}
+	return &requests[0], nil
+}
+
+// DeleteExpiredAuthRequests deletes authorization requests that expired before the given time
+func (r *IdentityRepository) DeleteExpiredAuthRequests(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&models.OIDCAuthRequest{}).Error
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ensure LoginAttemptRepository implements interfaces.LoginAttemptRepository
+var _ interfaces.LoginAttemptRepository = (*LoginAttemptRepository)(nil)
+
+// LoginAttemptRepository implements the interfaces.LoginAttemptRepository interface
+// using PostgreSQL as the database, which lets every replica share the counters
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginAttemptRepository creates a new LoginAttemptRepository instance
+func NewLoginAttemptRepository() *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		db: database.DB,
+	}
+}
+
+// Find finds the counter of a key
+func (r *LoginAttemptRepository) Find(key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.db.Where("key = ?", key).First(&attempt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No failures, but no error
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// RecordFailure counts a failure while holding a lock on the counter row, so concurrent
+// requests see each other's failures before they are allowed
+func (r *LoginAttemptRepository) RecordFailure(key string, at time.Time, window time.Duration, allow func(current *models.LoginAttempt) error) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// New keys start with an empty counter whose window has already ended
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginAttempt{Key: key, LastFailureAt: at, WindowExpiresAt: at}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&attempt).Error
+		if err != nil {
+			return err
+		}
+
+		current := attempt
+		if err := allow(&current); err != nil {
+			return err
+		}
+
+		if attempt.WindowExpiresAt.After(at) {
+			attempt.Failures++
+		} else {
+			attempt.Failures = 1
+			attempt.WindowExpiresAt = at.Add(window)
+		}
+		attempt.LastFailureAt = at
+		return tx.Save(&attempt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// Lock locks a key and resets its failures
+func (r *LoginAttemptRepository) Lock(key string, until time.Time) error {
+	return r.db.Model(&models.LoginAttempt{}).Where("key = ?", key).Updates(map[string]any{
+		"failures":     0,
+		"locked_until": until,
+	}).Error
+}
+
+// Reset deletes the counter of a key
+func (r *LoginAttemptRepository) Reset(key string) error {
+	return r.db.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+// DeleteExpired deletes the counters that no longer count failures or lock anything
+func (r *LoginAttemptRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("window_expires_at < ? AND (locked_until IS NULL OR locked_until < ?)", now, now).
+		Delete(&models.LoginAttempt{}).Error
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ensure LoginMethodRepository implements interfaces.LoginMethodRepository
+var _ interfaces.LoginMethodRepository = (*LoginMethodRepository)(nil)
+
+// errKeepLastLoginMethod rolls back the removal of a user's last login method
+var errKeepLastLoginMethod = errors.New("last login method")
+
+// LoginMethodRepository implements the interfaces.LoginMethodRepository interface
+// using PostgreSQL as the database
+type LoginMethodRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginMethodRepository creates a new LoginMethodRepository instance
+func NewLoginMethodRepository() *LoginMethodRepository {
+	return &LoginMethodRepository{
+		db: database.DB,
+	}
+}
+
+// DeleteIdentity deletes an identity of a user unless it is their last login method
+func (r *LoginMethodRepository) DeleteIdentity(userID, identityID uint) (interfaces.LoginMethodRemoval, error) {
+	return r.remove(userID, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("id = ? AND user_id = ?", identityID, userID).Delete(&models.UserIdentity{})
+	})
+}
+
+// DeletePasskey deletes a WebAuthn credential of a user unless it is their last login method
+func (r *LoginMethodRepository) DeletePasskey(userID, credentialID uint) (interfaces.LoginMethodRemoval, error) {
+	return r.remove(userID, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("id = ? AND user_id = ?", credentialID, userID).Delete(&models.WebAuthnCredential{})
+	})
+}
+
+// RemovePassword clears the password of a user unless it is their last login method
+func (r *LoginMethodRepository) RemovePassword(userID uint) (interfaces.LoginMethodRemoval, error) {
+	return r.remove(userID, func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&models.User{}).Where("id = ? AND password_hash IS NOT NULL", userID).Update("password_hash", nil)
+	})
+}
+
+// remove locks the user's row, counts their login methods and runs the removal, rolling it
+// back if it took away the last one. Every removal takes the same lock, so they run one at a time.
+func (r *LoginMethodRepository) remove(userID uint, removal func(tx *gorm.DB) *gorm.DB) (interfaces.LoginMethodRemoval, error) {
+	outcome := interfaces.LoginMethodRemoved
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "password_hash").First(&user, userID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				outcome = interfaces.LoginMethodUserNotFound
+				return nil
+			}
+			return err
+		}
+
+		methods, err := countLoginMethods(tx, &user)
+		if err != nil {
+			return err
+		}
+
+		result := removal(tx)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			outcome = interfaces.LoginMethodNotFound
+			return nil
+		}
+		if methods <= 1 {
+			return errKeepLastLoginMethod
+		}
+		return nil
+	})
+	if errors.Is(err, errKeepLastLoginMethod) {
+		return interfaces.LoginMethodLast, nil
+	}
+	return outcome, err
+}
+
+// countLoginMethods counts the password, identities and passkeys of a user
+func countLoginMethods(tx *gorm.DB, user *models.User) (int64, error) {
+	var identities, passkeys int64
+	if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&identities).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.WebAuthnCredential{}).Where("user_id = ?", user.ID).Count(&passkeys).Error; err != nil {
+		return 0, err
+	}
+
+	methods := identities + passkeys
+	if user.PasswordHash != nil {
+		methods++
+	}
+	return methods, nil
+}
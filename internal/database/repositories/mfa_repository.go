@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure MFARepository implements interfaces.MFARepository
+var _ interfaces.MFARepository = (*MFARepository)(nil)
+
+// MFARepository implements the interfaces.MFARepository interface
+// using PostgreSQL as the database
+type MFARepository struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new MFARepository instance
+func NewMFARepository() *MFARepository {
+	return &MFARepository{
+		db: database.DB,
+	}
+}
+
+// FindTOTPByUserID finds the TOTP enrollment of a user
+func (r *MFARepository) FindTOTPByUserID(userID uint) (*models.TOTPCredential, error) {
+	var credential models.TOTPCredential
+	err := r.db.Where("user_id = ?", userID).First(&credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Not enrolled, but no error
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// SaveTOTP creates or replaces the TOTP enrollment of a user
+func (r *MFARepository) SaveTOTP(credential *models.TOTPCredential) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if credential.ID == 0 {
+			if err := tx.Where("user_id = ?", credential.UserID).Delete(&models.TOTPCredential{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(credential).Error
+	})
+}
+
+// UpdateTOTPLastUsedStep atomically records the time step of an accepted code
+func (r *MFARepository) UpdateTOTPLastUsedStep(id uint, step int64) (bool, error) {
+	result := r.db.Model(&models.TOTPCredential{}).
+		Where("id = ? AND last_used_step < ?", id, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ConfirmTOTP sets confirmed_at only, so the last used step recorded while verifying
+// the confirmation code is kept
+func (r *MFARepository) ConfirmTOTP(id uint, confirmedAt time.Time) error {
+	return r.db.Model(&models.TOTPCredential{}).Where("id = ?", id).Update("confirmed_at", confirmedAt).Error
+}
+
+// DeleteTOTP deletes the TOTP enrollment and recovery codes of a user
+func (r *MFARepository) DeleteTOTP(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.TOTPCredential{}).Error
+	})
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of a user
+func (r *MFARepository) ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// FindUnusedRecoveryCodes finds the unused recovery codes of a user
+func (r *MFARepository) FindUnusedRecoveryCodes(userID uint) ([]models.RecoveryCode, error) {
+	var codes []models.RecoveryCode
+	err := r.db.Where("user_id = ? AND used_at IS NULL", userID).Find(&codes).Error
+	return codes, err
+}
+
+// MarkRecoveryCodeUsed atomically marks a recovery code as used
+func (r *MFARepository) MarkRecoveryCodeUsed(id uint) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ensure OAuthRepository implements interfaces.OAuthRepository
+var _ interfaces.OAuthRepository = (*OAuthRepository)(nil)
+
+// OAuthRepository implements the interfaces.OAuthRepository interface
+// using PostgreSQL as the database
+type OAuthRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthRepository creates a new OAuthRepository instance
+func NewOAuthRepository() *OAuthRepository {
+	return &OAuthRepository{
+		db: database.DB,
+	}
+}
+
+// CreateClient creates a new client in the database
+func (r *OAuthRepository) CreateClient(client *models.OAuthClient) error {
+	return r.db.Create(client).Error
+}
+
+// FindClientByClientID finds a client by its client ID
+func (r *OAuthRepository) FindClientByClientID(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.Where("client_id = ?", clientID).First(&client).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Client not found, but no error
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+// CreateAuthorizationCode creates a new authorization code in the database
+func (r *OAuthRepository) CreateAuthorizationCode(code *models.OAuthAuthorizationCode) error {
+	return r.db.Create(code).Error
+}
+
+// FindAuthorizationCodeByHash finds an authorization code by its hash
+func (r *OAuthRepository) FindAuthorizationCodeByHash(codeHash string) (*models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	err := r.db.Where("code_hash = ?", codeHash).First(&code).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Code not found, but no error
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+// MarkAuthorizationCodeUsed atomically marks an authorization code as used
+func (r *OAuthRepository) MarkAuthorizationCodeUsed(id uint) (bool, error) {
+	result := r.db.Model(&models.OAuthAuthorizationCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SetAuthorizationCodeFamily stores the refresh token family issued for an authorization code
+func (r *OAuthRepository) SetAuthorizationCodeFamily(id uint, familyID string) error {
+	return r.db.Model(&models.OAuthAuthorizationCode{}).
+		Where("id = ?", id).
+		Update("refresh_family_id", familyID).Error
+}
+
+// DeleteExpiredAuthorizationCodes deletes authorization codes that expired before the given time
+func (r *OAuthRepository) DeleteExpiredAuthorizationCodes(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&models.OAuthAuthorizationCode{}).Error
+}
+
+// FindConsent finds the consent a user gave a client
+func (r *OAuthRepository) FindConsent(userID uint, clientID string) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	err := r.db.Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No consent yet, but no error
+		}
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// FindConsentsByUserID finds the consents a user gave to any client
+func (r *OAuthRepository) FindConsentsByUserID(userID uint) ([]models.OAuthConsent, error) {
+	var consents []models.OAuthConsent
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&consents).Error
+	return consents, err
+}
+
+// SaveConsent creates or replaces the consent a user gave a client
+func (r *OAuthRepository) SaveConsent(consent *models.OAuthConsent) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scope", "updated_at"}),
+	}).Create(consent).Error
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure PasswordResetTokenRepository implements interfaces.PasswordResetTokenRepository
+var _ interfaces.PasswordResetTokenRepository = (*PasswordResetTokenRepository)(nil)
+
+// PasswordResetTokenRepository implements the interfaces.PasswordResetTokenRepository interface
+// using PostgreSQL as the database
+type PasswordResetTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetTokenRepository creates a new PasswordResetTokenRepository instance
+func NewPasswordResetTokenRepository() *PasswordResetTokenRepository {
+	return &PasswordResetTokenRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new reset token in the database
+func (r *PasswordResetTokenRepository) Create(token *models.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindByHash finds a reset token by the hash of its value
+func (r *PasswordResetTokenRepository) FindByHash(hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Token not found, but no error
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed atomically marks a token as used.
+// Returns false if another request already used it.
+func (r *PasswordResetTokenRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateForUser marks every unused token of a user as used
+func (r *PasswordResetTokenRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure PersonalAccessTokenRepository implements interfaces.PersonalAccessTokenRepository
+var _ interfaces.PersonalAccessTokenRepository = (*PersonalAccessTokenRepository)(nil)
+
+// PersonalAccessTokenRepository implements the interfaces.PersonalAccessTokenRepository interface
+// using PostgreSQL as the database
+type PersonalAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPersonalAccessTokenRepository creates a new PersonalAccessTokenRepository instance
+func NewPersonalAccessTokenRepository() *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new token in the database
+func (r *PersonalAccessTokenRepository) Create(token *models.PersonalAccessToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindByHash finds a token by the hash of its value
+func (r *PersonalAccessTokenRepository) FindByHash(hash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Token not found, but no error
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// FindByUserID finds all tokens of a user
+func (r *PersonalAccessTokenRepository) FindByUserID(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&tokens).Error
+	return tokens, err
+}
+
+// UpdateLastUsed records that a token was used
+func (r *PersonalAccessTokenRepository) UpdateLastUsed(id uint, usedAt time.Time) error {
+	return r.db.Model(&models.PersonalAccessToken{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
+
+// Delete deletes a token belonging to a user
+func (r *PersonalAccessTokenRepository) Delete(id, userID uint) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.PersonalAccessToken{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
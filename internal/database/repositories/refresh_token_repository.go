@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure RefreshTokenRepository implements interfaces.RefreshTokenRepository
+var _ interfaces.RefreshTokenRepository = (*RefreshTokenRepository)(nil)
+
+// RefreshTokenRepository implements the interfaces.RefreshTokenRepository interface
+// using PostgreSQL as the database
+type RefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository creates a new RefreshTokenRepository instance
+func NewRefreshTokenRepository() *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new refresh token in the database
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindByHash finds a refresh token by the hash of its value
+func (r *RefreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Token not found, but no error
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// FindByUserID finds every refresh token of a user
+func (r *RefreshTokenRepository) FindByUserID(userID uint) ([]models.RefreshToken, error) {
+	var tokens []models.RefreshToken
+	err := r.db.Where("user_id = ?", userID).Order("created_at, id").Find(&tokens).Error
+	return tokens, err
+}
+
+// MarkRotated atomically marks a token as rotated.
+// Returns false if another request already rotated or revoked it.
+func (r *RefreshTokenRepository) MarkRotated(id uint) (bool, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", id).
+		Update("rotated_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeFamily revokes every token in the given family and ends its session
+func (r *RefreshTokenRepository) RevokeFamily(familyID string) error {
+	return r.revoke("family_id = ? AND revoked_at IS NULL", familyID)
+}
+
+// RevokeAllForUser revokes every token belonging to the given user and ends their sessions
+func (r *RefreshTokenRepository) RevokeAllForUser(userID uint) error {
+	return r.revoke("user_id = ? AND revoked_at IS NULL", userID)
+}
+
+// revoke marks the tokens and sessions matching the condition as revoked
+func (r *RefreshTokenRepository) revoke(condition string, value any) error {
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.RefreshToken{}).Where(condition, value).Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Session{}).Where(condition, value).Update("revoked_at", now).Error
+	})
+}
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ensure RevocationRepository implements interfaces.RevocationRepository
+var _ interfaces.RevocationRepository = (*RevocationRepository)(nil)
+
+// RevocationRepository implements the interfaces.RevocationRepository interface
+// using PostgreSQL as the database
+type RevocationRepository struct {
+	db *gorm.DB
+}
+
+// NewRevocationRepository creates a new RevocationRepository instance
+func NewRevocationRepository() *RevocationRepository {
+	return &RevocationRepository{
+		db: database.DB,
+	}
+}
+
+// RevokeToken records a revoked token; revoking the same token twice is a no-op
+func (r *RevocationRepository) RevokeToken(token *models.RevokedToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+// IsTokenRevoked checks if a token ID has been revoked
+func (r *RevocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RevokeUserTokensBefore revokes all tokens of a user issued before the given time
+func (r *RevocationRepository) RevokeUserTokensBefore(userID uint, before time.Time) error {
+	revocation := &models.UserTokenRevocation{
+		UserID:        userID,
+		RevokedBefore: before,
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before"}),
+	}).Create(revocation).Error
+}
+
+// FindUserRevocation finds the user-wide revocation for a user
+func (r *RevocationRepository) FindUserRevocation(userID uint) (*models.UserTokenRevocation, error) {
+	var revocation models.UserTokenRevocation
+	err := r.db.First(&revocation, "user_id = ?", userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // No revocation, but no error
+		}
+		return nil, err
+	}
+	return &revocation, nil
+}
+
+// DeleteExpired deletes revoked token records that have expired
+func (r *RevocationRepository) DeleteExpired() error {
+	return r.db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error
+}
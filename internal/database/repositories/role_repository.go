@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ensure RoleRepository implements interfaces.RoleRepository
+var _ interfaces.RoleRepository = (*RoleRepository)(nil)
+
+// RoleRepository implements the interfaces.RoleRepository interface
+// using PostgreSQL as the database
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new RoleRepository instance
+func NewRoleRepository() *RoleRepository {
+	return &RoleRepository{
+		db: database.DB,
+	}
+}
+
+// UpsertRole creates or updates a role by name and replaces its permissions
+func (r *RoleRepository) UpsertRole(role *models.Role, permissions []models.Permission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range permissions {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"description"}),
+			}).Create(&permissions[i]).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("name = ?", permissions[i].Name).First(&permissions[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description", "built_in"}),
+		}).Omit("Permissions").Create(role).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("name = ?", role.Name).First(role).Error; err != nil {
+			return err
+		}
+		return tx.Model(role).Association("Permissions").Replace(permissions)
+	})
+}
+
+// FindAll finds all roles with their permissions
+func (r *RoleRepository) FindAll() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// FindByName finds a role with its permissions by name
+func (r *RoleRepository) FindByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Role not found, but no error
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// FindByUserID finds the roles assigned to a user
+func (r *RoleRepository) FindByUserID(userID uint) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles).Error
+	return roles, err
+}
+
+// Assign assigns a role to a user
+func (r *RoleRepository) Assign(assignment *models.UserRole) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Omit("Role").Create(assignment).Error
+}
+
+// Unassign removes a role from a user
+func (r *RoleRepository) Unassign(userID, roleID uint) (bool, error) {
+	result := r.db.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&models.UserRole{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UnassignUnlessLast removes a role from a user unless they are its last holder. Every removal
+// locks the role's assignments before counting them, so removals of the same role run one at a time.
+func (r *RoleRepository) UnassignUnlessLast(userID, roleID uint) (interfaces.RoleUnassignment, error) {
+	outcome := interfaces.RoleUnassigned
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var holders []uint
+		err := tx.Model(&models.UserRole{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("role_id = ?", roleID).Pluck("user_id", &holders).Error
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !slices.Contains(holders, userID):
+			outcome = interfaces.RoleNotAssigned
+			return nil
+		case len(holders) <= 1:
+			outcome = interfaces.RoleLastHolder
+			return nil
+		}
+		return tx.Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&models.UserRole{}).Error
+	})
+	return outcome, err
+}
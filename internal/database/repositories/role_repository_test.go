@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+func TestUnassignUnlessLastKeepsLastHolder(t *testing.T) {
+	repo := &RoleRepository{db: openTestDB(t, &models.Role{}, &models.UserRole{})}
+	role := &models.Role{Name: "admin"}
+	if err := repo.db.Create(role).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	for _, userID := range []uint{1, 2} {
+		if err := repo.Assign(&models.UserRole{UserID: userID, RoleID: role.ID}); err != nil {
+			t.Fatalf("Assign error: %v", err)
+		}
+	}
+
+	steps := []struct {
+		userID uint
+		want   interfaces.RoleUnassignment
+	}{
+		{3, interfaces.RoleNotAssigned},
+		{1, interfaces.RoleUnassigned},
+		{2, interfaces.RoleLastHolder},
+		{1, interfaces.RoleNotAssigned},
+	}
+	for _, step := range steps {
+		got, err := repo.UnassignUnlessLast(step.userID, role.ID)
+		if err != nil {
+			t.Fatalf("UnassignUnlessLast(%d) error: %v", step.userID, err)
+		}
+		if got != step.want {
+			t.Errorf("UnassignUnlessLast(%d) = %v, want %v", step.userID, got, step.want)
+		}
+	}
+
+	var holders []uint
+	repo.db.Model(&models.UserRole{}).Where("role_id = ?", role.ID).Pluck("user_id", &holders)
+	if len(holders) != 1 || holders[0] != 2 {
+		t.Errorf("holders = %v, want [2]", holders)
+	}
+}
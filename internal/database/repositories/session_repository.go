@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure SessionRepository implements interfaces.SessionRepository
+var _ interfaces.SessionRepository = (*SessionRepository)(nil)
+
+// SessionRepository implements the interfaces.SessionRepository interface
+// using PostgreSQL as the database
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new SessionRepository instance
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new session in the database
+func (r *SessionRepository) Create(session *models.Session) error {
+	return r.db.Create(session).Error
+}
+
+// FindByID finds a session by ID
+func (r *SessionRepository) FindByID(id uint) (*models.Session, error) {
+	var session models.Session
+	err := r.db.First(&session, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Session not found, but no error
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindByFamilyID finds the session of a refresh token family
+func (r *SessionRepository) FindByFamilyID(familyID string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("family_id = ?", familyID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Session not found, but no error
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindByUserID finds the sessions of a user, most recently seen first
+func (r *SessionRepository) FindByUserID(userID uint, activeOnly bool, limit int) ([]models.Session, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if activeOnly {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var sessions []models.Session
+	err := query.Order("last_seen_at DESC, id DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// Touch records that a session was refreshed
+func (r *SessionRepository) Touch(id uint, seenAt, expiresAt time.Time) error {
+	return r.db.Model(&models.Session{}).Where("id = ?", id).Updates(map[string]any{
+		"last_seen_at": seenAt,
+		"expires_at":   expiresAt,
+	}).Error
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure SigningKeyRepository implements interfaces.SigningKeyRepository
+var _ interfaces.SigningKeyRepository = (*SigningKeyRepository)(nil)
+
+// SigningKeyRepository implements the interfaces.SigningKeyRepository interface
+// using PostgreSQL as the database
+type SigningKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewSigningKeyRepository creates a new SigningKeyRepository instance
+func NewSigningKeyRepository() *SigningKeyRepository {
+	return &SigningKeyRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new signing key in the database
+func (r *SigningKeyRepository) Create(key *models.SigningKey) error {
+	return r.db.Create(key).Error
+}
+
+// FindUnexpired finds all keys that have not expired, newest first
+func (r *SigningKeyRepository) FindUnexpired() ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := r.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// RotateAllExcept marks every other unrotated key as rotated
+func (r *SigningKeyRepository) RotateAllExcept(kid string, expiresAt time.Time) error {
+	return r.db.Model(&models.SigningKey{}).
+		Where("kid <> ? AND rotated_at IS NULL", kid).
+		Updates(map[string]any{"rotated_at": time.Now(), "expires_at": expiresAt}).Error
+}
+
+// UpdatePrivateKey replaces the stored private key of a key
+func (r *SigningKeyRepository) UpdatePrivateKey(id uint, privateKey string) error {
+	return r.db.Model(&models.SigningKey{}).Where("id = ?", id).Update("private_key", privateKey).Error
+}
+
+// DeleteExpired deletes keys that have expired
+func (r *SigningKeyRepository) DeleteExpired() error {
+	return r.db.Where("expires_at IS NOT NULL AND expires_at < ?", time.Now()).
+		Delete(&models.SigningKey{}).Error
+}
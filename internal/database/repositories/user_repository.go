@@ -1,12 +1,17 @@
 package repositories
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/danigrb.dev/user-service/internal/database"
 	"github.com/danigrb.dev/user-service/internal/database/interfaces"
 	"github.com/danigrb.dev/user-service/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Ensure UserRepository implements interfaces.UserRepository
@@ -69,10 +74,12 @@ func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
 	return &user, nil
 }
 
-// FindByAppleID finds a user by Apple ID
-func (r *UserRepository) FindByAppleID(appleID string) (*models.User, error) {
+// FindByIdentity finds a user by a linked identity, e.g. ("apple", sub)
+func (r *UserRepository) FindByIdentity(provider, subject string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("apple_id = ?", appleID).First(&user).Error
+	err := r.db.Joins("JOIN user_identities ON user_identities.user_id = users.id").
+		Where("user_identities.provider = ? AND user_identities.subject = ?", provider, subject).
+		First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil // User not found, but no error
@@ -82,16 +89,180 @@ func (r *UserRepository) FindByAppleID(appleID string) (*models.User, error) {
 	return &user, nil
 }
 
+// userSortColumns maps the sortable fields to their columns
+var userSortColumns = map[string]string{
+	"created_at": "created_at",
+	"id":         "id",
+	"email":      "email",
+	"username":   "username",
+}
+
+// List lists users matching the filter with keyset pagination over (sort column, id)
+func (r *UserRepository) List(ctx context.Context, filter interfaces.UserFilter, cursor interfaces.UserCursor, limit int) ([]models.User, error) {
+	query := applyUserFilter(r.db.WithContext(ctx).Model(&models.User{}), filter)
+
+	column, ok := userSortColumns[cursor.Sort.Field]
+	if !ok {
+		column = "created_at"
+	}
+	comparison := ">"
+	if cursor.Sort.Descending {
+		comparison = "<"
+	}
+
+	if cursor.LastID != 0 {
+		switch column {
+		case "id":
+			query = query.Where("id "+comparison+" ?", cursor.LastID)
+		case "created_at":
+			lastCreatedAt, err := time.Parse(time.RFC3339Nano, cursor.LastValue)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor: %w", err)
+			}
+			query = query.Where("(created_at, id) "+comparison+" (?, ?)", lastCreatedAt, cursor.LastID)
+		default:
+			query = query.Where("("+column+", id) "+comparison+" (?, ?)", cursor.LastValue, cursor.LastID)
+		}
+	}
+
+	order := clause.OrderBy{Columns: []clause.OrderByColumn{
+		{Column: clause.Column{Name: column}, Desc: cursor.Sort.Descending},
+	}}
+	if column != "id" {
+		order.Columns = append(order.Columns, clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: cursor.Sort.Descending})
+	}
+
+	var users []models.User
+	err := query.Order(order).Limit(limit).Find(&users).Error
+	return users, err
+}
+
+// applyUserFilter adds the conditions of a listing filter to a users query
+func applyUserFilter(query *gorm.DB, filter interfaces.UserFilter) *gorm.DB {
+	if filter.Query != "" {
+		prefix := escapeLike(strings.ToLower(filter.Query)) + "%"
+		query = query.Where("(LOWER(email) LIKE ? OR LOWER(username) LIKE ?)", prefix, prefix)
+	}
+	if filter.EmailPrefix != "" {
+		query = query.Where("LOWER(email) LIKE ?", escapeLike(strings.ToLower(filter.EmailPrefix))+"%")
+	}
+	if filter.UsernamePrefix != "" {
+		query = query.Where("LOWER(username) LIKE ?", escapeLike(strings.ToLower(filter.UsernamePrefix))+"%")
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Role != "" {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = users.id AND roles.name = ?)`, filter.Role)
+	}
+	if filter.EmailVerified != nil {
+		if *filter.EmailVerified {
+			query = query.Where("email_verified_at IS NOT NULL")
+		} else {
+			query = query.Where("email_verified_at IS NULL")
+		}
+	}
+	return query
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 // Update updates a user in the database
 func (r *UserRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
+// UpdateStatus changes the status columns of a user and records the transition
+func (r *UserRepository) UpdateStatus(user *models.User, transition *models.UserStatusTransition) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]any{
+			"status":            user.Status,
+			"status_reason":     user.StatusReason,
+			"status_changed_at": user.StatusChangedAt,
+			"purge_at":          user.PurgeAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(transition).Error
+	})
+}
+
+// FindStatusTransitions finds the status transitions of a user
+func (r *UserRepository) FindStatusTransitions(userID uint) ([]models.UserStatusTransition, error) {
+	var transitions []models.UserStatusTransition
+	err := r.db.Where("user_id = ?", userID).Order("created_at, id").Find(&transitions).Error
+	return transitions, err
+}
+
 // Delete deletes a user from the database
 func (r *UserRepository) Delete(id uint) error {
 	return r.db.Delete(&models.User{}, id).Error
 }
 
+// FindPurgeable finds the IDs of deleted users whose grace period ended before the given time
+func (r *UserRepository) FindPurgeable(before time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&models.User{}).
+		Where("status = ? AND purge_at < ?", models.UserStatusDeleted, before).
+		Order("purge_at").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// purgedUserRecords lists the models whose rows are owned by a user through user_id
+var purgedUserRecords = []any{
+	&models.RefreshToken{},
+	&models.Session{},
+	&models.RevokedToken{},
+	&models.UserTokenRevocation{},
+	&models.PasswordResetToken{},
+	&models.TOTPCredential{},
+	&models.RecoveryCode{},
+	&models.WebAuthnCredential{},
+	&models.UserIdentity{},
+	&models.OIDCAuthRequest{},
+	&models.OAuthAuthorizationCode{},
+	&models.OAuthConsent{},
+	&models.PersonalAccessToken{},
+	&models.UserRole{},
+	&models.UserStatusTransition{},
+	&models.DataExport{},
+}
+
+// Purge permanently deletes a user and the records linked to them. Users that were restored
+// in the meantime, or deleted again with a new grace period, are left alone.
+func (r *UserRepository) Purge(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND purge_at < ?", models.UserStatusDeleted, time.Now()).First(&user, id).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // Restored, still in its grace period or already purged
+			}
+			return err
+		}
+
+		for _, record := range purgedUserRecords {
+			if err := tx.Where("user_id = ?", id).Delete(record).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&user).Error
+	})
+}
+
 // EmailExists checks if an email already exists in the database
 func (r *UserRepository) EmailExists(email string) (bool, error) {
 	var count int64
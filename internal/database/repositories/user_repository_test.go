@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// newTestUserRepository returns a UserRepository on a fresh SQLite database with the user tables
+func newTestUserRepository(t *testing.T) *UserRepository {
+	t.Helper()
+	return &UserRepository{db: openTestDB(t, append([]any{&models.User{}}, purgedUserRecords...)...)}
+}
+
+// createDeletedUser creates a user deleted with the given purge time
+func createDeletedUser(t *testing.T, repo *UserRepository, purgeAt time.Time) *models.User {
+	t.Helper()
+
+	user := &models.User{Email: "ada@example.com", Username: "ada", Status: models.UserStatusDeleted, PurgeAt: &purgeAt}
+	if err := repo.db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// userExists reports whether the user is still stored
+func userExists(t *testing.T, repo *UserRepository, id uint) bool {
+	t.Helper()
+
+	var count int64
+	if err := repo.db.Model(&models.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	return count > 0
+}
+
+func TestPurgeErasesExpiredAccounts(t *testing.T) {
+	repo := newTestUserRepository(t)
+	user := createDeletedUser(t, repo, time.Now().Add(-time.Hour))
+	if err := repo.db.Create(&models.Session{UserID: user.ID, FamilyID: "family"}).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	if err := repo.Purge(user.ID); err != nil {
+		t.Fatalf("Purge error: %v", err)
+	}
+	if userExists(t, repo, user.ID) {
+		t.Error("expired account was not purged")
+	}
+	var sessions int64
+	repo.db.Model(&models.Session{}).Where("user_id = ?", user.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("%d sessions left after purge", sessions)
+	}
+}
+
+func TestPurgeSkipsAccountsDeletedAgain(t *testing.T) {
+	repo := newTestUserRepository(t)
+	user := createDeletedUser(t, repo, time.Now().Add(-time.Hour))
+
+	ids, err := repo.FindPurgeable(time.Now(), 10)
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("FindPurgeable = %v, %v, want the user", ids, err)
+	}
+
+	// Restored and deleted again before the purger gets to it: a new grace period starts
+	if err := repo.db.Model(user).Update("purge_at", time.Now().Add(30*24*time.Hour)).Error; err != nil {
+		t.Fatalf("update purge_at: %v", err)
+	}
+	if err := repo.Purge(ids[0]); err != nil {
+		t.Fatalf("Purge error: %v", err)
+	}
+	if !userExists(t, repo, user.ID) {
+		t.Error("account in a new grace period was purged")
+	}
+}
+
+func TestPurgeSkipsRestoredAccounts(t *testing.T) {
+	repo := newTestUserRepository(t)
+	user := createDeletedUser(t, repo, time.Now().Add(-time.Hour))
+
+	if err := repo.db.Model(user).Updates(map[string]any{"status": models.UserStatusActive, "purge_at": nil}).Error; err != nil {
+		t.Fatalf("restore user: %v", err)
+	}
+	if err := repo.Purge(user.ID); err != nil {
+		t.Fatalf("Purge error: %v", err)
+	}
+	if !userExists(t, repo, user.ID) {
+		t.Error("restored account was purged")
+	}
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"gorm.io/gorm"
+)
+
+// Ensure WebAuthnCredentialRepository implements interfaces.WebAuthnCredentialRepository
+var _ interfaces.WebAuthnCredentialRepository = (*WebAuthnCredentialRepository)(nil)
+
+// WebAuthnCredentialRepository implements the interfaces.WebAuthnCredentialRepository interface
+// using PostgreSQL as the database
+type WebAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnCredentialRepository creates a new WebAuthnCredentialRepository instance
+func NewWebAuthnCredentialRepository() *WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepository{
+		db: database.DB,
+	}
+}
+
+// Create creates a new credential in the database
+func (r *WebAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	return r.db.Create(credential).Error
+}
+
+// FindByCredentialID finds a credential by its credential ID
+func (r *WebAuthnCredentialRepository) FindByCredentialID(credentialID string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.Where("credential_id = ?", credentialID).First(&credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil // Credential not found, but no error
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// FindByUserID finds all credentials of a user
+func (r *WebAuthnCredentialRepository) FindByUserID(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error
+	return credentials, err
+}
+
+// UpdateSignCount records a successful assertion, guarding against concurrent updates
+func (r *WebAuthnCredentialRepository) UpdateSignCount(id uint, previous, current uint32, backupState bool) (bool, error) {
+	result := r.db.Model(&models.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", id, previous).
+		Updates(map[string]any{
+			"sign_count":   current,
+			"backup_state": backupState,
+			"last_used_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Delete deletes a credential belonging to a user
+func (r *WebAuthnCredentialRepository) Delete(id, userID uint) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
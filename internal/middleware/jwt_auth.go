@@ -1,12 +1,12 @@
 package middleware
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
-	"os"
+	"slices"
 
+	"github.com/danigrb.dev/user-service/internal/services"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // ExtractUserID extracts the user ID from the Gin context
@@ -33,37 +33,161 @@ func ExtractUserID(c *gin.Context) (uint, bool) {
 	}
 }
 
-// JWTAuth is a middleware that validates JWT tokens
+// ExtractClaims returns the verified token claims stored in the Gin context by JWTAuth
+func ExtractClaims(c *gin.Context) (*services.Claims, bool) {
+	value, exists := c.Get("claims")
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*services.Claims)
+	return claims, ok
+}
+
+// JWTAuth is a middleware that validates JWT tokens and rejects users whose account
+// status no longer allows access
 func JWTAuth() gin.HandlerFunc {
+	authService := services.NewAuthService()
+	userStatus := services.GetUserStatusService()
+
 	return func(c *gin.Context) {
-		// Get the token from the Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" || len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+		tokenString, ok := bearerToken(c)
+		if !ok {
 			return
 		}
 
-		tokenString := authHeader[7:]
+		// Verify the signature (key selected by kid), registered claims and revocation state
+		claims, err := authService.ValidateAccessToken(tokenString)
+		if err == nil {
+			err = userStatus.CheckUser(claims.UserID)
+		}
+		if err != nil {
+			abortInvalidToken(c, err)
+			return
+		}
+
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// TokenAuth is a middleware for routes that scripts may call. It accepts the same access
+// tokens as JWTAuth, as well as personal access tokens granted all of the given scopes.
+func TokenAuth(scopes ...string) gin.HandlerFunc {
+	authService := services.NewAuthService()
+	userStatus := services.GetUserStatusService()
+	personalAccessTokens := services.NewPersonalAccessTokenService()
 
-		// Parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	return func(c *gin.Context) {
+		tokenString, ok := bearerToken(c)
+		if !ok {
+			return
+		}
+
+		if !services.IsPersonalAccessToken(tokenString) {
+			claims, err := authService.ValidateAccessToken(tokenString)
+			if err == nil {
+				err = userStatus.CheckUser(claims.UserID)
+			}
+			if err != nil {
+				abortInvalidToken(c, err)
+				return
 			}
-			secret := []byte(os.Getenv("JWT_SECRET"))
-			return secret, nil
-		})
+			setClaims(c, claims)
+			c.Next()
+			return
+		}
 
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		claims, err := personalAccessTokens.Authenticate(tokenString)
+		if err != nil {
+			abortInvalidToken(c, err)
 			return
 		}
+		for _, scope := range scopes {
+			if !slices.Contains(claims.Scopes, scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "Token is missing the " + scope + " scope",
+					"code":  "insufficient_scope",
+				})
+				return
+			}
+		}
 
-		// Set claims in context for handlers to use
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("user_id", claims["user_id"])
-			c.Set("email", claims["email"])
-			c.Set("username", claims["username"])
+		setClaims(c, claims)
+		c.Next()
+	}
+}
+
+// bearerToken returns the token of the Authorization header, or aborts with 401
+func bearerToken(c *gin.Context) (string, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" || len(authHeader) < 7 || authHeader[:7] != "Bearer " {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+		return "", false
+	}
+	return authHeader[7:], true
+}
+
+// abortInvalidToken responds to a token that failed validation
+func abortInvalidToken(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTokenRevoked):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+	case errors.Is(err, services.ErrInvalidToken):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+	case services.AccountStatusCode(err) != "":
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": services.AccountStatusCode(err)})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+	}
+}
+
+// setClaims sets the verified claims in the context for handlers to use
+func setClaims(c *gin.Context, claims *services.Claims) {
+	c.Set("claims", claims)
+	c.Set("user_id", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("username", claims.Username)
+	c.Set("jti", claims.ID)
+	if claims.ExpiresAt != nil {
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+	}
+}
+
+// RequireVerifiedEmail rejects users whose email is not verified when
+// EMAIL_VERIFICATION_POLICY is "routes". It must run after JWTAuth.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if services.CurrentEmailVerificationPolicy() != services.EmailVerificationPolicyRoutes {
+			c.Next()
+			return
+		}
+
+		claims, ok := ExtractClaims(c)
+		if !ok || !claims.EmailVerified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Email address has not been verified",
+				"code":  "email_not_verified",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission rejects requests whose token doesn't grant the permission.
+// It must run after JWTAuth.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ExtractClaims(c)
+		if !ok || !slices.Contains(claims.Permissions, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Missing permission " + permission,
+				"code":  "insufficient_permissions",
+			})
+			return
 		}
 
 		c.Next()
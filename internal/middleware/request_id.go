@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"github.com/danigrb.dev/user-service/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID correlating a request across services and the audit log
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients and proxies
+const maxRequestIDLength = 128
+
+// RequestID keeps the X-Request-ID of incoming requests, or assigns a new one, and echoes it
+// in the response. The ID is stored in the Gin context under "request_id".
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			generated, err := services.NewTokenID()
+			if err != nil {
+				c.Next()
+				return
+			}
+			requestID = generated
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// ExtractRequestID returns the ID RequestID assigned to the request, or ""
+func ExtractRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// validRequestID reports whether a client-provided request ID is safe to log and store
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditChange is the value of a field before and after an operation
+type AuditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// AuditChanges maps changed fields to their old and new values
+type AuditChanges map[string]AuditChange
+
+// Scan implements the sql.Scanner interface for AuditChanges.
+func (c *AuditChanges) Scan(src any) error {
+	return scanJSON(src, c)
+}
+
+// Value implements the driver.Valuer interface for AuditChanges.
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// AuditMetadata holds details specific to an action
+type AuditMetadata map[string]any
+
+// Scan implements the sql.Scanner interface for AuditMetadata.
+func (m *AuditMetadata) Scan(src any) error {
+	return scanJSON(src, m)
+}
+
+// Value implements the driver.Valuer interface for AuditMetadata.
+func (m AuditMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// AuditEvent records a security-relevant operation. Events are append-only: they are never
+// updated, and they outlive the accounts they refer to.
+type AuditEvent struct {
+	ID           uint          `gorm:"primaryKey" json:"id"`
+	Action       string        `gorm:"index;not null" json:"action"`
+	ActorID      *uint         `gorm:"index" json:"actor_id,omitempty"`       // User who performed the operation, nil when anonymous
+	TargetUserID *uint         `gorm:"index" json:"target_user_id,omitempty"` // User the operation was performed on
+	IP           string        `gorm:"" json:"ip,omitempty"`
+	UserAgent    string        `gorm:"" json:"user_agent,omitempty"`
+	RequestID    string        `gorm:"index" json:"request_id,omitempty"`
+	Changes      AuditChanges  `gorm:"type:json" json:"changes,omitempty"`
+	Metadata     AuditMetadata `gorm:"type:json" json:"metadata,omitempty"`
+	CreatedAt    time.Time     `gorm:"index;not null" json:"created_at"`
+}
+
+// scanJSON unmarshals a JSON column into dst
+func scanJSON(src any, dst any) error {
+	if src == nil {
+		return nil
+	}
+
+	var source []byte
+	switch src := src.(type) {
+	case string:
+		source = []byte(src)
+	case []byte:
+		source = src
+	default:
+		return errors.New("incompatible type for JSON column")
+	}
+	return json.Unmarshal(source, dst)
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// DataExportStatus is the progress of a data export
+type DataExportStatus string
+
+const (
+	DataExportPending DataExportStatus = "pending" // Queued or being assembled
+	DataExportReady   DataExportStatus = "ready"   // Archive can be downloaded
+	DataExportFailed  DataExportStatus = "failed"  // Assembling the archive failed
+)
+
+// DataExport is a copy of everything stored about a user, assembled in the background on
+// request. The archive is kept until ExpiresAt and downloaded through a short-lived link;
+// only the SHA-256 hash of the current link token is stored.
+type DataExport struct {
+	ID                uint             `gorm:"primaryKey" json:"id"`
+	UserID            uint             `gorm:"index;not null" json:"-"`
+	Status            DataExportStatus `gorm:"type:varchar(16);not null" json:"status"`
+	Format            string           `gorm:"not null" json:"format"` // "json" or "zip"
+	Archive           []byte           `gorm:"" json:"-"`
+	Size              int              `gorm:"not null;default:0" json:"size,omitempty"`
+	Error             string           `gorm:"" json:"error,omitempty"`
+	DownloadTokenHash *string          `gorm:"uniqueIndex" json:"-"`
+	DownloadExpiresAt *time.Time       `gorm:"" json:"-"` // When the current download link stops working
+	CreatedAt         time.Time        `json:"created_at"`
+	CompletedAt       *time.Time       `json:"completed_at,omitempty"`
+	ExpiresAt         time.Time        `gorm:"index;not null" json:"expires_at"` // When the export is deleted
+}
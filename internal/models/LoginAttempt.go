@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// LoginAttempt counts the failed sign-ins of one key (an account, an IP address or a subnet)
+// within a window that starts with the first failure, and the lockout they caused
+type LoginAttempt struct {
+	Key             string     `gorm:"primaryKey" json:"key"`
+	Failures        int        `gorm:"not null" json:"failures"`
+	LastFailureAt   time.Time  `gorm:"not null" json:"last_failure_at"`
+	WindowExpiresAt time.Time  `gorm:"index;not null" json:"window_expires_at"`
+	LockedUntil     *time.Time `json:"locked_until,omitempty"`
+}
+
+// IsLocked reports whether the key is locked out at the given time
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// CurrentFailures returns the failures counted in the window that is open at the given time
+func (a *LoginAttempt) CurrentFailures(now time.Time) int {
+	if !now.Before(a.WindowExpiresAt) {
+		return 0
+	}
+	return a.Failures
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// OAuthClient is an application allowed to log users in through this service's
+// OAuth 2.0 / OpenID Connect provider. Public clients (SPAs, mobile apps) have no secret
+// and must use PKCE.
+type OAuthClient struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ClientID         string    `gorm:"uniqueIndex;not null" json:"client_id"`
+	ClientSecretHash *string   `gorm:"" json:"-"` // Nil for public clients
+	Name             string    `gorm:"not null" json:"client_name"`
+	RedirectURIs     string    `gorm:"not null" json:"-"`                         // Space separated
+	GrantTypes       string    `gorm:"not null" json:"-"`                         // Space separated
+	Scopes           string    `gorm:"not null" json:"-"`                         // Space separated scopes the client may request
+	FirstParty       bool      `gorm:"not null;default:false" json:"first_party"` // First-party clients skip the consent screen
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// IsPublic reports whether the client has no secret
+func (c *OAuthClient) IsPublic() bool {
+	return c.ClientSecretHash == nil
+}
+
+// RedirectURIList returns the registered redirect URIs
+func (c *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+// GrantTypeList returns the grant types the client may use
+func (c *OAuthClient) GrantTypeList() []string {
+	return strings.Fields(c.GrantTypes)
+}
+
+// ScopeList returns the scopes the client may request
+func (c *OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// OAuthAuthorizationCode is a single-use code issued by the authorization endpoint.
+// Only the SHA-256 hash of the code is stored.
+type OAuthAuthorizationCode struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	CodeHash            string     `gorm:"uniqueIndex;not null" json:"-"`
+	ClientID            string     `gorm:"index;not null" json:"client_id"`
+	UserID              uint       `gorm:"not null" json:"user_id"`
+	RedirectURI         string     `gorm:"not null" json:"redirect_uri"`
+	Scope               string     `gorm:"not null" json:"scope"`
+	Nonce               string     `gorm:"" json:"-"`
+	CodeChallenge       string     `gorm:"" json:"-"`
+	CodeChallengeMethod string     `gorm:"" json:"-"`
+	AuthTime            time.Time  `json:"auth_time"`
+	RefreshFamilyID     string     `gorm:"" json:"-"` // Family of the refresh token issued for the code, revoked if the code is replayed
+	ExpiresAt           time.Time  `gorm:"index;not null" json:"expires_at"`
+	UsedAt              *time.Time `json:"used_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// OAuthConsent records the scopes a user granted to a client
+type OAuthConsent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_consent_user_client;not null" json:"user_id"`
+	ClientID  string    `gorm:"uniqueIndex:idx_consent_user_client;not null" json:"client_id"`
+	Scope     string    `gorm:"not null" json:"scope"` // Space separated
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// PersonalAccessToken is a long-lived credential a user creates for scripts and CI jobs.
+// Only the SHA-256 hash of the token is stored; the plaintext is shown once on creation.
+type PersonalAccessToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	Name       string     `gorm:"not null" json:"name"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Prefix     string     `gorm:"not null" json:"prefix"` // Start of the token, to help users recognize it
+	Scopes     string     `gorm:"not null" json:"-"`      // Space separated
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`   // Nil for tokens that never expire
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ScopeList returns the scopes granted to the token
+func (t *PersonalAccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// IsExpired reports whether the token has passed its expiry
+func (t *PersonalAccessToken) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
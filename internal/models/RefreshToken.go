@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RefreshToken is an opaque, long-lived credential that can be exchanged for a new
+// access token. Only the SHA-256 hash of the token is stored. Every rotation creates
+// a new token in the same family; replaying a rotated token revokes the whole family.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID  string     `gorm:"index;not null" json:"family_id"`
+	ClientID  string     `gorm:"index" json:"client_id,omitempty"` // OAuth client the token was issued to, empty for first-party sessions
+	Scope     string     `gorm:"" json:"scope,omitempty"`          // Space separated scopes granted to the OAuth client
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	AuthTime  time.Time  `json:"auth_time"` // When the user last actively authenticated in this family
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"` // Set once the token has been exchanged
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // Set when the token (or its family) is revoked
+}
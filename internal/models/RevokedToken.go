@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// RevokedToken records an access token (by its jti claim) that was revoked before it expired.
+// Rows can be removed once ExpiresAt has passed since the token is rejected anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey" json:"jti"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserTokenRevocation invalidates every access token of a user issued before RevokedBefore.
+// It is used to sign a user out of all sessions at once.
+type UserTokenRevocation struct {
+	UserID        uint      `gorm:"primaryKey" json:"user_id"`
+	RevokedBefore time.Time `gorm:"not null" json:"revoked_before"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Permission is a named right checked by route guards, such as "users:read"
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"-"`
+	Name        string `gorm:"uniqueIndex;not null" json:"name"`
+	Description string `gorm:"" json:"description,omitempty"`
+}
+
+// Role groups permissions that are granted to users together
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"name"`
+	Description string       `gorm:"" json:"description,omitempty"`
+	BuiltIn     bool         `gorm:"not null;default:false" json:"built_in"` // Seeded at startup; can't be changed through the API
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// PermissionNames returns the names of the role's permissions
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, permission := range r.Permissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
+
+// UserRole assigns a role to a user
+type UserRole struct {
+	UserID     uint      `gorm:"primaryKey" json:"user_id"`
+	RoleID     uint      `gorm:"primaryKey;index" json:"role_id"`
+	AssignedBy *uint     `gorm:"" json:"assigned_by,omitempty"` // Nil for assignments made at startup
+	CreatedAt  time.Time `json:"created_at"`
+	Role       Role      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Session is a first-party sign-in on one device. It follows a refresh token family:
+// it starts when the user authenticates, is seen again on every refresh and ends when
+// the family is revoked. Access tokens name their session in the "sid" claim.
+type Session struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	FamilyID   string     `gorm:"uniqueIndex;not null" json:"-"` // Refresh token family of the session
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	AuthMethod string     `json:"auth_method"` // How the user signed in, e.g. "password" or "passkey"
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"` // Expiry of the latest refresh token
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive reports whether the session can still be refreshed
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
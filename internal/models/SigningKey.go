@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SigningKey is an asymmetric key pair used to sign tokens issued by this service.
+// The newest key that has not been rotated out signs new tokens; rotated keys stay
+// published in the JWKS until ExpiresAt so tokens they signed can still be verified.
+type SigningKey struct {
+	ID         uint       `gorm:"primaryKey" json:"-"`
+	KID        string     `gorm:"uniqueIndex;not null" json:"kid"`
+	Algorithm  string     `gorm:"not null" json:"alg"`
+	PrivateKey string     `gorm:"type:text;not null" json:"-"` // PKCS#8 PEM, encrypted with SIGNING_KEY_ENCRYPTION_KEY
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"` // When the key stopped signing new tokens
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// TOTPCredential is a user's authenticator app enrollment (RFC 6238).
+// The shared secret is stored encrypted; the enrollment only counts once confirmed.
+type TOTPCredential struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	EncryptedSecret string     `gorm:"not null" json:"-"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep    int64      `gorm:"not null;default:0" json:"-"` // Time step of the last accepted code, prevents replay
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// RecoveryCode is a hashed one-time code that can replace a TOTP code when the
+// authenticator is unavailable
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -4,8 +4,10 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type Preferences map[string]any
@@ -48,15 +50,56 @@ func (p Preferences) Value() (driver.Value, error) {
 	return json.Marshal(p)
 }
 
+// UserStatus is the lifecycle state of an account
+type UserStatus string
+
+const (
+	UserStatusPending   UserStatus = "pending"   // Registered, email not verified yet
+	UserStatusActive    UserStatus = "active"    // Normal account
+	UserStatusSuspended UserStatus = "suspended" // Temporarily locked by an admin
+	UserStatusBanned    UserStatus = "banned"    // Permanently locked by an admin
+	UserStatusDeleted   UserStatus = "deleted"   // Deleted by the user or an admin, restorable until purged
+)
+
+// CanSignIn reports whether accounts in the status may sign in and use their tokens.
+// Whether pending accounts may sign in is left to the email verification policy.
+func (s UserStatus) CanSignIn() bool {
+	return s == UserStatusActive || s == UserStatusPending
+}
+
 type User struct {
-	ID           uint        `gorm:"primaryKey" json:"id"`
+	ID           uint        `gorm:"primaryKey;index:idx_users_created_at_id,priority:2" json:"id"`
 	Email        string      `gorm:"uniqueIndex;not null" json:"email"`
-	PasswordHash *string     `gorm:"" json:"-"` // Nullable for Apple users
+	PasswordHash *string     `gorm:"" json:"-"` // Nullable for users who only sign in through other methods
 	Username     string      `gorm:"unique;not null" json:"username"`
 	AvatarURL    string      `gorm:"" json:"avatar_url,omitempty"`
 	Preferences  Preferences `gorm:"type:json" json:"preferences"`
-	AppleID      *string     `gorm:"uniqueIndex" json:"-"` // Stores Apple 'sub' claim, nullable for non-Apple users
-	AppleEmail   *string     `gorm:"" json:"-"`            // Optional: store Apple email if provided
+
+	EmailVerifiedAt *time.Time `gorm:"" json:"email_verified_at,omitempty"` // Nil until the user confirms their email
+
+	Status          UserStatus `gorm:"type:varchar(16);not null;default:active;index" json:"status"`
+	StatusReason    string     `gorm:"" json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `gorm:"" json:"status_changed_at,omitempty"`
+	PurgeAt         *time.Time `gorm:"index" json:"purge_at,omitempty"` // When a deleted account is erased for good, nil otherwise
+
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP;index:idx_users_created_at_id,priority:1" json:"created_at"` // Existing rows are backfilled with the migration time
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// IsEmailVerified reports whether the user has confirmed their current email address.
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// BeforeCreate starts new accounts as pending until their email is verified.
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.Status == "" {
+		u.Status = UserStatusPending
+		if u.IsEmailVerified() {
+			u.Status = UserStatusActive
+		}
+	}
+	return nil
 }
 
 // SetPassword hashes the given password and sets the PasswordHash field.
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// IdentityClaims holds the raw claims a provider returned for an identity
+type IdentityClaims map[string]any
+
+// Scan implements the sql.Scanner interface for IdentityClaims.
+func (c *IdentityClaims) Scan(src any) error {
+	if src == nil {
+		*c = make(IdentityClaims)
+		return nil
+	}
+
+	var source []byte
+	switch src := src.(type) {
+	case string:
+		source = []byte(src)
+	case []byte:
+		source = src
+	default:
+		return errors.New("incompatible type for IdentityClaims")
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(source, &result); err != nil {
+		return err
+	}
+
+	*c = result
+	return nil
+}
+
+// Value implements the driver.Valuer interface for IdentityClaims.
+func (c IdentityClaims) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// UserIdentity links a user to an account at an external identity provider
+// (Apple, Google, any OpenID Connect provider, ...)
+type UserIdentity struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	UserID        uint           `gorm:"index;not null" json:"-"`
+	Provider      string         `gorm:"uniqueIndex:idx_identity_provider_subject;not null" json:"provider"`
+	Subject       string         `gorm:"uniqueIndex:idx_identity_provider_subject;not null" json:"-"` // The provider's stable user ID ('sub' claim)
+	Email         string         `gorm:"" json:"email,omitempty"`
+	EmailVerified bool           `gorm:"not null;default:false" json:"email_verified"`
+	RawClaims     IdentityClaims `gorm:"type:json" json:"-"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	LastLoginAt   *time.Time     `json:"last_login_at,omitempty"`
+}
+
+// OIDCAuthRequest is a pending authorization code flow with an external provider, either to log in or to link an identity.
+// It keeps the PKCE verifier and nonce server side until the callback; only the hash of the state is stored.
+type OIDCAuthRequest struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	StateHash    string    `gorm:"uniqueIndex;not null" json:"-"`
+	Provider     string    `gorm:"not null" json:"provider"`
+	UserID       *uint     `gorm:"" json:"-"` // Set when an authenticated user is linking the identity to their account
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	RedirectURI  string    `gorm:"not null" json:"redirect_uri"`
+	ExpiresAt    time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
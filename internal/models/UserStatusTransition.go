@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// UserStatusTransition records a change of an account's lifecycle status
+type UserStatusTransition struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	FromStatus UserStatus `gorm:"type:varchar(16);not null" json:"from_status"`
+	ToStatus   UserStatus `gorm:"type:varchar(16);not null" json:"to_status"`
+	Reason     string     `gorm:"" json:"reason,omitempty"`
+	ActorID    *uint      `gorm:"" json:"actor_id,omitempty"` // Admin who made the change; nil for the user or the system
+	CreatedAt  time.Time  `json:"created_at"`
+}
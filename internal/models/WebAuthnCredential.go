@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey or security key registered by a user
+type WebAuthnCredential struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"index;not null" json:"-"`
+	CredentialID   string     `gorm:"uniqueIndex;not null" json:"credential_id"` // base64url encoded
+	PublicKey      []byte     `gorm:"not null" json:"-"`                         // COSE encoded
+	Algorithm      int        `gorm:"not null" json:"-"`
+	SignCount      uint32     `gorm:"not null;default:0" json:"-"`
+	AAGUID         string     `gorm:"" json:"aaguid,omitempty"`
+	Transports     string     `gorm:"" json:"transports,omitempty"` // Comma separated hints from the browser
+	Name           string     `gorm:"" json:"name"`
+	BackupEligible bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	BackupState    bool       `gorm:"not null;default:false" json:"backed_up"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/danigrb.dev/user-service/internal/controllers"
 	"github.com/danigrb.dev/user-service/internal/middleware"
+	"github.com/danigrb.dev/user-service/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,29 +15,134 @@ func (server *Server) SetupRouter() {
 	// TODO: Uncomment and implement middleware when available
 	// router.Use(middleware.Logging())
 	// router.Use(middleware.CORS())
+	router.Use(middleware.RequestID())
 
 	// Initialize controllers
 	authController := controllers.NewAuthController()
 	userController := controllers.NewUserController()
+	wellKnownController := controllers.NewWellKnownController()
+	emailVerificationController := controllers.NewEmailVerificationController()
+	passwordController := controllers.NewPasswordController()
+	mfaController := controllers.NewMFAController()
+	webAuthnController := controllers.NewWebAuthnController()
+	accountLinkController := controllers.NewAccountLinkController()
+	oauthController := controllers.NewOAuthController()
+	personalAccessTokenController := controllers.NewPersonalAccessTokenController()
+	roleController := controllers.NewRoleController()
+	adminUserController := controllers.NewAdminUserController()
+	dataExportController := controllers.NewDataExportController()
+	auditController := controllers.NewAuditController()
+	sessionController := controllers.NewSessionController()
 
 	// Auth routes
 	auth := router.Group("/auth")
 	{
 		auth.POST("/register", authController.Register)
 		auth.POST("/login", authController.Login)
+		auth.POST("/mfa/verify", authController.VerifyMFA)
+		auth.POST("/magic-link", authController.RequestMagicLink)
+		auth.POST("/magic-link/consume", authController.ConsumeMagicLink)
 		auth.POST("/refresh", authController.RefreshToken)
+		auth.POST("/restore", authController.RestoreAccount)
+		auth.POST("/unlock", authController.UnlockAccount)
 		auth.POST("/apple", authController.AppleLogin)
+		auth.GET("/oidc/providers", authController.OIDCProviders)
+		auth.POST("/oidc/:provider/authorize", authController.OIDCAuthorize)
+		auth.POST("/oidc/:provider/callback", authController.OIDCCallback)
+		auth.POST("/logout", middleware.JWTAuth(), authController.Logout)
+		auth.POST("/logout-all", middleware.JWTAuth(), authController.LogoutAll)
+		auth.POST("/verify-email", emailVerificationController.VerifyEmail)
+		auth.POST("/verify-email/resend", emailVerificationController.ResendVerificationEmail)
+		auth.POST("/password/forgot", passwordController.ForgotPassword)
+		auth.POST("/password/reset", passwordController.ResetPassword)
+		auth.POST("/webauthn/register/begin", middleware.JWTAuth(), webAuthnController.BeginRegistration)
+		auth.POST("/webauthn/register/finish", middleware.JWTAuth(), webAuthnController.FinishRegistration)
+		auth.POST("/webauthn/login/begin", webAuthnController.BeginLogin)
+		auth.POST("/webauthn/login/finish", webAuthnController.FinishLogin)
+		auth.POST("/webauthn/mfa/begin", webAuthnController.BeginMFA)
+		auth.POST("/webauthn/mfa/finish", webAuthnController.FinishMFA)
+	}
+
+	// User routes that also accept personal access tokens with the given scopes
+	api := router.Group("/user")
+	{
+		api.GET("/profile", middleware.TokenAuth(services.ScopeProfileRead), userController.GetProfile)
+		api.PUT("/profile", middleware.TokenAuth(services.ScopeProfileWrite), middleware.RequireVerifiedEmail(), userController.UpdateProfile)
+
+		// Authenticated by the token in the link returned by POST /user/exports/:id/link
+		api.GET("/exports/download", dataExportController.Download)
 	}
 
 	// User profile routes
 	user := router.Group("/user")
 	user.Use(middleware.JWTAuth())
 	{
-		user.GET("/profile", userController.GetProfile)
-		user.PUT("/profile", userController.UpdateProfile)
 		user.DELETE("/profile", userController.DeleteUser)
+		user.PUT("/password", userController.ChangePassword)
+		user.DELETE("/password", accountLinkController.RemovePassword)
+		user.GET("/login-methods", accountLinkController.GetLoginMethods)
+		user.POST("/identities/apple", accountLinkController.LinkApple)
+		user.POST("/identities/oidc/:provider/authorize", accountLinkController.BeginOIDCLink)
+		user.POST("/identities/oidc/:provider/callback", accountLinkController.CompleteOIDCLink)
+		user.DELETE("/identities/:id", accountLinkController.UnlinkIdentity)
+		user.GET("/mfa", mfaController.GetStatus)
+		user.POST("/mfa/totp", mfaController.EnrollTOTP)
+		user.POST("/mfa/totp/confirm", mfaController.ConfirmTOTP)
+		user.DELETE("/mfa/totp", mfaController.DisableTOTP)
+		user.POST("/mfa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+		user.GET("/webauthn/credentials", webAuthnController.ListCredentials)
+		user.DELETE("/webauthn/credentials/:id", webAuthnController.DeleteCredential)
+		user.GET("/tokens", personalAccessTokenController.ListTokens)
+		user.POST("/tokens", personalAccessTokenController.CreateToken)
+		user.DELETE("/tokens/:id", personalAccessTokenController.DeleteToken)
+		user.GET("/sessions", sessionController.ListSessions)
+		user.DELETE("/sessions/:id", sessionController.RevokeSession)
+		user.POST("/export", dataExportController.RequestExport)
+		user.GET("/exports", dataExportController.ListExports)
+		user.GET("/exports/:id", dataExportController.GetExport)
+		user.POST("/exports/:id/link", dataExportController.CreateDownloadLink)
 	}
 
+	// Administration routes, guarded by the permissions of the caller's roles
+	admin := router.Group("/admin")
+	admin.Use(middleware.JWTAuth())
+	{
+		admin.GET("/users", middleware.RequirePermission(services.PermissionUsersRead), adminUserController.ListUsers)
+		admin.GET("/users/:id", middleware.RequirePermission(services.PermissionUsersRead), adminUserController.GetUser)
+		admin.PATCH("/users/:id", middleware.RequirePermission(services.PermissionUsersWrite), adminUserController.UpdateUser)
+		admin.DELETE("/users/:id", middleware.RequirePermission(services.PermissionUsersWrite), adminUserController.DeleteUser)
+		admin.POST("/users/:id/restore", middleware.RequirePermission(services.PermissionUsersWrite), adminUserController.RestoreUser)
+		admin.POST("/users/:id/suspend", middleware.RequirePermission(services.PermissionUsersWrite), adminUserController.SuspendUser)
+		admin.POST("/users/:id/unsuspend", middleware.RequirePermission(services.PermissionUsersWrite), adminUserController.UnsuspendUser)
+		admin.PUT("/users/:id/status", middleware.RequirePermission(services.PermissionUsersWrite), adminUserController.SetStatus)
+		admin.GET("/users/:id/status-history", middleware.RequirePermission(services.PermissionUsersRead), adminUserController.GetStatusHistory)
+		admin.POST("/users/:id/password-reset", middleware.RequirePermission(services.PermissionUsersWrite), adminUserController.ForcePasswordReset)
+		admin.POST("/users/:id/unlock", middleware.RequirePermission(services.PermissionUsersWrite), adminUserController.UnlockUser)
+		admin.GET("/roles", middleware.RequirePermission(services.PermissionRolesRead), roleController.ListRoles)
+		admin.GET("/users/:id/roles", middleware.RequirePermission(services.PermissionRolesRead), roleController.GetUserRoles)
+		admin.PUT("/users/:id/roles/:role", middleware.RequirePermission(services.PermissionRolesWrite), roleController.AssignRole)
+		admin.DELETE("/users/:id/roles/:role", middleware.RequirePermission(services.PermissionRolesWrite), roleController.UnassignRole)
+		admin.GET("/audit-events", middleware.RequirePermission(services.PermissionAuditRead), auditController.ListEvents)
+		admin.GET("/audit-events/export", middleware.RequirePermission(services.PermissionAuditRead), auditController.ExportEvents)
+	}
+
+	// OAuth 2.0 / OpenID Connect provider routes
+	oauth := router.Group("/oauth")
+	{
+		oauth.POST("/clients", oauthController.RegisterClient)
+		oauth.GET("/authorize", oauthController.Authorize)
+		oauth.POST("/authorize", middleware.JWTAuth(), oauthController.AuthorizeDecision)
+		oauth.POST("/token", oauthController.Token)
+		oauth.POST("/introspect", oauthController.Introspect)
+		oauth.POST("/revoke", oauthController.Revoke)
+	}
+	router.GET("/userinfo", oauthController.UserInfo)
+	router.POST("/userinfo", oauthController.UserInfo)
+
+	// Public discovery routes
+	router.GET("/.well-known/jwks.json", wellKnownController.JWKS)
+	router.GET("/.well-known/openid-configuration", wellKnownController.OpenIDConfiguration)
+
 	// Health check route
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
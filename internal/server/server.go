@@ -1,9 +1,15 @@
 package server
 
 import (
+	"context"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/danigrb.dev/user-service/internal/config"
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,6 +29,13 @@ func CreateNewServer() *Server {
 	engine := gin.New()
 	engine.Use(gin.Logger(), gin.Recovery())
 
+	// Only take the client IP from X-Forwarded-For when the request comes through one of our
+	// proxies (comma separated IPs or CIDRs). By default no proxy is trusted, so headers sent by
+	// clients can't spoof the address used by login throttling, audit events and sessions.
+	if err := engine.SetTrustedProxies(config.List("TRUSTED_PROXIES")); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	server := &Server{
 		Engine: engine,
 	}
@@ -32,7 +45,9 @@ func CreateNewServer() *Server {
 	return server
 }
 
-// Run starts the Gin server on the specified port.
+// Run starts the Gin server on the specified port and blocks until SIGINT or SIGTERM.
+// It then stops accepting connections and waits up to SHUTDOWN_TIMEOUT for in-flight
+// requests to finish.
 func (s *Server) Run() {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -40,7 +55,26 @@ func (s *Server) Run() {
 	}
 	log.Printf("Starting user-service on port %s", port)
 	//TODO: change to RunTLS, generateCerts, startTLS
-	if err := s.Engine.Run(":" + port); err != nil {
+	httpServer := &http.Server{Addr: ":" + port, Handler: s.Engine}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down user-service")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Duration("SHUTDOWN_TIMEOUT", 10*time.Second))
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to finish in-flight requests: %v", err)
 	}
 }
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+var (
+	// ErrLastLoginMethod is returned when removing a login method would lock the user out
+	ErrLastLoginMethod = errors.New("cannot remove the last way to sign in to this account")
+
+	// ErrIdentityAlreadyLinked is returned when an identity belongs to another account
+	ErrIdentityAlreadyLinked = errors.New("this identity is already linked to another account")
+
+	// ErrIdentityNotFound is returned for unknown identities
+	ErrIdentityNotFound = errors.New("identity not found")
+
+	// ErrNoPassword is returned when removing the password of an account without one
+	ErrNoPassword = errors.New("this account has no password")
+)
+
+// LoginMethods lists the ways a user can sign in
+type LoginMethods struct {
+	Password   bool                        `json:"password"`
+	Identities []models.UserIdentity       `json:"identities"`
+	Passkeys   []models.WebAuthnCredential `json:"passkeys"`
+}
+
+// Count returns the number of independent login methods
+func (m *LoginMethods) Count() int {
+	count := len(m.Identities) + len(m.Passkeys)
+	if m.Password {
+		count++
+	}
+	return count
+}
+
+// AccountLinkService attaches and detaches login methods (password, external identities
+// and passkeys), making sure every account keeps at least one way to sign in
+type AccountLinkService struct {
+	userRepo        interfaces.UserRepository
+	identityRepo    interfaces.IdentityRepository
+	credentialRepo  interfaces.WebAuthnCredentialRepository
+	loginMethodRepo interfaces.LoginMethodRepository
+}
+
+// NewAccountLinkService creates a new AccountLinkService with repositories from the factory
+func NewAccountLinkService() *AccountLinkService {
+	factory := repositories.NewFactory()
+	return &AccountLinkService{
+		userRepo:        factory.GetUserRepository(),
+		identityRepo:    factory.GetIdentityRepository(),
+		credentialRepo:  factory.GetWebAuthnCredentialRepository(),
+		loginMethodRepo: factory.GetLoginMethodRepository(),
+	}
+}
+
+// Methods returns the login methods of a user
+func (s *AccountLinkService) Methods(userID uint) (*LoginMethods, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	identities, err := s.identityRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.credentialRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginMethods{
+		Password:   user.PasswordHash != nil,
+		Identities: identities,
+		Passkeys:   passkeys,
+	}, nil
+}
+
+// LinkIdentity attaches a verified external identity to the user's account.
+// Linking an identity the user already has just refreshes it.
+func (s *AccountLinkService) LinkIdentity(userID uint, identity *FederatedIdentity) (*models.UserIdentity, error) {
+	existing, err := s.identityRepo.FindByProviderSubject(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.UserID != userID {
+			return nil, ErrIdentityAlreadyLinked
+		}
+		if identity.Email != "" {
+			existing.Email = identity.Email
+			existing.EmailVerified = identity.EmailVerified
+		}
+		if err := s.identityRepo.Update(existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	linked := newUserIdentity(identity)
+	linked.UserID = userID
+	linked.LastLoginAt = nil
+	if err := s.identityRepo.Create(linked); err != nil {
+		return nil, err
+	}
+	return linked, nil
+}
+
+// UnlinkIdentity detaches an external identity from the user's account
+func (s *AccountLinkService) UnlinkIdentity(userID, identityID uint) error {
+	removal, err := s.loginMethodRepo.DeleteIdentity(userID, identityID)
+	if err != nil {
+		return err
+	}
+	return loginMethodRemovalError(removal, ErrIdentityNotFound)
+}
+
+// RemovePasskey deletes one of the user's WebAuthn credentials
+func (s *AccountLinkService) RemovePasskey(userID, credentialID uint) error {
+	removal, err := s.loginMethodRepo.DeletePasskey(userID, credentialID)
+	if err != nil {
+		return err
+	}
+	return loginMethodRemovalError(removal, ErrWebAuthnCredentialNotFound)
+}
+
+// RemovePassword clears the user's password so they can only sign in through other methods
+func (s *AccountLinkService) RemovePassword(userID uint) error {
+	removal, err := s.loginMethodRepo.RemovePassword(userID)
+	if err != nil {
+		return err
+	}
+	return loginMethodRemovalError(removal, ErrNoPassword)
+}
+
+// loginMethodRemovalError maps the outcome of a removal to an error, notFound being the
+// error for a method the user doesn't have
+func loginMethodRemovalError(removal interfaces.LoginMethodRemoval, notFound error) error {
+	switch removal {
+	case interfaces.LoginMethodRemoved:
+		return nil
+	case interfaces.LoginMethodLast:
+		return ErrLastLoginMethod
+	case interfaces.LoginMethodUserNotFound:
+		return ErrUserNotFound
+	default:
+		return notFound
+	}
+}
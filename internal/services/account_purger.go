@@ -0,0 +1,71 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+)
+
+// accountPurgeBatchSize is how many accounts are purged per query
+const accountPurgeBatchSize = 100
+
+// AccountPurger permanently erases deleted accounts, and everything linked to them,
+// once their grace period has ended. Every replica may run one: purging an account twice
+// is a no-op, and accounts restored or deleted again in the meantime are skipped.
+type AccountPurger struct {
+	userRepo interfaces.UserRepository
+	interval time.Duration
+}
+
+// NewAccountPurger creates a new AccountPurger running every ACCOUNT_PURGE_INTERVAL
+func NewAccountPurger() *AccountPurger {
+	factory := repositories.NewFactory()
+	return &AccountPurger{
+		userRepo: factory.GetUserRepository(),
+		interval: config.Duration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+	}
+}
+
+// Start purges expired accounts now and then periodically in the background
+func (p *AccountPurger) Start() {
+	go p.run()
+}
+
+// PurgeExpired erases every account whose grace period has ended and returns how many it purged
+func (p *AccountPurger) PurgeExpired() (int, error) {
+	purged := 0
+	for {
+		ids, err := p.userRepo.FindPurgeable(time.Now(), accountPurgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, id := range ids {
+			if err := p.userRepo.Purge(id); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(ids) < accountPurgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// run purges expired accounts on every tick
+func (p *AccountPurger) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.PurgeExpired()
+		if err != nil {
+			log.Printf("Failed to purge deleted accounts: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted accounts", purged)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// ErrCannotModifySelf is returned when an admin tries to change the status of their own account
+var ErrCannotModifySelf = errors.New("admins can't change the status of their own account")
+
+// ErrInvalidCursor is returned for malformed pagination cursors or cursors used with a
+// different sort order than the listing that produced them
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// UserPage is one page of a user listing. NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []models.User `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// encodedCursor is the JSON inside an opaque cursor
+type encodedCursor struct {
+	Field      string `json:"f"`
+	Descending bool   `json:"d,omitempty"`
+	Value      string `json:"v,omitempty"`
+	ID         uint   `json:"id"`
+}
+
+// AdminUserUpdate holds the fields an admin may change; nil fields are left untouched
+type AdminUserUpdate struct {
+	Email         *string `json:"email" binding:"omitempty,email"`
+	Username      *string `json:"username" binding:"omitempty,min=3,max=30"`
+	AvatarURL     *string `json:"avatar_url"`
+	EmailVerified *bool   `json:"email_verified"`
+}
+
+// AdminUserDetails is a user together with the roles assigned to them and the end of the
+// lockout caused by failed logins, if any
+type AdminUserDetails struct {
+	*models.User
+	Roles       []models.Role `json:"roles"`
+	LockedUntil *time.Time    `json:"locked_until,omitempty"`
+}
+
+// AdminUserService implements user management for support staff
+type AdminUserService struct {
+	userRepo             interfaces.UserRepository
+	userService          *UserService
+	rbacService          *RBACService
+	passwordResetService *PasswordResetService
+	refreshTokenService  *RefreshTokenService
+	revocationService    *RevocationService
+	userStatusService    *UserStatusService
+	loginThrottle        *LoginThrottle
+	maxPageSize          int
+}
+
+// NewAdminUserService creates a new AdminUserService instance with repositories from the factory
+func NewAdminUserService() *AdminUserService {
+	factory := repositories.NewFactory()
+	return &AdminUserService{
+		userRepo:             factory.GetUserRepository(),
+		userService:          NewUserService(),
+		rbacService:          NewRBACService(),
+		passwordResetService: NewPasswordResetService(),
+		refreshTokenService:  NewRefreshTokenService(),
+		revocationService:    GetRevocationService(),
+		userStatusService:    GetUserStatusService(),
+		loginThrottle:        GetLoginThrottle(),
+		maxPageSize:          config.Int("ADMIN_MAX_PAGE_SIZE", 100),
+	}
+}
+
+// ListUsers returns up to limit users matching the filter in the given order, starting
+// after the position of an opaque cursor returned by a previous call ("" for the first page)
+func (s *AdminUserService) ListUsers(ctx context.Context, filter interfaces.UserFilter, sort interfaces.UserSort, cursor string, limit int) (*UserPage, error) {
+	if limit < 1 || limit > s.maxPageSize {
+		limit = s.maxPageSize
+	}
+	if sort.Field == "" {
+		sort.Field = "created_at"
+	}
+
+	position := interfaces.UserCursor{Sort: sort}
+	if cursor != "" {
+		decoded, err := decodeUserCursor(cursor)
+		if err != nil || decoded.Sort != sort {
+			return nil, ErrInvalidCursor
+		}
+		position = *decoded
+	}
+
+	// Fetch one extra user to learn whether there is another page
+	users, err := s.userRepo.List(ctx, filter, position, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		if page.NextCursor, err = encodeUserCursor(sort, &users[limit-1]); err != nil {
+			return nil, err
+		}
+	}
+	if page.Users == nil {
+		page.Users = []models.User{}
+	}
+	return page, nil
+}
+
+// GetUser returns a user with their roles and lockout
+func (s *AdminUserService) GetUser(id uint) (*AdminUserDetails, error) {
+	user, err := s.userService.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.rbacService.UserRoles(id)
+	if err != nil {
+		return nil, err
+	}
+	lockedUntil, err := s.loginThrottle.AccountLockedUntil(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminUserDetails{User: user, Roles: roles, LockedUntil: lockedUntil}, nil
+}
+
+// UpdateUser changes account fields on behalf of the user
+func (s *AdminUserService) UpdateUser(id uint, update *AdminUserUpdate) (*models.User, error) {
+	updates := make(map[string]any)
+	if update.Email != nil {
+		updates["email"] = strings.TrimSpace(*update.Email)
+	}
+	if update.Username != nil {
+		updates["username"] = *update.Username
+	}
+	if update.AvatarURL != nil {
+		updates["avatar_url"] = *update.AvatarURL
+	}
+
+	user, err := s.userService.UpdateUserProfile(id, updates)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.EmailVerified != nil && *update.EmailVerified != user.IsEmailVerified() {
+		if *update.EmailVerified {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		} else {
+			user.EmailVerifiedAt = nil
+		}
+		if err := s.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+		if err := s.userStatusService.ActivatePending(user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// SetStatus changes the lifecycle status of an account on behalf of actorID.
+// Suspending, banning or deleting an account signs the user out everywhere.
+func (s *AdminUserService) SetStatus(actorID, id uint, status models.UserStatus, reason string) (*models.User, error) {
+	if actorID == id {
+		return nil, ErrCannotModifySelf
+	}
+	return s.userStatusService.Transition(id, status, reason, &actorID)
+}
+
+// StatusHistory returns the status transitions of an account, oldest first
+func (s *AdminUserService) StatusHistory(id uint) ([]models.UserStatusTransition, error) {
+	if _, err := s.userService.GetUserByID(id); err != nil {
+		return nil, err
+	}
+	return s.userStatusService.History(id)
+}
+
+// ForcePasswordReset invalidates the user's password, signs them out everywhere and
+// emails them a reset link. Used when an account is believed to be compromised.
+func (s *AdminUserService) ForcePasswordReset(id uint) error {
+	user, err := s.userService.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = nil
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	if err := s.signOutEverywhere(id); err != nil {
+		return err
+	}
+	return s.passwordResetService.SendResetEmail(user)
+}
+
+// UnlockUser lifts the lockout of an account that had too many failed logins
+func (s *AdminUserService) UnlockUser(id uint) error {
+	_, err := s.loginThrottle.UnlockUser(id)
+	return err
+}
+
+// DeleteUser schedules an account for purging; it can be restored until the grace period ends
+func (s *AdminUserService) DeleteUser(actorID, id uint) (*models.User, error) {
+	if actorID == id {
+		return nil, ErrCannotModifySelf
+	}
+	return s.userStatusService.ScheduleDeletion(id, "deleted by an admin", &actorID)
+}
+
+// RestoreUser cancels the deletion of an account
+func (s *AdminUserService) RestoreUser(actorID, id uint) (*models.User, error) {
+	return s.userStatusService.Restore(id, "restored by an admin", &actorID)
+}
+
+// signOutEverywhere revokes every refresh and access token of the user
+func (s *AdminUserService) signOutEverywhere(userID uint) error {
+	if err := s.refreshTokenService.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return s.revocationService.RevokeAllForUser(userID)
+}
+
+// encodeUserCursor returns the opaque cursor positioned after the user
+func encodeUserCursor(sort interfaces.UserSort, last *models.User) (string, error) {
+	cursor := encodedCursor{Field: sort.Field, Descending: sort.Descending, ID: last.ID}
+	switch sort.Field {
+	case "created_at":
+		cursor.Value = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "email":
+		cursor.Value = last.Email
+	case "username":
+		cursor.Value = last.Username
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeUserCursor parses an opaque cursor
+func decodeUserCursor(cursor string) (*interfaces.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var decoded encodedCursor
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	if decoded.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if decoded.Field == "created_at" {
+		if _, err := time.Parse(time.RFC3339Nano, decoded.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &interfaces.UserCursor{
+		Sort:      interfaces.UserSort{Field: decoded.Field, Descending: decoded.Descending},
+		LastValue: decoded.Value,
+		LastID:    decoded.ID,
+	}, nil
+}
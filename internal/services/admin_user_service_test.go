@@ -0,0 +1,49 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+func TestDecodeUserCursor(t *testing.T) {
+	sort := interfaces.UserSort{Field: "created_at", Descending: true}
+	createdAt := time.Date(2026, 3, 1, 12, 30, 0, 500, time.UTC)
+	valid, err := encodeUserCursor(sort, &models.User{ID: 7, CreatedAt: createdAt})
+	if err != nil {
+		t.Fatalf("encodeUserCursor error: %v", err)
+	}
+
+	decoded, err := decodeUserCursor(valid)
+	if err != nil {
+		t.Fatalf("decodeUserCursor error: %v", err)
+	}
+	if decoded.Sort != sort || decoded.LastID != 7 || decoded.LastValue != createdAt.Format(time.RFC3339Nano) {
+		t.Errorf("decodeUserCursor = %+v", decoded)
+	}
+
+	tests := []struct {
+		name   string
+		cursor encodedCursor
+	}{
+		{"missing id", encodedCursor{Field: "created_at", Value: createdAt.Format(time.RFC3339Nano)}},
+		{"malformed created_at", encodedCursor{Field: "created_at", Value: "yesterday", ID: 7}},
+		{"empty created_at", encodedCursor{Field: "created_at", ID: 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := decodeUserCursor(base64.RawURLEncoding.EncodeToString(data)); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeUserCursor error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
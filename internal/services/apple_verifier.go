@@ -0,0 +1,136 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const appleIssuer = "https://appleid.apple.com"
+
+// ErrInvalidAppleToken is returned when an Apple identity token fails verification
+var ErrInvalidAppleToken = errors.New("invalid Apple identity token")
+
+// AppleIdentity holds the verified claims of an Apple identity token
+type AppleIdentity struct {
+	Subject        string
+	Email          string
+	EmailVerified  bool
+	IsPrivateEmail bool
+}
+
+// appleClaims are the claims Apple puts in identity tokens.
+// Apple encodes the boolean claims either as JSON booleans or as "true"/"false" strings.
+type appleClaims struct {
+	jwt.RegisteredClaims
+	Email          string `json:"email"`
+	EmailVerified  any    `json:"email_verified"`
+	IsPrivateEmail any    `json:"is_private_email"`
+	Nonce          string `json:"nonce"`
+}
+
+// AppleVerifier verifies Sign in with Apple identity tokens
+type AppleVerifier struct {
+	keys         KeySource
+	clientIDs    []string
+	requireNonce bool
+	leeway       time.Duration
+}
+
+// NewAppleVerifier creates an AppleVerifier configured from the environment.
+// APPLE_CLIENT_IDS lists the bundle and service IDs accepted as audience.
+func NewAppleVerifier() *AppleVerifier {
+	keys := NewRemoteJWKS(
+		config.String("APPLE_JWKS_URL", "https://appleid.apple.com/auth/keys"),
+		config.Duration("APPLE_JWKS_CACHE_TTL", 24*time.Hour),
+	)
+	return NewAppleVerifierWithKeySource(keys, config.List("APPLE_CLIENT_IDS"))
+}
+
+// NewAppleVerifierWithKeySource creates an AppleVerifier using a specific key source.
+// This is useful for testing against locally generated keys.
+func NewAppleVerifierWithKeySource(keys KeySource, clientIDs []string) *AppleVerifier {
+	return &AppleVerifier{
+		keys:         keys,
+		clientIDs:    clientIDs,
+		requireNonce: config.Bool("APPLE_REQUIRE_NONCE", true),
+		leeway:       config.Duration("APPLE_TOKEN_LEEWAY", time.Minute),
+	}
+}
+
+// Verify checks the identity token's signature, issuer, audience and expiry and,
+// when present, that its nonce claim is the SHA-256 hash of the raw nonce the client
+// generated for this sign-in. It returns the verified identity.
+func (v *AppleVerifier) Verify(identityToken, nonce string) (*AppleIdentity, error) {
+	if len(v.clientIDs) == 0 {
+		return nil, errors.New("Apple sign in is not configured")
+	}
+
+	var claims appleClaims
+	token, err := jwt.ParseWithClaims(identityToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.Key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(appleIssuer),
+		jwt.WithAudience(v.clientIDs...),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.leeway),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAppleToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidAppleToken)
+	}
+
+	if err := v.verifyNonce(claims.Nonce, nonce); err != nil {
+		return nil, err
+	}
+
+	return &AppleIdentity{
+		Subject:        claims.Subject,
+		Email:          claims.Email,
+		EmailVerified:  appleBool(claims.EmailVerified),
+		IsPrivateEmail: appleBool(claims.IsPrivateEmail),
+	}, nil
+}
+
+// verifyNonce compares the token's nonce claim with the hash of the raw nonce from the client
+func (v *AppleVerifier) verifyNonce(claimed, raw string) error {
+	if claimed == "" && raw == "" {
+		if v.requireNonce {
+			return fmt.Errorf("%w: nonce required", ErrInvalidAppleToken)
+		}
+		return nil
+	}
+	if claimed == "" || raw == "" {
+		return fmt.Errorf("%w: nonce mismatch", ErrInvalidAppleToken)
+	}
+
+	sum := sha256.Sum256([]byte(raw))
+	expected := hex.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(claimed), []byte(expected)) != 1 {
+		return fmt.Errorf("%w: nonce mismatch", ErrInvalidAppleToken)
+	}
+	return nil
+}
+
+// appleBool interprets a boolean claim that may be encoded as a bool or a string
+func appleBool(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
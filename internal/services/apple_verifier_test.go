@@ -0,0 +1,151 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signAppleToken signs claims as an Apple identity token with the given key and kid
+func signAppleToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+// appleTokenClaims returns the claims of a valid identity token for the nonce
+func appleTokenClaims(nonce string) jwt.MapClaims {
+	sum := sha256.Sum256([]byte(nonce))
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":              appleIssuer,
+		"aud":              "com.example.app",
+		"sub":              "001234.abcdef",
+		"iat":              now.Unix(),
+		"exp":              now.Add(10 * time.Minute).Unix(),
+		"email":            "ada@privaterelay.appleid.com",
+		"email_verified":   "true",
+		"is_private_email": true,
+		"nonce":            hex.EncodeToString(sum[:]),
+	}
+}
+
+func TestAppleVerifierVerify(t *testing.T) {
+	server := newTestJWKSServer(t)
+	key := server.addKey(t, "apple-1")
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	verifier := NewAppleVerifierWithKeySource(NewRemoteJWKS(server.URL, time.Hour), []string{"com.example.app", "com.example.web"})
+
+	token := signAppleToken(t, key, "apple-1", appleTokenClaims("raw-nonce"))
+	identity, err := verifier.Verify(token, "raw-nonce")
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	want := AppleIdentity{Subject: "001234.abcdef", Email: "ada@privaterelay.appleid.com", EmailVerified: true, IsPrivateEmail: true}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	withClaim := func(name string, value any) jwt.MapClaims {
+		claims := appleTokenClaims("raw-nonce")
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	tests := map[string]struct {
+		token string
+		nonce string
+	}{
+		"wrong issuer":      {signAppleToken(t, key, "apple-1", withClaim("iss", "https://evil.example")), "raw-nonce"},
+		"wrong audience":    {signAppleToken(t, key, "apple-1", withClaim("aud", "com.other.app")), "raw-nonce"},
+		"expired":           {signAppleToken(t, key, "apple-1", withClaim("exp", time.Now().Add(-2*time.Minute).Unix())), "raw-nonce"},
+		"no expiry":         {signAppleToken(t, key, "apple-1", withClaim("exp", nil)), "raw-nonce"},
+		"no subject":        {signAppleToken(t, key, "apple-1", withClaim("sub", nil)), "raw-nonce"},
+		"nonce mismatch":    {token, "other-nonce"},
+		"nonce not hashed":  {signAppleToken(t, key, "apple-1", withClaim("nonce", "raw-nonce")), "raw-nonce"},
+		"missing nonce":     {token, ""},
+		"no nonce claim":    {signAppleToken(t, key, "apple-1", withClaim("nonce", nil)), "raw-nonce"},
+		"no nonce at all":   {signAppleToken(t, key, "apple-1", withClaim("nonce", nil)), ""},
+		"unknown kid":       {signAppleToken(t, key, "apple-2", appleTokenClaims("raw-nonce")), "raw-nonce"},
+		"wrong signing key": {signAppleToken(t, otherKey, "apple-1", appleTokenClaims("raw-nonce")), "raw-nonce"},
+		"malformed":         {"not.a.token", "raw-nonce"},
+	}
+	for name, tt := range tests {
+		if _, err := verifier.Verify(tt.token, tt.nonce); !errors.Is(err, ErrInvalidAppleToken) {
+			t.Errorf("%s: got %v, want ErrInvalidAppleToken", name, err)
+		}
+	}
+}
+
+func TestAppleVerifierRejectsOtherAlgorithms(t *testing.T) {
+	server := newTestJWKSServer(t)
+	server.addKey(t, "apple-1")
+	verifier := NewAppleVerifierWithKeySource(NewRemoteJWKS(server.URL, time.Hour), []string{"com.example.app"})
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, appleTokenClaims("raw-nonce"))
+	token.Header["kid"] = "apple-1"
+	signed, err := token.SignedString(ecKey)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	if _, err := verifier.Verify(signed, "raw-nonce"); !errors.Is(err, ErrInvalidAppleToken) {
+		t.Errorf("ES256 token: got %v, want ErrInvalidAppleToken", err)
+	}
+}
+
+func TestAppleVerifierFollowsKeyRotation(t *testing.T) {
+	server := newTestJWKSServer(t)
+	oldKey := server.addKey(t, "apple-1")
+	keys := NewRemoteJWKS(server.URL, time.Hour)
+	keys.minRefresh = 0
+	verifier := NewAppleVerifierWithKeySource(keys, []string{"com.example.app"})
+
+	if _, err := verifier.Verify(signAppleToken(t, oldKey, "apple-1", appleTokenClaims("n")), "n"); err != nil {
+		t.Fatalf("token signed with the first key: %v", err)
+	}
+
+	// Apple publishes a new key: tokens signed with it are accepted without waiting for the cache
+	newKey := server.addKey(t, "apple-2")
+	if _, err := verifier.Verify(signAppleToken(t, newKey, "apple-2", appleTokenClaims("n")), "n"); err != nil {
+		t.Errorf("token signed with the rotated key: %v", err)
+	}
+}
+
+func TestAppleVerifierOptionalNonce(t *testing.T) {
+	t.Setenv("APPLE_REQUIRE_NONCE", "false")
+	server := newTestJWKSServer(t)
+	key := server.addKey(t, "apple-1")
+	verifier := NewAppleVerifierWithKeySource(NewRemoteJWKS(server.URL, time.Hour), []string{"com.example.app"})
+
+	claims := appleTokenClaims("")
+	delete(claims, "nonce")
+	if _, err := verifier.Verify(signAppleToken(t, key, "apple-1", claims), ""); err != nil {
+		t.Errorf("token without nonce rejected while nonces are optional: %v", err)
+	}
+	if _, err := verifier.Verify(signAppleToken(t, key, "apple-1", claims), "raw-nonce"); !errors.Is(err, ErrInvalidAppleToken) {
+		t.Errorf("nonce sent but not in the token: got %v, want ErrInvalidAppleToken", err)
+	}
+}
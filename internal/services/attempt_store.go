@@ -0,0 +1,149 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// AttemptStore keeps the failed login counters of LoginThrottle and the second factor counters
+// of MFAService. Counters are keyed by strings such as "account:<email>", "ip:<address>" or
+// "mfa:<user ID>".
+type AttemptStore interface {
+	// Find returns the counter of a key, or nil if it has none
+	Find(key string) (*models.LoginAttempt, error)
+
+	// RecordFailure atomically counts a failure at the given time, opening a new window
+	// if the last one expired, and returns the updated counter. allow is called with the
+	// counter as it was (zero for new keys) while no other failure can be recorded for the
+	// key; if it returns an error, nothing is recorded and the error is returned.
+	RecordFailure(key string, at time.Time, window time.Duration, allow func(current *models.LoginAttempt) error) (*models.LoginAttempt, error)
+
+	// Lock locks a key until the given time and starts counting its failures again
+	Lock(key string, until time.Time) error
+
+	// Reset clears the failures and lock of a key
+	Reset(key string) error
+
+	// DeleteExpired drops the counters whose window and lock have both ended
+	DeleteExpired(now time.Time) error
+}
+
+// The database store is the repository itself
+var _ AttemptStore = interfaces.LoginAttemptRepository(nil)
+
+var (
+	attemptStoreInstance AttemptStore
+	attemptStoreOnce     sync.Once
+)
+
+// GetAttemptStore returns the process-wide AttemptStore, so that in-memory counters are shared
+// by every service that limits attempts
+func GetAttemptStore() AttemptStore {
+	attemptStoreOnce.Do(func() {
+		attemptStoreInstance = NewAttemptStore()
+	})
+	return attemptStoreInstance
+}
+
+// NewAttemptStore returns the AttemptStore selected by LOGIN_ATTEMPT_STORE: "memory" (the default),
+// which only suits a single replica, or "database" which shares the counters through Postgres
+func NewAttemptStore() AttemptStore {
+	switch config.String("LOGIN_ATTEMPT_STORE", "memory") {
+	case "database":
+		return repositories.NewFactory().GetLoginAttemptRepository()
+	default:
+		return NewMemoryAttemptStore()
+	}
+}
+
+// MemoryAttemptStore keeps the counters in process memory
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+}
+
+// Ensure MemoryAttemptStore implements AttemptStore
+var _ AttemptStore = (*MemoryAttemptStore)(nil)
+
+// NewMemoryAttemptStore creates a new, empty MemoryAttemptStore
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: make(map[string]models.LoginAttempt)}
+}
+
+// Find returns a copy of the counter of a key
+func (m *MemoryAttemptStore) Find(key string) (*models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt, ok := m.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+// RecordFailure counts a failure for a key if allow accepts its current counter
+func (m *MemoryAttemptStore) RecordFailure(key string, at time.Time, window time.Duration, allow func(current *models.LoginAttempt) error) (*models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt, ok := m.attempts[key]
+	if !ok {
+		attempt.Key = key
+	}
+	current := attempt
+	if err := allow(&current); err != nil {
+		return nil, err
+	}
+
+	if !at.Before(attempt.WindowExpiresAt) {
+		attempt.Key = key
+		attempt.Failures = 0
+		attempt.WindowExpiresAt = at.Add(window)
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = at
+	m.attempts[key] = attempt
+	return &attempt, nil
+}
+
+// Lock locks a key and resets its failures
+func (m *MemoryAttemptStore) Lock(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt, ok := m.attempts[key]
+	if !ok {
+		return nil
+	}
+	attempt.Failures = 0
+	attempt.LockedUntil = &until
+	m.attempts[key] = attempt
+	return nil
+}
+
+// Reset forgets a key
+func (m *MemoryAttemptStore) Reset(key string) error {
+	m.mu.Lock()
+	delete(m.attempts, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// DeleteExpired forgets the keys that no longer count failures or lock anything
+func (m *MemoryAttemptStore) DeleteExpired(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, attempt := range m.attempts {
+		if now.After(attempt.WindowExpiresAt) && !attempt.IsLocked(now) {
+			delete(m.attempts, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// Audited actions
+const (
+	AuditActionRegister            = "auth.register"
+	AuditActionLogin               = "auth.login"
+	AuditActionLoginFailed         = "auth.login_failed"
+	AuditActionMFAVerified         = "auth.mfa_verified"
+	AuditActionMFAFailed           = "auth.mfa_failed"
+	AuditActionRefreshTokenReused  = "auth.refresh_token_reused"
+	AuditActionLogout              = "auth.logout"
+	AuditActionLogoutAll           = "auth.logout_all"
+	AuditActionAccountRestored     = "auth.account_restored"
+	AuditActionAccountLocked       = "auth.account_locked"
+	AuditActionAccountUnlocked     = "auth.account_unlocked"
+	AuditActionProfileUpdated      = "user.profile_updated"
+	AuditActionEmailChanged        = "user.email_changed"
+	AuditActionPasswordChanged     = "user.password_changed"
+	AuditActionPasswordReset       = "user.password_reset"
+	AuditActionAccountDeleted      = "user.deleted"
+	AuditActionTokenCreated        = "user.token_created"
+	AuditActionTokenDeleted        = "user.token_deleted"
+	AuditActionSessionRevoked      = "user.session_revoked"
+	AuditActionMFAEnabled          = "user.mfa_enabled"
+	AuditActionMFADisabled         = "user.mfa_disabled"
+	AuditActionRecoveryCodesReset  = "user.recovery_codes_regenerated"
+	AuditActionPasskeyRegistered   = "user.passkey_registered"
+	AuditActionPasskeyRemoved      = "user.passkey_removed"
+	AuditActionIdentityLinked      = "user.identity_linked"
+	AuditActionIdentityUnlinked    = "user.identity_unlinked"
+	AuditActionPasswordRemoved     = "user.password_removed"
+	AuditActionAdminUserUpdated    = "admin.user_updated"
+	AuditActionAdminStatusChanged  = "admin.user_status_changed"
+	AuditActionAdminUserDeleted    = "admin.user_deleted"
+	AuditActionAdminUserRestored   = "admin.user_restored"
+	AuditActionAdminPasswordForced = "admin.password_reset_forced"
+	AuditActionAdminUserUnlocked   = "admin.user_unlocked"
+	AuditActionAdminRoleAssigned   = "admin.role_assigned"
+	AuditActionAdminRoleUnassigned = "admin.role_unassigned"
+)
+
+var (
+	auditServiceInstance *AuditService
+	auditServiceOnce     sync.Once
+)
+
+// ErrInvalidAuditCursor is returned for malformed audit event cursors
+var ErrInvalidAuditCursor = errors.New("invalid cursor")
+
+// AuditEventPage is a page of audit events and the cursor of the next page ("" on the last page)
+type AuditEventPage struct {
+	Events     []models.AuditEvent `json:"events"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// AuditService records security-relevant events and lets admins query them.
+// Record never blocks: events are queued in a buffer of AUDIT_BUFFER_SIZE and written
+// in batches by a background worker. When the buffer is full, events are dropped and
+// logged rather than slowing down requests.
+// Batches the database rejects are retried up to AUDIT_WRITE_RETRIES times with exponential
+// backoff, then appended to AUDIT_SPILL_FILE (if set) as JSON lines, which are written to the
+// database the next time the service starts.
+type AuditService struct {
+	auditRepo     interfaces.AuditEventRepository
+	events        chan models.AuditEvent
+	batchSize     int
+	flushInterval time.Duration
+	maxPageSize   int
+	maxExport     int
+	writeRetries  int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	spillPath     string
+	dropped       atomic.Int64
+
+	closing   chan struct{}
+	stopped   chan struct{}
+	closed    atomic.Bool
+	closeOnce sync.Once
+}
+
+// GetAuditService returns the process-wide AuditService, starting its writer on first use.
+// It also contributes the audit_events section to data exports.
+func GetAuditService() *AuditService {
+	auditServiceOnce.Do(func() {
+		factory := repositories.NewFactory()
+		auditServiceInstance = &AuditService{
+			auditRepo:     factory.GetAuditEventRepository(),
+			events:        make(chan models.AuditEvent, config.Int("AUDIT_BUFFER_SIZE", 1024)),
+			batchSize:     config.Int("AUDIT_BATCH_SIZE", 100),
+			flushInterval: config.Duration("AUDIT_FLUSH_INTERVAL", time.Second),
+			maxPageSize:   config.Int("AUDIT_MAX_PAGE_SIZE", 200),
+			maxExport:     config.Int("AUDIT_EXPORT_MAX_EVENTS", 100000),
+			writeRetries:  config.Int("AUDIT_WRITE_RETRIES", 5),
+			retryDelay:    config.Duration("AUDIT_RETRY_DELAY", 500*time.Millisecond),
+			maxRetryDelay: config.Duration("AUDIT_MAX_RETRY_DELAY", 30*time.Second),
+			spillPath:     config.String("AUDIT_SPILL_FILE", ""),
+			closing:       make(chan struct{}),
+			stopped:       make(chan struct{}),
+		}
+		go auditServiceInstance.runWriter()
+
+		RegisterExportSection("audit_events", ExportSectionFunc(auditServiceInstance.exportUserEvents))
+	})
+	return auditServiceInstance
+}
+
+// Record queues an event for writing without waiting for the database
+func (s *AuditService) Record(event models.AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	if s.closed.Load() {
+		log.Printf("Audit service closed, dropping event %s", event.Action)
+		return
+	}
+	select {
+	case s.events <- event:
+	default:
+		if dropped := s.dropped.Add(1); dropped == 1 || dropped%100 == 0 {
+			log.Printf("Audit buffer full, %d events dropped so far (last: %s)", dropped, event.Action)
+		}
+	}
+}
+
+// List returns a page of events matching the filter, newest first
+func (s *AuditService) List(ctx context.Context, filter interfaces.AuditEventFilter, cursor string, limit int) (*AuditEventPage, error) {
+	beforeID, err := decodeAuditCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > s.maxPageSize {
+		limit = s.maxPageSize
+	}
+
+	// Fetch one extra event to know whether there is a next page
+	events, err := s.auditRepo.List(ctx, filter, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AuditEventPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = strconv.FormatUint(uint64(page.Events[limit-1].ID), 10)
+	}
+	if page.Events == nil {
+		page.Events = []models.AuditEvent{}
+	}
+	return page, nil
+}
+
+// Export passes every event matching the filter, newest first, to write in batches,
+// up to AUDIT_EXPORT_MAX_EVENTS
+func (s *AuditService) Export(ctx context.Context, filter interfaces.AuditEventFilter, write func([]models.AuditEvent) error) error {
+	var beforeID uint
+	exported := 0
+	for exported < s.maxExport {
+		limit := min(500, s.maxExport-exported)
+		events, err := s.auditRepo.List(ctx, filter, beforeID, limit)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		if err := write(events); err != nil {
+			return err
+		}
+		exported += len(events)
+		beforeID = events[len(events)-1].ID
+		if len(events) < limit {
+			return nil
+		}
+	}
+	return nil
+}
+
+// UserChanges returns the audited profile fields that differ between two versions of a user
+func UserChanges(before, after *models.User) models.AuditChanges {
+	changes := models.AuditChanges{}
+	addChange := func(field string, from, to any) {
+		if !reflect.DeepEqual(from, to) {
+			changes[field] = models.AuditChange{From: from, To: to}
+		}
+	}
+	addChange("email", before.Email, after.Email)
+	addChange("username", before.Username, after.Username)
+	addChange("avatar_url", before.AvatarURL, after.AvatarURL)
+	addChange("email_verified", before.IsEmailVerified(), after.IsEmailVerified())
+	addChange("status", before.Status, after.Status)
+	addChange("preferences", map[string]any(before.Preferences), map[string]any(after.Preferences))
+	return changes
+}
+
+// CloneUser returns a copy of a user that is safe to compare with UserChanges after the
+// original was modified
+func CloneUser(user *models.User) *models.User {
+	clone := *user
+	clone.Preferences = maps.Clone(user.Preferences)
+	return &clone
+}
+
+// exportUserEvents is the data export section of the events a user performed or was subject to
+func (s *AuditService) exportUserEvents(ctx context.Context, userID uint) (any, error) {
+	events := []models.AuditEvent{}
+	err := s.Export(ctx, interfaces.AuditEventFilter{UserID: &userID}, func(batch []models.AuditEvent) error {
+		events = append(events, batch...)
+		return nil
+	})
+	return events, err
+}
+
+// Close stops accepting events and waits until the queued ones have been written (or spilled,
+// if the database keeps failing). It is called on shutdown, after the HTTP server stopped
+// serving requests.
+func (s *AuditService) Close() {
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		close(s.closing)
+	})
+	<-s.stopped
+}
+
+// runWriter writes queued events in batches, at least every flush interval,
+// until Close drains the queue
+func (s *AuditService) runWriter() {
+	defer close(s.stopped)
+	s.replaySpilled()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AuditEvent, 0, s.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.writeBatch(batch)
+		batch = make([]models.AuditEvent, 0, s.batchSize)
+	}
+
+	for {
+		select {
+		case event := <-s.events:
+			batch = append(batch, event)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.closing:
+			for {
+				select {
+				case event := <-s.events:
+					batch = append(batch, event)
+					if len(batch) >= s.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeBatch writes a batch, retrying with exponential backoff while the database fails.
+// Batches that still can't be written are spilled to AUDIT_SPILL_FILE.
+func (s *AuditService) writeBatch(batch []models.AuditEvent) {
+	delay := s.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.auditRepo.CreateBatch(batch)
+		if err == nil {
+			return
+		}
+		if attempt > s.writeRetries {
+			log.Printf("Failed to write %d audit events after %d attempts: %v", len(batch), attempt, err)
+			s.spill(batch)
+			return
+		}
+
+		log.Printf("Failed to write %d audit events, retrying in %s: %v", len(batch), delay, err)
+		time.Sleep(delay)
+		delay = min(delay*2, s.maxRetryDelay)
+	}
+}
+
+// spill appends events to AUDIT_SPILL_FILE as JSON lines, or logs that they are lost
+func (s *AuditService) spill(batch []models.AuditEvent) {
+	if s.spillPath == "" {
+		log.Printf("Dropped %d audit events, set AUDIT_SPILL_FILE to keep them", len(batch))
+		return
+	}
+
+	file, err := os.OpenFile(s.spillPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("Dropped %d audit events, failed to open spill file: %v", len(batch), err)
+		return
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, event := range batch {
+		event.ID = 0
+		if err := encoder.Encode(event); err != nil {
+			log.Printf("Dropped audit events, failed to write spill file: %v", err)
+			return
+		}
+	}
+	log.Printf("Spilled %d audit events to %s", len(batch), s.spillPath)
+}
+
+// replaySpilled writes the events spilled by an earlier run and removes the spill file.
+// The file is kept if the database still fails, to be replayed on the next start.
+func (s *AuditService) replaySpilled() {
+	if s.spillPath == "" {
+		return
+	}
+	file, err := os.Open(s.spillPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to open audit spill file: %v", err)
+		}
+		return
+	}
+
+	var events []models.AuditEvent
+	decoder := json.NewDecoder(file)
+	for {
+		var event models.AuditEvent
+		if err := decoder.Decode(&event); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("Skipping the rest of the audit spill file: %v", err)
+			}
+			break
+		}
+		events = append(events, event)
+	}
+	file.Close()
+
+	total := len(events)
+	for batch := range slices.Chunk(events, max(s.batchSize, 1)) {
+		if err := s.auditRepo.CreateBatch(batch); err != nil {
+			log.Printf("Failed to replay %d spilled audit events, keeping them in %s: %v", len(events), s.spillPath, err)
+			// Keep only the events that weren't written, so they aren't duplicated next time
+			if len(events) < total {
+				if err := os.Remove(s.spillPath); err == nil {
+					s.spill(events)
+				}
+			}
+			return
+		}
+		events = events[len(batch):]
+	}
+	if err := os.Remove(s.spillPath); err != nil {
+		log.Printf("Failed to remove audit spill file: %v", err)
+		return
+	}
+	log.Printf("Replayed spilled audit events from %s", s.spillPath)
+}
+
+// decodeAuditCursor returns the ID a page of events starts below
+func decodeAuditCursor(cursor string) (uint, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidAuditCursor
+	}
+	return uint(id), nil
+}
@@ -1,5 +1,294 @@
 package services
 
 import (
-    
-)
\ No newline at end of file
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenUseAccess marks tokens that grant access to the API.
+// Other token uses (challenges, email links, ...) are rejected by ValidateAccessToken.
+const TokenUseAccess = "access"
+
+// TokenUseOAuthAccess marks access tokens issued to OAuth clients. They are limited to
+// their scopes and can't be used where first-party access tokens are expected.
+const TokenUseOAuthAccess = "oauth_access"
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, expired or fail verification
+	ErrInvalidToken = errors.New("invalid or expired token")
+
+	// ErrTokenRevoked is returned for valid tokens that were revoked server-side
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// Claims are the claims carried by every token this service signs
+type Claims struct {
+	jwt.RegisteredClaims
+	TokenUse      string   `json:"token_use"`
+	UserID        uint     `json:"user_id,omitempty"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"email_verified,omitempty"`
+	Username      string   `json:"username,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	Permissions   []string `json:"permissions,omitempty"`
+	ClientID      string   `json:"client_id,omitempty"` // OAuth client the token was issued to
+	SessionID     uint     `json:"sid,omitempty"`       // First-party session the token belongs to
+
+	// AuthTime is when the user last actively authenticated (as opposed to refreshing)
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+
+	// Challenge carries the server challenge of a stateless ceremony (e.g. WebAuthn),
+	// or the hash of a nonce held by the client the token is bound to (magic links)
+	Challenge string `json:"challenge,omitempty"`
+}
+
+// AuthenticatedWithin reports whether the user actively authenticated within maxAge
+func (c *Claims) AuthenticatedWithin(maxAge time.Duration) bool {
+	return c.AuthTime != nil && time.Since(c.AuthTime.Time) <= maxAge
+}
+
+// TokenPair is the result of a successful authentication or refresh
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	SessionID    uint
+	User         *models.User
+}
+
+// AuthService owns issuing and validating the tokens of this service
+type AuthService struct {
+	userRepo            interfaces.UserRepository
+	keyManager          *KeyManager
+	revocationService   *RevocationService
+	refreshTokenService *RefreshTokenService
+	sessionService      *SessionService
+	rbacService         *RBACService
+	issuer              string
+	audience            []string
+	accessTokenTTL      time.Duration
+	leeway              time.Duration
+}
+
+// NewAuthService creates a new AuthService configured from the environment
+func NewAuthService() *AuthService {
+	factory := repositories.NewFactory()
+	return &AuthService{
+		userRepo:            factory.GetUserRepository(),
+		keyManager:          GetKeyManager(),
+		revocationService:   GetRevocationService(),
+		refreshTokenService: NewRefreshTokenService(),
+		sessionService:      NewSessionService(),
+		rbacService:         NewRBACService(),
+		issuer:              config.String("JWT_ISSUER", "user-service"),
+		audience:            configAudience(),
+		accessTokenTTL:      config.Duration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		leeway:              config.Duration("JWT_LEEWAY", 30*time.Second),
+	}
+}
+
+// AccessTokenTTL returns the lifetime of access tokens
+func (s *AuthService) AccessTokenTTL() time.Duration {
+	return s.accessTokenTTL
+}
+
+// IssueTokens creates a refresh token in a new family for a user who just authenticated,
+// records it as a session on the device described by info and issues an access token in it
+func (s *AuthService) IssueTokens(user *models.User, info SessionInfo) (*TokenPair, error) {
+	authTime := time.Now()
+	refreshToken, record, err := s.refreshTokenService.Issue(user.ID, authTime)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.sessionService.Start(record, info)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, _, err := s.IssueAccessToken(user, authTime, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.accessTokenTTL,
+		SessionID:    session.ID,
+		User:         user,
+	}, nil
+}
+
+// RefreshTokens rotates a refresh token and issues a new access token for its owner
+// in the same session
+func (s *AuthService) RefreshTokens(refreshToken string) (*TokenPair, error) {
+	newRefreshToken, record, err := s.refreshTokenService.Rotate(refreshToken, "")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err := UserStatusError(user.Status); err != nil {
+		return nil, err
+	}
+
+	// Families issued before sessions were tracked have no session
+	var sessionID uint
+	session, err := s.sessionService.Refreshed(record)
+	if err != nil {
+		return nil, err
+	}
+	if session != nil {
+		sessionID = session.ID
+	}
+
+	accessToken, _, err := s.IssueAccessToken(user, record.AuthTime, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    s.accessTokenTTL,
+		SessionID:    sessionID,
+		User:         user,
+	}, nil
+}
+
+// IssueAccessToken creates a signed access token in the session of the user who authenticated
+// at authTime. The user's roles and permissions are embedded so that route guards don't hit the database.
+func (s *AuthService) IssueAccessToken(user *models.User, authTime time.Time, sessionID uint) (string, *Claims, error) {
+	roles, permissions, err := s.rbacService.Authorization(user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := &Claims{
+		TokenUse:      TokenUseAccess,
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		Username:      user.Username,
+		Roles:         roles,
+		Permissions:   permissions,
+		SessionID:     sessionID,
+		AuthTime:      jwt.NewNumericDate(authTime),
+	}
+	token, err := s.SignToken(claims, s.accessTokenTTL)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// SignToken fills in the registered claims (iss, aud, sub, jti, iat, nbf, exp) that are
+// not already set and signs the token with the current signing key
+func (s *AuthService) SignToken(claims *Claims, ttl time.Duration) (string, error) {
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if claims.Issuer == "" {
+		claims.Issuer = s.issuer
+	}
+	if len(claims.Audience) == 0 {
+		claims.Audience = s.audience
+	}
+	if claims.Subject == "" && claims.UserID != 0 {
+		claims.Subject = subjectForUser(claims.UserID)
+	}
+	issuedAt := now
+	if claims.UserID != 0 {
+		if issuedAt, err = s.revocationService.IssueTime(claims.UserID, now); err != nil {
+			return "", err
+		}
+	}
+	claims.ID = jti
+	claims.IssuedAt = jwt.NewNumericDate(issuedAt)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	return s.keyManager.Sign(claims)
+}
+
+// ValidateAccessToken verifies an access token, including server-side revocation
+func (s *AuthService) ValidateAccessToken(token string) (*Claims, error) {
+	return s.validateToken(token, TokenUseAccess)
+}
+
+// ValidateOAuthAccessToken verifies an access token issued to an OAuth client, including server-side revocation
+func (s *AuthService) ValidateOAuthAccessToken(token string) (*Claims, error) {
+	return s.validateToken(token, TokenUseOAuthAccess)
+}
+
+// validateToken parses a token of the given use and checks it hasn't been revoked
+func (s *AuthService) validateToken(token, tokenUse string) (*Claims, error) {
+	claims, err := s.ParseToken(token, tokenUse)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.revocationService.IsRevoked(claims.ID, claims.UserID, claims.SessionID, claims.IssuedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// ParseToken verifies the signature, issuer, audience and lifetime of a token signed by
+// this service and checks it was issued for the expected use
+func (s *AuthService) ParseToken(token, tokenUse string) (*Claims, error) {
+	options := append(s.keyManager.ParserOptions(),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience...),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.leeway),
+	)
+
+	var claims Claims
+	parsed, err := jwt.ParseWithClaims(token, &claims, s.keyManager.Keyfunc, options...)
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+	if claims.TokenUse != tokenUse || claims.ID == "" || claims.IssuedAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// subjectForUser returns the "sub" claim identifying a user in tokens
+func subjectForUser(userID uint) string {
+	return fmt.Sprint(userID)
+}
+
+// configAudience returns the audiences put in and required of our tokens
+func configAudience() []string {
+	if audience := config.List("JWT_AUDIENCE"); len(audience) > 0 {
+		return audience
+	}
+	return []string{"user-service"}
+}
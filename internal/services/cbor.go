@@ -0,0 +1,215 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Minimal CBOR (RFC 8949) decoder covering what WebAuthn attestation objects and
+// COSE keys use. Maps decode to map[any]any; integers to int64 (or uint64 when they
+// don't fit); byte strings to []byte.
+
+// maxCBORDepth bounds nesting so malicious input can't exhaust the stack
+const maxCBORDepth = 16
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// decodeCBOR decodes one CBOR item from data and returns it with the number of bytes consumed
+func decodeCBOR(data []byte) (any, int, error) {
+	d := &cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) decode(depth int) (any, error) {
+	if depth > maxCBORDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+	if d.pos >= len(d.data) {
+		return nil, errCBORTruncated
+	}
+
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, initial&0x1f
+
+	// Floats and simple values use the argument bits differently
+	if major == 7 {
+		return d.decodeSimple(info)
+	}
+
+	arg, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0: // unsigned integer
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+	case 1: // negative integer
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case 2: // byte string
+		raw, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 3: // text string
+		raw, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(raw), nil
+	case 4: // array
+		if arg > uint64(len(d.data)) {
+			return nil, errCBORTruncated
+		}
+		items := make([]any, 0, arg)
+		for range arg {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5: // map
+		if arg > uint64(len(d.data)) {
+			return nil, errCBORTruncated
+		}
+		entries := make(map[any]any, arg)
+		for range arg {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, uint64, string:
+			default:
+				return nil, fmt.Errorf("cbor: unsupported map key type %T", key)
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+		}
+		return entries, nil
+	case 6: // tag: return the tagged value
+		return d.decode(depth + 1)
+	}
+	return nil, fmt.Errorf("cbor: unsupported major type %d", major)
+}
+
+// argument reads the unsigned argument that follows the initial byte
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		raw, err := d.take(1)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(raw[0]), nil
+	case info == 25:
+		raw, err := d.take(2)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint16(raw)), nil
+	case info == 26:
+		raw, err := d.take(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(raw)), nil
+	case info == 27:
+		raw, err := d.take(8)
+		if err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(raw), nil
+	default:
+		// Indefinite lengths are not allowed in the canonical CBOR WebAuthn uses
+		return 0, errors.New("cbor: indefinite length items are not supported")
+	}
+}
+
+// decodeSimple decodes major type 7 (false, true, null, undefined and floats)
+func (d *cborDecoder) decodeSimple(info byte) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		raw, err := d.take(2)
+		if err != nil {
+			return nil, err
+		}
+		return halfToFloat(binary.BigEndian.Uint16(raw)), nil
+	case 26:
+		raw, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), nil
+	case 27:
+		raw, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+}
+
+// take returns the next n bytes
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBORTruncated
+	}
+	raw := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return raw, nil
+}
+
+// halfToFloat converts an IEEE 754 half-precision float
+func halfToFloat(bits uint16) float64 {
+	sign := 1.0
+	if bits&0x8000 != 0 {
+		sign = -1
+	}
+	exponent := int(bits>>10) & 0x1f
+	mantissa := float64(bits & 0x3ff)
+
+	switch exponent {
+	case 0:
+		return sign * math.Ldexp(mantissa, -24)
+	case 31:
+		if mantissa == 0 {
+			return sign * math.Inf(1)
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(mantissa+1024, exponent-25)
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// cborMap is a CBOR map whose entries are encoded in the given order, as canonical
+// CBOR (and therefore WebAuthn) requires
+type cborMap []cborEntry
+
+type cborEntry struct {
+	key   any
+	value any
+}
+
+// encodeCBOR encodes the subset of CBOR the software authenticator needs
+func encodeCBOR(value any) []byte {
+	var buf bytes.Buffer
+	writeCBOR(&buf, value)
+	return buf.Bytes()
+}
+
+func writeCBOR(buf *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			writeCBORHead(buf, 1, uint64(-1-v))
+		} else {
+			writeCBORHead(buf, 0, uint64(v))
+		}
+	case []byte:
+		writeCBORHead(buf, 2, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		writeCBORHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []any:
+		writeCBORHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			writeCBOR(buf, item)
+		}
+	case cborMap:
+		writeCBORHead(buf, 5, uint64(len(v)))
+		for _, entry := range v {
+			writeCBOR(buf, entry.key)
+			writeCBOR(buf, entry.value)
+		}
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	default:
+		panic(fmt.Sprintf("encodeCBOR: unsupported type %T", value))
+	}
+}
+
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.Write([]byte{major<<5 | 24, byte(arg)})
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// Vectors from RFC 8949, Appendix A
+	tests := []struct {
+		hex  string
+		want any
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1a000f4240", int64(1000000)},
+		{"1bffffffffffffffff", uint64(math.MaxUint64)},
+		{"20", int64(-1)},
+		{"3903e7", int64(-1000)},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"6449455446", "IETF"},
+		{"83010203", []any{int64(1), int64(2), int64(3)}},
+		{"a201020304", map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f93c00", 1.0},
+		{"f9c400", -4.0},
+		{"fa47c35000", 100000.0},
+		{"fb3ff199999999999a", 1.1},
+	}
+
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		got, consumed, err := decodeCBOR(data)
+		if err != nil {
+			t.Errorf("decodeCBOR(%s) error: %v", tt.hex, err)
+			continue
+		}
+		if consumed != len(data) {
+			t.Errorf("decodeCBOR(%s) consumed %d of %d bytes", tt.hex, consumed, len(data))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("decodeCBOR(%s) = %#v, want %#v", tt.hex, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeCBORReportsConsumedBytes(t *testing.T) {
+	// A COSE key followed by more authenticator data: only the first item is consumed
+	data := append(encodeCBOR(cborMap{{1, 2}, {3, -7}}), 0xde, 0xad)
+	_, consumed, err := decodeCBOR(data)
+	if err != nil {
+		t.Fatalf("decodeCBOR error: %v", err)
+	}
+	if consumed != len(data)-2 {
+		t.Errorf("consumed %d bytes, want %d", consumed, len(data)-2)
+	}
+}
+
+func TestDecodeCBORRejectsMalformedInput(t *testing.T) {
+	nested := bytes.Repeat([]byte{0x81}, maxCBORDepth+2)
+	nested = append(nested, 0x00)
+
+	tests := map[string][]byte{
+		"empty":                 {},
+		"truncated argument":    {0x19, 0x01},
+		"truncated byte string": {0x44, 0x01, 0x02},
+		"huge array length":     {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"indefinite length":     {0x5f, 0x41, 0x00, 0xff},
+		"unsupported simple":    {0xf0},
+		"too deeply nested":     nested,
+	}
+
+	for name, data := range tests {
+		if _, _, err := decodeCBOR(data); err == nil {
+			t.Errorf("%s: decodeCBOR succeeded, want an error", name)
+		}
+	}
+
+	if _, _, err := decodeCBOR([]byte{0x42, 0x01}); !errors.Is(err, errCBORTruncated) {
+		t.Errorf("truncated input: got %v, want errCBORTruncated", err)
+	}
+}
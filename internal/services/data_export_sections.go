@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// exportedSession is a first-party session or an OAuth grant, i.e. a refresh token family.
+// The device details are only known for sessions recorded since they were tracked.
+type exportedSession struct {
+	ClientID        string     `json:"client_id,omitempty"`
+	Scope           string     `json:"scope,omitempty"`
+	DeviceName      string     `json:"device_name,omitempty"`
+	UserAgent       string     `json:"user_agent,omitempty"`
+	IP              string     `json:"ip,omitempty"`
+	AuthMethod      string     `json:"auth_method,omitempty"`
+	SignedInAt      time.Time  `json:"signed_in_at"`
+	LastRefreshedAt time.Time  `json:"last_refreshed_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"` // When the session was revoked
+}
+
+// registerBuiltInExportSections registers the sections for the data this service stores
+func registerBuiltInExportSections(factory *repositories.Factory) {
+	userRepo := factory.GetUserRepository()
+	identityRepo := factory.GetIdentityRepository()
+	refreshTokenRepo := factory.GetRefreshTokenRepository()
+	sessionRepo := factory.GetSessionRepository()
+	mfaRepo := factory.GetMFARepository()
+	webAuthnRepo := factory.GetWebAuthnCredentialRepository()
+	personalAccessTokenRepo := factory.GetPersonalAccessTokenRepository()
+	roleRepo := factory.GetRoleRepository()
+	oauthRepo := factory.GetOAuthRepository()
+
+	RegisterExportSection("profile", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		user, err := userRepo.FindByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		return map[string]any{
+			"id":                user.ID,
+			"email":             user.Email,
+			"email_verified_at": user.EmailVerifiedAt,
+			"username":          user.Username,
+			"avatar_url":        user.AvatarURL,
+			"has_password":      user.PasswordHash != nil,
+			"status":            user.Status,
+			"created_at":        user.CreatedAt,
+			"updated_at":        user.UpdatedAt,
+		}, nil
+	}))
+
+	RegisterExportSection("preferences", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		user, err := userRepo.FindByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		return user.Preferences, nil
+	}))
+
+	RegisterExportSection("status_history", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		return userRepo.FindStatusTransitions(userID)
+	}))
+
+	// The stored profile and claims of the user at each provider, unlike the API which only shows a summary
+	RegisterExportSection("identities", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		identities, err := identityRepo.FindByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		exported := make([]map[string]any, 0, len(identities))
+		for _, identity := range identities {
+			exported = append(exported, map[string]any{
+				"provider":       identity.Provider,
+				"subject":        identity.Subject,
+				"email":          identity.Email,
+				"email_verified": identity.EmailVerified,
+				"claims":         identity.RawClaims,
+				"linked_at":      identity.CreatedAt,
+				"last_login_at":  identity.LastLoginAt,
+			})
+		}
+		return exported, nil
+	}))
+
+	RegisterExportSection("sessions", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		sessions, err := exportSessions(refreshTokenRepo, sessionRepo, userID)
+		if err != nil {
+			return nil, err
+		}
+		active := make([]exportedSession, 0, len(sessions))
+		for _, session := range sessions {
+			if session.EndedAt == nil && time.Now().Before(session.ExpiresAt) {
+				active = append(active, session)
+			}
+		}
+		return active, nil
+	}))
+
+	// Every sign in started a refresh token family, whether it is still active or not
+	RegisterExportSection("login_history", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		return exportSessions(refreshTokenRepo, sessionRepo, userID)
+	}))
+
+	RegisterExportSection("mfa", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		totp, err := mfaRepo.FindTOTPByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		passkeys, err := webAuthnRepo.FindByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		exported := map[string]any{"totp": nil, "passkeys": passkeys}
+		if totp != nil && totp.ConfirmedAt != nil {
+			exported["totp"] = map[string]any{"enabled_at": totp.ConfirmedAt}
+		}
+		return exported, nil
+	}))
+
+	RegisterExportSection("personal_access_tokens", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		tokens, err := personalAccessTokenRepo.FindByUserID(userID)
+		if err != nil {
+			return nil, err
+		}
+		exported := make([]map[string]any, 0, len(tokens))
+		for _, token := range tokens {
+			exported = append(exported, map[string]any{
+				"name":         token.Name,
+				"prefix":       token.Prefix,
+				"scopes":       token.ScopeList(),
+				"expires_at":   token.ExpiresAt,
+				"last_used_at": token.LastUsedAt,
+				"created_at":   token.CreatedAt,
+			})
+		}
+		return exported, nil
+	}))
+
+	RegisterExportSection("roles", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		return roleRepo.FindByUserID(userID)
+	}))
+
+	RegisterExportSection("oauth_consents", ExportSectionFunc(func(ctx context.Context, userID uint) (any, error) {
+		return oauthRepo.FindConsentsByUserID(userID)
+	}))
+}
+
+// exportSessions groups the refresh tokens of a user by family, oldest sign in first,
+// together with the recorded session of each family
+func exportSessions(refreshTokenRepo interfaces.RefreshTokenRepository, sessionRepo interfaces.SessionRepository, userID uint) ([]exportedSession, error) {
+	tokens, err := refreshTokenRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := sessionRepo.FindByUserID(userID, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	sessionsByFamily := make(map[string]models.Session, len(recorded))
+	for _, session := range recorded {
+		sessionsByFamily[session.FamilyID] = session
+	}
+
+	var sessions []exportedSession
+	families := make(map[string]int)
+	for _, token := range tokens {
+		i, ok := families[token.FamilyID]
+		if !ok {
+			signedInAt := token.AuthTime
+			if signedInAt.IsZero() {
+				signedInAt = token.CreatedAt
+			}
+			families[token.FamilyID] = len(sessions)
+			recordedSession := sessionsByFamily[token.FamilyID]
+			sessions = append(sessions, exportedSession{
+				ClientID:   token.ClientID,
+				Scope:      token.Scope,
+				DeviceName: recordedSession.DeviceName,
+				UserAgent:  recordedSession.UserAgent,
+				IP:         recordedSession.IP,
+				AuthMethod: recordedSession.AuthMethod,
+				SignedInAt: signedInAt,
+			})
+			i = len(sessions) - 1
+		}
+		session := &sessions[i]
+		session.LastRefreshedAt = token.CreatedAt
+		session.ExpiresAt = token.ExpiresAt
+		session.EndedAt = token.RevokedAt
+	}
+	if sessions == nil {
+		sessions = []exportedSession{}
+	}
+	return sessions, nil
+}
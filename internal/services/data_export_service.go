@@ -0,0 +1,334 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+const (
+	// DataExportFormatJSON is a single JSON document with every section
+	DataExportFormatJSON = "json"
+
+	// DataExportFormatZip is a zip archive with a JSON file per section
+	DataExportFormatZip = "zip"
+)
+
+// dataExportBuildTimeout is how long an export may stay pending before another one can be
+// requested, e.g. because the replica assembling it was restarted
+const dataExportBuildTimeout = time.Hour
+
+var (
+	dataExportServiceInstance *DataExportService
+	dataExportServiceOnce     sync.Once
+)
+
+var (
+	// ErrInvalidDataExportFormat is returned for unsupported archive formats
+	ErrInvalidDataExportFormat = errors.New(`format must be "json" or "zip"`)
+
+	// ErrDataExportInProgress is returned when the user already has an export being assembled
+	ErrDataExportInProgress = errors.New("an export is already in progress")
+
+	// ErrDataExportNotFound is returned for unknown exports and exports of other users
+	ErrDataExportNotFound = errors.New("export not found")
+
+	// ErrDataExportNotReady is returned when downloading an export that isn't assembled
+	ErrDataExportNotReady = errors.New("export is not ready")
+
+	// ErrInvalidDownloadToken is returned for unknown or expired download links
+	ErrInvalidDownloadToken = errors.New("invalid or expired download link")
+)
+
+// ExportSection contributes one part of the data export of a user. The result is encoded
+// as JSON.
+type ExportSection interface {
+	Export(ctx context.Context, userID uint) (any, error)
+}
+
+// ExportSectionFunc adapts a function to an ExportSection
+type ExportSectionFunc func(ctx context.Context, userID uint) (any, error)
+
+// Export calls f(ctx, userID)
+func (f ExportSectionFunc) Export(ctx context.Context, userID uint) (any, error) {
+	return f(ctx, userID)
+}
+
+var (
+	exportSectionsMu sync.RWMutex
+	exportSections   = make(map[string]ExportSection)
+)
+
+// RegisterExportSection adds a named section to every data export. Subsystems that store
+// data about users register one so exports stay complete. Registering a name again
+// replaces the section.
+func RegisterExportSection(name string, section ExportSection) {
+	exportSectionsMu.Lock()
+	exportSections[name] = section
+	exportSectionsMu.Unlock()
+}
+
+// ExportSectionNames returns the names of the registered sections, sorted
+func ExportSectionNames() []string {
+	exportSectionsMu.RLock()
+	defer exportSectionsMu.RUnlock()
+
+	names := make([]string, 0, len(exportSections))
+	for name := range exportSections {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DataExportArchive is the document a JSON export consists of, and the manifest of a zip export
+type DataExportArchive struct {
+	UserID     uint           `json:"user_id"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Sections   map[string]any `json:"sections,omitempty"`
+	Files      []string       `json:"files,omitempty"`
+}
+
+// DataExportService assembles copies of the data stored about users. Exports are built in
+// the background and kept for DATA_EXPORT_RETENTION; they are downloaded through links
+// that expire after DATA_EXPORT_LINK_TTL.
+type DataExportService struct {
+	exportRepo  interfaces.DataExportRepository
+	downloadURL string
+	retention   time.Duration
+	linkTTL     time.Duration
+}
+
+// GetDataExportService returns the process-wide DataExportService, registering the
+// built-in sections and starting the cleanup of expired exports on first use
+func GetDataExportService() *DataExportService {
+	dataExportServiceOnce.Do(func() {
+		factory := repositories.NewFactory()
+		dataExportServiceInstance = &DataExportService{
+			exportRepo:  factory.GetDataExportRepository(),
+			downloadURL: config.String("DATA_EXPORT_DOWNLOAD_URL", "http://localhost:8080/user/exports/download"),
+			retention:   config.Duration("DATA_EXPORT_RETENTION", 7*24*time.Hour),
+			linkTTL:     config.Duration("DATA_EXPORT_LINK_TTL", 15*time.Minute),
+		}
+		registerBuiltInExportSections(factory)
+		go dataExportServiceInstance.runCleanup(time.Hour)
+	})
+	return dataExportServiceInstance
+}
+
+// RequestExport queues an export of the user's data in the given format and assembles it in the background
+func (s *DataExportService) RequestExport(userID uint, format string) (*models.DataExport, error) {
+	if format == "" {
+		format = DataExportFormatJSON
+	}
+	if format != DataExportFormatJSON && format != DataExportFormatZip {
+		return nil, ErrInvalidDataExportFormat
+	}
+
+	exports, err := s.exportRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, export := range exports {
+		if export.Status == models.DataExportPending && time.Since(export.CreatedAt) < dataExportBuildTimeout {
+			return nil, ErrDataExportInProgress
+		}
+	}
+
+	export := &models.DataExport{
+		UserID:    userID,
+		Status:    models.DataExportPending,
+		Format:    format,
+		ExpiresAt: time.Now().Add(s.retention),
+	}
+	if err := s.exportRepo.Create(export); err != nil {
+		return nil, err
+	}
+
+	go s.build(*export)
+
+	return export, nil
+}
+
+// List returns the exports of a user, newest first
+func (s *DataExportService) List(userID uint) ([]models.DataExport, error) {
+	return s.exportRepo.FindByUserID(userID)
+}
+
+// Get returns an export of the user
+func (s *DataExportService) Get(userID, id uint) (*models.DataExport, error) {
+	export, err := s.exportRepo.FindByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if export == nil {
+		return nil, ErrDataExportNotFound
+	}
+	return export, nil
+}
+
+// CreateDownloadLink returns a link to download a ready export and when it expires.
+// Creating a link invalidates the previous one.
+func (s *DataExportService) CreateDownloadLink(userID, id uint) (string, time.Time, error) {
+	export, err := s.Get(userID, id)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if export.Status != models.DataExportReady {
+		return "", time.Time{}, ErrDataExportNotReady
+	}
+
+	plaintext, err := generateRandomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(s.linkTTL)
+	if export.ExpiresAt.Before(expiresAt) {
+		expiresAt = export.ExpiresAt
+	}
+	if err := s.exportRepo.SetDownloadToken(export.ID, hashToken(plaintext), expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return appendQuery(s.downloadURL, url.Values{"token": {plaintext}}), expiresAt, nil
+}
+
+// Download returns the export, including its archive, a download link was created for
+func (s *DataExportService) Download(token string) (*models.DataExport, error) {
+	export, err := s.exportRepo.FindByDownloadTokenHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if export == nil || export.Status != models.DataExportReady || export.DownloadExpiresAt == nil {
+		return nil, ErrInvalidDownloadToken
+	}
+	now := time.Now()
+	if now.After(*export.DownloadExpiresAt) || now.After(export.ExpiresAt) {
+		return nil, ErrInvalidDownloadToken
+	}
+	return export, nil
+}
+
+// FileName returns the name an export is downloaded as
+func (s *DataExportService) FileName(export *models.DataExport) string {
+	return fmt.Sprintf("user-data-%d-%s.%s", export.UserID, export.CreatedAt.UTC().Format("20060102"), export.Format)
+}
+
+// build assembles an export and stores the result
+func (s *DataExportService) build(export models.DataExport) {
+	ctx, cancel := context.WithTimeout(context.Background(), dataExportBuildTimeout)
+	defer cancel()
+
+	archive, err := s.assemble(ctx, export.UserID, export.Format)
+	now := time.Now()
+	export.CompletedAt = &now
+	if err != nil {
+		log.Printf("Failed to assemble data export %d: %v", export.ID, err)
+		export.Status = models.DataExportFailed
+		export.Error = "Failed to assemble the export"
+	} else {
+		export.Status = models.DataExportReady
+		export.Archive = archive
+		export.Size = len(archive)
+	}
+
+	if err := s.exportRepo.Update(&export); err != nil {
+		log.Printf("Failed to store data export %d: %v", export.ID, err)
+	}
+}
+
+// assemble runs every registered section and encodes the results in the format
+func (s *DataExportService) assemble(ctx context.Context, userID uint, format string) ([]byte, error) {
+	exportSectionsMu.RLock()
+	sections := make(map[string]ExportSection, len(exportSections))
+	for name, section := range exportSections {
+		sections[name] = section
+	}
+	exportSectionsMu.RUnlock()
+
+	document := DataExportArchive{
+		UserID:     userID,
+		ExportedAt: time.Now().UTC(),
+		Sections:   make(map[string]any, len(sections)),
+	}
+	for name, section := range sections {
+		data, err := section.Export(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("section %s: %w", name, err)
+		}
+		document.Sections[name] = data
+	}
+
+	if format == DataExportFormatJSON {
+		return json.MarshalIndent(document, "", "  ")
+	}
+	return zipDataExport(document)
+}
+
+// zipDataExport writes every section of an export to its own file next to a manifest
+func zipDataExport(document DataExportArchive) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	names := make([]string, 0, len(document.Sections))
+	for name := range document.Sections {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	manifest := DataExportArchive{UserID: document.UserID, ExportedAt: document.ExportedAt}
+	for _, name := range names {
+		fileName := name + ".json"
+		if err := writeZipJSON(archive, fileName, document.Sections[name]); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, fileName)
+	}
+	if err := writeZipJSON(archive, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeZipJSON adds a file with the JSON encoding of v to a zip archive
+func writeZipJSON(archive *zip.Writer, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return err
+}
+
+// runCleanup periodically deletes exports past their retention
+func (s *DataExportService) runCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.exportRepo.DeleteExpired(time.Now()); err != nil {
+			log.Printf("Failed to delete expired data exports: %v", err)
+		}
+	}
+}
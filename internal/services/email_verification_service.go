@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// TokenUseEmailVerification marks tokens sent in verification emails
+const TokenUseEmailVerification = "email_verification"
+
+// EmailVerificationPolicy controls what unverified accounts are allowed to do
+type EmailVerificationPolicy string
+
+const (
+	// EmailVerificationPolicyNone sends verification emails but blocks nothing
+	EmailVerificationPolicyNone EmailVerificationPolicy = "none"
+
+	// EmailVerificationPolicyLogin refuses to log in unverified accounts
+	EmailVerificationPolicyLogin EmailVerificationPolicy = "login"
+
+	// EmailVerificationPolicyRoutes allows login but rejects routes guarded by RequireVerifiedEmail
+	EmailVerificationPolicyRoutes EmailVerificationPolicy = "routes"
+)
+
+// ErrInvalidVerificationToken is returned for unknown, expired, used or outdated verification tokens
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// CurrentEmailVerificationPolicy returns the policy configured in EMAIL_VERIFICATION_POLICY
+func CurrentEmailVerificationPolicy() EmailVerificationPolicy {
+	switch policy := EmailVerificationPolicy(config.String("EMAIL_VERIFICATION_POLICY", "none")); policy {
+	case EmailVerificationPolicyLogin, EmailVerificationPolicyRoutes:
+		return policy
+	default:
+		return EmailVerificationPolicyNone
+	}
+}
+
+// EmailVerificationService sends and consumes email verification tokens.
+// Tokens are signed JWTs bound to the address they were sent to; each can be used once.
+type EmailVerificationService struct {
+	userRepo          interfaces.UserRepository
+	authService       *AuthService
+	revocationService *RevocationService
+	mailer            Mailer
+	ttl               time.Duration
+	verifyURL         string
+}
+
+// NewEmailVerificationService creates a new EmailVerificationService configured from the environment
+func NewEmailVerificationService() *EmailVerificationService {
+	factory := repositories.NewFactory()
+	return &EmailVerificationService{
+		userRepo:          factory.GetUserRepository(),
+		authService:       NewAuthService(),
+		revocationService: GetRevocationService(),
+		mailer:            NewMailer(),
+		ttl:               config.Duration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		verifyURL:         config.String("EMAIL_VERIFICATION_URL", "http://localhost:8080/verify-email"),
+	}
+}
+
+// SendVerificationEmail emails the user a link to verify their current address
+func (s *EmailVerificationService) SendVerificationEmail(user *models.User) error {
+	token, err := s.authService.SignToken(&Claims{
+		TokenUse: TokenUseEmailVerification,
+		UserID:   user.ID,
+		Email:    user.Email,
+	}, s.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := s.verifyURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s. If you did not create an account, you can ignore this email.",
+			user.Username, link, s.ttl),
+	})
+}
+
+// Resend sends a new verification email to the account with the given address.
+// Unknown and already verified addresses are silently ignored to avoid account enumeration.
+func (s *EmailVerificationService) Resend(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.IsEmailVerified() {
+		return nil
+	}
+	return s.SendVerificationEmail(user)
+}
+
+// Verify consumes a verification token and marks the user's email as verified
+func (s *EmailVerificationService) Verify(token string) (*models.User, error) {
+	claims, err := s.authService.ParseToken(token, TokenUseEmailVerification)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	used, err := s.revocationService.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	// The token is only valid for the address it was sent to
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Email != claims.Email {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	// Consume the token before updating so it cannot be replayed
+	if err := s.revocationService.RevokeToken(claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	if !user.IsEmailVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+	}
+	if err := GetUserStatusService().ActivatePending(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SendVerificationEmailAsync sends the verification email in the background, logging failures.
+// It is used where a delivery problem should not fail the request (the user can resend).
+func (s *EmailVerificationService) SendVerificationEmailAsync(user *models.User) {
+	recipient := *user
+	go func() {
+		if err := s.SendVerificationEmail(&recipient); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", recipient.ID, err)
+		}
+	}()
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// ErrIdentityEmailInUse is returned when a new external identity uses the email of an existing account
+var ErrIdentityEmailInUse = errors.New("an account with this email already exists, sign in to it first to connect this provider")
+
+// AccountAutoLinkPolicy controls whether a new external identity is attached to an existing
+// account with the same email instead of requiring the user to link it explicitly
+type AccountAutoLinkPolicy string
+
+const (
+	// AccountAutoLinkNone never links automatically
+	AccountAutoLinkNone AccountAutoLinkPolicy = "none"
+
+	// AccountAutoLinkVerifiedEmail links when both the provider and the existing account
+	// have verified the address
+	AccountAutoLinkVerifiedEmail AccountAutoLinkPolicy = "verified_email"
+)
+
+// CurrentAccountAutoLinkPolicy returns the policy configured in ACCOUNT_AUTO_LINK
+func CurrentAccountAutoLinkPolicy() AccountAutoLinkPolicy {
+	if AccountAutoLinkPolicy(config.String("ACCOUNT_AUTO_LINK", "none")) == AccountAutoLinkVerifiedEmail {
+		return AccountAutoLinkVerifiedEmail
+	}
+	return AccountAutoLinkNone
+}
+
+// FederatedIdentity is a verified user identity asserted by an external provider
+type FederatedIdentity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Claims            map[string]any
+}
+
+// IdentityService signs users in with identities from external providers
+// (Apple, Google, any OpenID Connect provider) and keeps them linked to accounts
+type IdentityService struct {
+	identityRepo interfaces.IdentityRepository
+	userRepo     interfaces.UserRepository
+	userService  *UserService
+}
+
+// NewIdentityService creates a new IdentityService with repositories from the factory
+func NewIdentityService() *IdentityService {
+	factory := repositories.NewFactory()
+	return &IdentityService{
+		identityRepo: factory.GetIdentityRepository(),
+		userRepo:     factory.GetUserRepository(),
+		userService:  NewUserService(),
+	}
+}
+
+// SignIn returns the user linked to the identity, creating the account on first sign in.
+// An email that is already registered is only linked under the verified_email policy; an
+// unverified account could have been registered by someone else to hijack the address.
+func (s *IdentityService) SignIn(identity *FederatedIdentity) (*models.User, error) {
+	existing, err := s.identityRepo.FindByProviderSubject(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		user, err := s.userRepo.FindByID(existing.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		if err := AccountStatusError(user); err != nil {
+			return nil, err
+		}
+		if err := s.recordLogin(existing, identity); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	// New accounts need an email, which some providers omit depending on the granted scopes
+	if identity.Email == "" {
+		return nil, errors.New("the provider did not share an email address")
+	}
+	owner, err := s.userRepo.FindByEmail(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil {
+		if CurrentAccountAutoLinkPolicy() != AccountAutoLinkVerifiedEmail || !identity.EmailVerified || !owner.IsEmailVerified() {
+			return nil, ErrIdentityEmailInUse
+		}
+		if err := AccountStatusError(owner); err != nil {
+			return nil, err
+		}
+		linked := newUserIdentity(identity)
+		linked.UserID = owner.ID
+		if err := s.identityRepo.Create(linked); err != nil {
+			return nil, err
+		}
+		return owner, nil
+	}
+
+	suggestion := identity.PreferredUsername
+	if suggestion == "" {
+		suggestion = identity.Email
+	}
+	username, err := s.userService.availableUsername(suggestion)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Email:       identity.Email,
+		Username:    username,
+		Preferences: models.Preferences{},
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.identityRepo.CreateWithUser(user, newUserIdentity(identity)); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// recordLogin refreshes the stored profile of an identity after a sign in
+func (s *IdentityService) recordLogin(stored *models.UserIdentity, identity *FederatedIdentity) error {
+	now := time.Now()
+	stored.LastLoginAt = &now
+	// Some providers (Apple) only send the email on the first sign in
+	if identity.Email != "" {
+		stored.Email = identity.Email
+		stored.EmailVerified = identity.EmailVerified
+	}
+	if len(identity.Claims) > 0 {
+		stored.RawClaims = identity.Claims
+	}
+	return s.identityRepo.Update(stored)
+}
+
+// newUserIdentity converts a verified identity into its database record
+func newUserIdentity(identity *FederatedIdentity) *models.UserIdentity {
+	now := time.Now()
+	claims := models.IdentityClaims(identity.Claims)
+	if claims == nil {
+		claims = models.IdentityClaims{}
+	}
+	return &models.UserIdentity{
+		Provider:      identity.Provider,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		RawClaims:     claims,
+		LastLoginAt:   &now,
+	}
+}
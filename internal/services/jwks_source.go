@@ -0,0 +1,128 @@
+package services
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrUnknownKeyID is returned when a key set has no key with the requested kid
+var ErrUnknownKeyID = errors.New("unknown key id")
+
+// KeySource resolves the public key used to verify a token signed by a third party.
+// Implementations can serve keys from a remote JWKS endpoint, a file or a test fixture.
+type KeySource interface {
+	// Key returns the public key with the given key ID
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// RemoteJWKS is a KeySource backed by a remote JWKS endpoint.
+// Keys are cached for cacheTTL, and an unknown kid triggers an early refresh
+// (at most once per minRefresh) to pick up rotated keys. Keys are downloaded without holding
+// the cache lock, and concurrent lookups that need a refresh share a single download.
+type RemoteJWKS struct {
+	url        string
+	client     *http.Client
+	cacheTTL   time.Duration
+	minRefresh time.Duration
+
+	refreshGroup singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// Ensure RemoteJWKS implements KeySource
+var _ KeySource = (*RemoteJWKS)(nil)
+
+// NewRemoteJWKS creates a KeySource that fetches keys from the given JWKS URL
+func NewRemoteJWKS(url string, cacheTTL time.Duration) *RemoteJWKS {
+	return &RemoteJWKS{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		cacheTTL:   cacheTTL,
+		minRefresh: time.Minute,
+		keys:       make(map[string]crypto.PublicKey),
+	}
+}
+
+// Key returns the public key with the given key ID, fetching the key set if needed
+func (j *RemoteJWKS) Key(kid string) (crypto.PublicKey, error) {
+	key, ok, sinceFetch := j.cached(kid)
+	if ok && sinceFetch < j.cacheTTL {
+		return key, nil
+	}
+
+	// Refresh when the cache is stale, or when the kid is unknown and we haven't refreshed recently
+	if sinceFetch >= j.cacheTTL || (!ok && sinceFetch >= j.minRefresh) {
+		_, err, _ := j.refreshGroup.Do(j.url, func() (any, error) {
+			return nil, j.refresh()
+		})
+		if err != nil {
+			// Keep serving cached keys if the endpoint is temporarily unavailable
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		key, ok, _ = j.cached(kid)
+	}
+
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	return key, nil
+}
+
+// cached returns the cached key with the given key ID and how long ago the set was fetched
+func (j *RemoteJWKS) cached(kid string) (crypto.PublicKey, bool, time.Duration) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key, ok := j.keys[kid]
+	return key, ok, time.Since(j.fetchedAt)
+}
+
+// refresh downloads the key set and replaces the cache
+func (j *RemoteJWKS) refresh() error {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// Skip keys we don't understand rather than failing the whole set
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+	return nil
+}
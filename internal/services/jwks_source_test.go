@@ -0,0 +1,224 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testJWKSServer serves the public halves of its RSA keys as a JWKS and counts the downloads.
+// Requests wait for release while it is set.
+type testJWKSServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	release chan struct{}
+	fetches atomic.Int32
+}
+
+func newTestJWKSServer(t *testing.T) *testJWKSServer {
+	t.Helper()
+	server := &testJWKSServer{keys: make(map[string]*rsa.PrivateKey)}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *testJWKSServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.fetches.Add(1)
+	s.mu.Lock()
+	release := s.release
+	s.mu.Unlock()
+	if release != nil {
+		<-release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := JWKSet{Keys: []JWK{}}
+	for kid, key := range s.keys {
+		jwk, err := NewJWK(kid, "RS256", &key.PublicKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+// addKey generates and publishes a new key
+func (s *testJWKSServer) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+	return key
+}
+
+// removeKey stops publishing a key
+func (s *testJWKSServer) removeKey(kid string) {
+	s.mu.Lock()
+	delete(s.keys, kid)
+	s.mu.Unlock()
+}
+
+// hold makes requests wait until the returned function is called
+func (s *testJWKSServer) hold() func() {
+	release := make(chan struct{})
+	s.mu.Lock()
+	s.release = release
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		s.release = nil
+		s.mu.Unlock()
+		close(release)
+	}
+}
+
+func TestRemoteJWKSCachesKeys(t *testing.T) {
+	server := newTestJWKSServer(t)
+	key := server.addKey(t, "k1")
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		got, err := jwks.Key("k1")
+		if err != nil {
+			t.Fatalf("Key error: %v", err)
+		}
+		if !key.PublicKey.Equal(got) {
+			t.Fatal("Key returned a different key")
+		}
+	}
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Errorf("%d fetches, want 1", fetches)
+	}
+}
+
+func TestRemoteJWKSPicksUpRotatedKeys(t *testing.T) {
+	server := newTestJWKSServer(t)
+	server.addKey(t, "k1")
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+	jwks.minRefresh = 0
+
+	if _, err := jwks.Key("k1"); err != nil {
+		t.Fatalf("Key error: %v", err)
+	}
+
+	rotated := server.addKey(t, "k2")
+	server.removeKey("k1")
+	got, err := jwks.Key("k2")
+	if err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if !rotated.PublicKey.Equal(got) {
+		t.Error("Key returned a different key")
+	}
+	if _, err := jwks.Key("k1"); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("retired key: got %v, want ErrUnknownKeyID", err)
+	}
+}
+
+func TestRemoteJWKSLimitsRefreshesForUnknownKeys(t *testing.T) {
+	server := newTestJWKSServer(t)
+	server.addKey(t, "k1")
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		if _, err := jwks.Key("unknown"); !errors.Is(err, ErrUnknownKeyID) {
+			t.Fatalf("unknown kid: got %v, want ErrUnknownKeyID", err)
+		}
+	}
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Errorf("%d fetches, want 1", fetches)
+	}
+}
+
+func TestRemoteJWKSServesCachedKeysWhileEndpointFails(t *testing.T) {
+	server := newTestJWKSServer(t)
+	key := server.addKey(t, "k1")
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+	if _, err := jwks.Key("k1"); err != nil {
+		t.Fatalf("Key error: %v", err)
+	}
+
+	server.Close()
+	jwks.cacheTTL = 0
+	got, err := jwks.Key("k1")
+	if err != nil {
+		t.Fatalf("stale key while endpoint is down: %v", err)
+	}
+	if !key.PublicKey.Equal(got) {
+		t.Error("Key returned a different key")
+	}
+}
+
+func TestRemoteJWKSSharesConcurrentRefreshes(t *testing.T) {
+	server := newTestJWKSServer(t)
+	server.addKey(t, "k1")
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+
+	release := server.hold()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := jwks.Key("k1"); err != nil {
+				t.Errorf("Key error: %v", err)
+			}
+		}()
+	}
+	// Let every lookup reach the refresh before the download completes
+	time.Sleep(50 * time.Millisecond)
+	release()
+	wg.Wait()
+
+	if fetches := server.fetches.Load(); fetches != 1 {
+		t.Errorf("%d fetches, want 1", fetches)
+	}
+}
+
+func TestRemoteJWKSDoesNotBlockCachedKeysDuringRefresh(t *testing.T) {
+	server := newTestJWKSServer(t)
+	server.addKey(t, "k1")
+	jwks := NewRemoteJWKS(server.URL, time.Hour)
+	jwks.minRefresh = 0
+	if _, err := jwks.Key("k1"); err != nil {
+		t.Fatalf("Key error: %v", err)
+	}
+
+	// An unknown kid starts a download that doesn't complete yet
+	release := server.hold()
+	defer release()
+	go jwks.Key("unknown")
+	for server.fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key("k1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("cached key: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cached key lookup blocked by a pending refresh")
+	}
+}
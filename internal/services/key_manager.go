@@ -0,0 +1,416 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	keyManagerInstance *KeyManager
+	keyManagerOnce     sync.Once
+)
+
+// SupportedSigningAlgorithms lists the JWS algorithms the key manager can generate and verify
+var SupportedSigningAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+// loadedKey is a signing key decoded from the database
+type loadedKey struct {
+	kid       string
+	algorithm string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	createdAt time.Time
+	rotated   bool
+}
+
+// KeyManager owns the asymmetric keys used to sign and verify tokens.
+// Keys are persisted so every replica signs with the same current key and can verify
+// tokens signed by the others. A new key is generated every JWT_KEY_ROTATION_INTERVAL;
+// the previous keys stay available for verification for JWT_KEY_VERIFY_GRACE.
+// Private keys are stored encrypted with SIGNING_KEY_ENCRYPTION_KEY, so no key can be
+// generated until it is set.
+type KeyManager struct {
+	signingKeyRepo   interfaces.SigningKeyRepository
+	algorithm        string
+	rotationInterval time.Duration
+	verifyGrace      time.Duration
+
+	mu         sync.RWMutex
+	current    *loadedKey
+	keys       map[string]*loadedKey
+	loadedAt   time.Time
+	reloadLock sync.Mutex
+}
+
+// GetKeyManager returns the process-wide KeyManager, loading keys and starting scheduled rotation on first use
+func GetKeyManager() *KeyManager {
+	keyManagerOnce.Do(func() {
+		factory := repositories.NewFactory()
+		keyManagerInstance = NewKeyManagerWithRepo(factory.GetSigningKeyRepository())
+		if err := keyManagerInstance.EncryptStoredKeys(); err != nil {
+			log.Printf("Failed to encrypt stored signing keys: %v", err)
+		}
+		if err := keyManagerInstance.RotateIfDue(); err != nil {
+			log.Printf("Failed to initialize signing keys: %v", err)
+		}
+		go keyManagerInstance.runRotation(config.Duration("JWT_KEY_CHECK_INTERVAL", 10*time.Minute))
+	})
+	return keyManagerInstance
+}
+
+// NewKeyManagerWithRepo creates a new KeyManager with a specific repository
+func NewKeyManagerWithRepo(signingKeyRepo interfaces.SigningKeyRepository) *KeyManager {
+	algorithm := config.String("JWT_SIGNING_ALG", "RS256")
+	if signingMethod(algorithm) == nil {
+		log.Printf("Unsupported JWT_SIGNING_ALG %q, using RS256", algorithm)
+		algorithm = "RS256"
+	}
+
+	return &KeyManager{
+		signingKeyRepo:   signingKeyRepo,
+		algorithm:        algorithm,
+		rotationInterval: config.Duration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		verifyGrace:      config.Duration("JWT_KEY_VERIFY_GRACE", 7*24*time.Hour),
+		keys:             make(map[string]*loadedKey),
+	}
+}
+
+// Algorithm returns the JWS algorithm new tokens are signed with
+func (m *KeyManager) Algorithm() string {
+	return m.algorithm
+}
+
+// Sign signs the claims with the current key and sets the kid header
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	current := m.current
+	m.mu.RUnlock()
+
+	if current == nil {
+		// Keys failed to load at startup; try again before giving up
+		if err := m.RotateIfDue(); err != nil {
+			return "", err
+		}
+		m.mu.RLock()
+		current = m.current
+		m.mu.RUnlock()
+		if current == nil {
+			return "", errors.New("no signing key available")
+		}
+	}
+
+	token := jwt.NewWithClaims(current.method, claims)
+	token.Header["kid"] = current.kid
+	return token.SignedString(current.private)
+}
+
+// Keyfunc resolves the verification key for a token by its kid header.
+// It can be passed directly to jwt.Parse.
+func (m *KeyManager) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid header")
+	}
+
+	key, err := m.verificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.private.Public(), nil
+}
+
+// ParserOptions returns the jwt parser options matching the keys this manager issues
+func (m *KeyManager) ParserOptions() []jwt.ParserOption {
+	return []jwt.ParserOption{jwt.WithValidMethods(SupportedSigningAlgorithms)}
+}
+
+// JWKS returns the public keys currently valid for verification
+func (m *KeyManager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range m.keys {
+		jwk, err := NewJWK(key.kid, key.algorithm, key.private.Public())
+		if err != nil {
+			log.Printf("Failed to encode signing key %s: %v", key.kid, err)
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// RotateIfDue reloads keys from the database and generates a new signing key when
+// there is none or the current one is older than the rotation interval
+func (m *KeyManager) RotateIfDue() error {
+	m.reloadLock.Lock()
+	defer m.reloadLock.Unlock()
+
+	if err := m.reload(); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	current := m.current
+	m.mu.RUnlock()
+
+	if current != nil && time.Since(current.createdAt) < m.rotationInterval {
+		return nil
+	}
+	return m.rotate()
+}
+
+// Rotate immediately generates a new signing key and retires the previous ones
+func (m *KeyManager) Rotate() error {
+	m.reloadLock.Lock()
+	defer m.reloadLock.Unlock()
+	return m.rotate()
+}
+
+// rotate generates and stores a new key. The caller must hold reloadLock.
+func (m *KeyManager) rotate() error {
+	private, err := generatePrivateKey(m.algorithm)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+	kid, err := generateRandomHex(8)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := encryptSigningKey(kid, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		return err
+	}
+
+	key := &models.SigningKey{
+		KID:        kid,
+		Algorithm:  m.algorithm,
+		PrivateKey: sealed,
+	}
+	if err := m.signingKeyRepo.Create(key); err != nil {
+		return err
+	}
+	if err := m.signingKeyRepo.RotateAllExcept(kid, time.Now().Add(m.verifyGrace)); err != nil {
+		return err
+	}
+
+	log.Printf("Rotated token signing key, new kid %s (%s)", kid, m.algorithm)
+	return m.reload()
+}
+
+// EncryptStoredKeys encrypts the private keys that were stored in plaintext before keys were
+// encrypted at rest. Expired keys are deleted rather than encrypted.
+func (m *KeyManager) EncryptStoredKeys() error {
+	if err := m.signingKeyRepo.DeleteExpired(); err != nil {
+		return err
+	}
+	records, err := m.signingKeyRepo.FindUnexpired()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if !isPlaintextSigningKey(record.PrivateKey) {
+			continue
+		}
+		sealed, err := encryptSigningKey(record.KID, []byte(record.PrivateKey))
+		if err != nil {
+			return err
+		}
+		if err := m.signingKeyRepo.UpdatePrivateKey(record.ID, sealed); err != nil {
+			return err
+		}
+		log.Printf("Encrypted stored signing key %s", record.KID)
+	}
+	return nil
+}
+
+// reload replaces the in-memory keys with the unexpired keys from the database.
+// The caller must hold reloadLock.
+func (m *KeyManager) reload() error {
+	records, err := m.signingKeyRepo.FindUnexpired()
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*loadedKey, len(records))
+	var current *loadedKey
+	for _, record := range records {
+		key, err := decodeSigningKey(record)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", record.KID, err)
+			continue
+		}
+		keys[key.kid] = key
+		// Records are ordered newest first
+		if current == nil && !key.rotated {
+			current = key
+		}
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.current = current
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
+	return nil
+}
+
+// verificationKey finds a key by kid, reloading once if another replica may have rotated
+func (m *KeyManager) verificationKey(kid string) (*loadedKey, error) {
+	m.mu.RLock()
+	key, ok := m.keys[kid]
+	loadedAt := m.loadedAt
+	m.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if time.Since(loadedAt) >= 10*time.Second {
+		m.reloadLock.Lock()
+		err := m.reload()
+		m.reloadLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		m.mu.RLock()
+		key, ok = m.keys[kid]
+		m.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKeyID
+}
+
+// runRotation periodically rotates keys when due and removes expired ones
+func (m *KeyManager) runRotation(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := m.signingKeyRepo.DeleteExpired(); err != nil {
+			log.Printf("Failed to delete expired signing keys: %v", err)
+		}
+		if err := m.RotateIfDue(); err != nil {
+			log.Printf("Failed to rotate signing keys: %v", err)
+		}
+	}
+}
+
+// decodeSigningKey parses a stored key and checks it matches its algorithm
+func decodeSigningKey(record models.SigningKey) (*loadedKey, error) {
+	method := signingMethod(record.Algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported algorithm %q", record.Algorithm)
+	}
+
+	// Keys stored before encryption at rest are plain PEM until EncryptStoredKeys runs
+	pemData := []byte(record.PrivateKey)
+	if !isPlaintextSigningKey(record.PrivateKey) {
+		decrypted, err := decryptSigningKey(record.KID, record.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt private key: %w", err)
+		}
+		pemData = decrypted
+	}
+
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var private crypto.Signer
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if record.Algorithm == "RS256" {
+			private = k
+		}
+	case *ecdsa.PrivateKey:
+		if record.Algorithm == "ES256" && k.Curve == elliptic.P256() {
+			private = k
+		}
+	case ed25519.PrivateKey:
+		if record.Algorithm == "EdDSA" {
+			private = k
+		}
+	}
+	if private == nil {
+		return nil, fmt.Errorf("key type does not match algorithm %s", record.Algorithm)
+	}
+
+	return &loadedKey{
+		kid:       record.KID,
+		algorithm: record.Algorithm,
+		method:    method,
+		private:   private,
+		createdAt: record.CreatedAt,
+		rotated:   record.RotatedAt != nil,
+	}, nil
+}
+
+// isPlaintextSigningKey reports whether a stored private key is unencrypted PEM
+func isPlaintextSigningKey(privateKey string) bool {
+	return strings.HasPrefix(strings.TrimSpace(privateKey), "-----BEGIN")
+}
+
+// signingMethod maps a supported algorithm name to its jwt signing method
+func signingMethod(algorithm string) jwt.SigningMethod {
+	switch algorithm {
+	case "RS256":
+		return jwt.SigningMethodRS256
+	case "ES256":
+		return jwt.SigningMethodES256
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA
+	default:
+		return nil
+	}
+}
+
+// generatePrivateKey creates a new private key for the algorithm
+func generatePrivateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case "RS256":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		return private, err
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// TokenUseAccountUnlock marks the tokens emailed to the owner of an account that got locked
+// after too many failed logins
+const TokenUseAccountUnlock = "account_unlock"
+
+var (
+	loginThrottleInstance *LoginThrottle
+	loginThrottleOnce     sync.Once
+)
+
+var (
+	// ErrTooManyLoginAttempts is returned when a login is refused because of earlier failures
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts, try again later")
+
+	// ErrInvalidUnlockToken is returned for unknown, expired or already used unlock tokens
+	ErrInvalidUnlockToken = errors.New("invalid or expired unlock token")
+)
+
+// LoginThrottledError tells a refused client how long to wait before trying again.
+// It matches ErrTooManyLoginAttempts with errors.Is.
+type LoginThrottledError struct {
+	RetryAfter    time.Duration
+	AccountLocked bool // The account is locked out, as opposed to a delay or a blocked network
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.AccountLocked {
+		return "account is temporarily locked after too many failed login attempts"
+	}
+	return ErrTooManyLoginAttempts.Error()
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// attemptLimit is a counter key and the number of failures that locks it out (0 for no limit).
+// Account counters also wait out a delay between failures.
+type attemptLimit struct {
+	key     string
+	limit   int
+	account bool
+}
+
+// LoginThrottle protects password logins against guessing. Failures are counted per account,
+// per IP address and per subnet within LOGIN_FAILURE_WINDOW. After LOGIN_DELAY_AFTER failures
+// an account has to wait an exponentially growing delay between attempts, and any counter
+// reaching its limit is locked out for LOGIN_LOCKOUT_DURATION. Locked accounts can be unlocked
+// early through an emailed link or by an admin.
+type LoginThrottle struct {
+	store              AttemptStore
+	userRepo           interfaces.UserRepository
+	authService        *AuthService
+	revocationService  *RevocationService
+	mailer             Mailer
+	window             time.Duration
+	lockoutDuration    time.Duration
+	maxAccountFailures int
+	maxIPFailures      int
+	maxSubnetFailures  int
+	delayAfter         int
+	delayBase          time.Duration
+	delayMax           time.Duration
+	unlockURL          string
+	unlockTokenTTL     time.Duration
+}
+
+// GetLoginThrottle returns the process-wide LoginThrottle so that in-memory counters are shared
+func GetLoginThrottle() *LoginThrottle {
+	loginThrottleOnce.Do(func() {
+		factory := repositories.NewFactory()
+		loginThrottleInstance = &LoginThrottle{
+			store:              GetAttemptStore(),
+			userRepo:           factory.GetUserRepository(),
+			authService:        NewAuthService(),
+			revocationService:  GetRevocationService(),
+			mailer:             NewMailer(),
+			window:             config.Duration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+			lockoutDuration:    config.Duration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+			maxAccountFailures: config.Int("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+			maxIPFailures:      config.Int("LOGIN_MAX_IP_FAILURES", 50),
+			maxSubnetFailures:  config.Int("LOGIN_MAX_SUBNET_FAILURES", 200),
+			delayAfter:         config.Int("LOGIN_DELAY_AFTER", 2),
+			delayBase:          config.Duration("LOGIN_DELAY_BASE", time.Second),
+			delayMax:           config.Duration("LOGIN_DELAY_MAX", 30*time.Second),
+			unlockURL:          config.String("ACCOUNT_UNLOCK_URL", "http://localhost:8080/unlock-account"),
+			unlockTokenTTL:     config.Duration("ACCOUNT_UNLOCK_TOKEN_TTL", time.Hour),
+		}
+		go loginThrottleInstance.runCleanup(10 * time.Minute)
+	})
+	return loginThrottleInstance
+}
+
+// Reserve counts a login to the account with the given email from ip as a failure before its
+// password is checked, so that concurrent guesses can't slip past the delay or the limits
+// together. The login is refused, without being counted, while the account, the address or its
+// subnet is locked out, or while the account waits out the delay after its latest failures.
+// Reserve reports whether this attempt locked the account; RecordSuccess undoes the reservation
+// once the password turns out to be right, and NotifyAccountLocked tells the owner otherwise.
+// The address and its subnet are only checked here: RecordFailure counts them once the password
+// turns out to be wrong, so that successful logins from a shared address don't lock it out.
+func (t *LoginThrottle) Reserve(email, ip string) (bool, error) {
+	now := time.Now()
+	// Networks come first, so that a blocked network doesn't count towards the account
+	for _, network := range t.networkLimits(ip) {
+		attempt, err := t.store.Find(network.key)
+		if err != nil {
+			return false, err
+		}
+		if attempt != nil && attempt.IsLocked(now) {
+			return false, &LoginThrottledError{RetryAfter: attempt.LockedUntil.Sub(now)}
+		}
+	}
+	return t.reserve(attemptLimit{key: accountAttemptKey(email), limit: t.maxAccountFailures, account: true}, now)
+}
+
+// RecordFailure counts a login from ip whose password was wrong against the address and its
+// subnet, locking them out once they reach their limits
+func (t *LoginThrottle) RecordFailure(ip string) error {
+	now := time.Now()
+	for _, network := range t.networkLimits(ip) {
+		// A network another attempt just locked needs no further counting
+		if _, err := t.reserve(network, now); err != nil && !errors.Is(err, ErrTooManyLoginAttempts) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSuccess clears the failures of an account, including the one reserved for the current
+// login, once its password was right. Address counters are kept, so that signing in to one
+// account can't reset them.
+func (t *LoginThrottle) RecordSuccess(email string) error {
+	return t.store.Reset(accountAttemptKey(email))
+}
+
+// NotifyAccountLocked emails an unlock link to the owner of the account with the given email,
+// after a login whose reservation locked the account turned out to be wrong
+func (t *LoginThrottle) NotifyAccountLocked(email string) {
+	t.sendUnlockEmailAsync(email)
+}
+
+// AccountLockedUntil returns when the lockout of the account with the given email ends,
+// or nil if it isn't locked
+func (t *LoginThrottle) AccountLockedUntil(email string) (*time.Time, error) {
+	attempt, err := t.store.Find(accountAttemptKey(email))
+	if err != nil || attempt == nil || !attempt.IsLocked(time.Now()) {
+		return nil, err
+	}
+	return attempt.LockedUntil, nil
+}
+
+// UnlockUser lifts the lockout and clears the failures of a user's account
+func (t *LoginThrottle) UnlockUser(userID uint) (*models.User, error) {
+	user, err := t.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := t.store.Reset(accountAttemptKey(user.Email)); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UnlockWithToken unlocks the account an unlock link was emailed for. Tokens are single-use.
+func (t *LoginThrottle) UnlockWithToken(token string) (*models.User, error) {
+	claims, err := t.authService.ParseToken(token, TokenUseAccountUnlock)
+	if err != nil {
+		return nil, ErrInvalidUnlockToken
+	}
+	used, err := t.revocationService.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrInvalidUnlockToken
+	}
+	if err := t.revocationService.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	user, err := t.userRepo.FindByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidUnlockToken
+	}
+	// The token names the address that was locked, which may differ from the current one
+	if err := t.store.Reset(accountAttemptKey(claims.Email)); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// reserve counts a failure for a key unless it is locked out, full, or (for accounts) still
+// waiting out its delay, and locks it once it reaches its limit
+func (t *LoginThrottle) reserve(counter attemptLimit, now time.Time) (bool, error) {
+	attempt, err := t.store.RecordFailure(counter.key, now, t.window, func(current *models.LoginAttempt) error {
+		if current.IsLocked(now) {
+			return &LoginThrottledError{RetryAfter: current.LockedUntil.Sub(now), AccountLocked: counter.account}
+		}
+		failures := current.CurrentFailures(now)
+		// Another attempt reached the limit and is about to lock the key
+		if counter.limit > 0 && failures >= counter.limit {
+			return &LoginThrottledError{RetryAfter: t.lockoutDuration, AccountLocked: counter.account}
+		}
+		if !counter.account {
+			return nil
+		}
+		if delay := t.delay(failures); delay > 0 {
+			if wait := current.LastFailureAt.Add(delay).Sub(now); wait > 0 {
+				return &LoginThrottledError{RetryAfter: wait}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if counter.limit <= 0 || attempt.Failures < counter.limit {
+		return false, nil
+	}
+	return true, t.store.Lock(counter.key, now.Add(t.lockoutDuration))
+}
+
+// delay returns how long an account with the given number of failures has to wait
+// between attempts: LOGIN_DELAY_BASE, doubling with every further failure up to LOGIN_DELAY_MAX
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	if t.delayAfter <= 0 || failures < t.delayAfter {
+		return 0
+	}
+	delay := t.delayBase
+	for i := t.delayAfter; i < failures && delay < t.delayMax; i++ {
+		delay *= 2
+	}
+	return min(delay, t.delayMax)
+}
+
+// networkLimits returns the counters of an IP address and of its /24 (IPv4) or /64 (IPv6) subnet
+func (t *LoginThrottle) networkLimits(ip string) []attemptLimit {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	bits := 64
+	if addr.Is4() {
+		bits = 24
+	}
+	subnet, err := addr.Prefix(bits)
+	if err != nil {
+		return []attemptLimit{{key: "ip:" + addr.String(), limit: t.maxIPFailures}}
+	}
+	return []attemptLimit{
+		{key: "ip:" + addr.String(), limit: t.maxIPFailures},
+		{key: "subnet:" + subnet.String(), limit: t.maxSubnetFailures},
+	}
+}
+
+// sendUnlockEmailAsync emails the unlock link in the background, logging failures
+func (t *LoginThrottle) sendUnlockEmailAsync(email string) {
+	go func() {
+		if err := t.sendUnlockEmail(email); err != nil {
+			log.Printf("Failed to send account unlock email: %v", err)
+		}
+	}()
+}
+
+// sendUnlockEmail emails an unlock link to the owner of the account with the given email.
+// Addresses without an account are locked like the others, but nobody is emailed.
+func (t *LoginThrottle) sendUnlockEmail(email string) error {
+	user, err := t.userRepo.FindByEmail(email)
+	if err != nil || user == nil {
+		return err
+	}
+
+	token, err := t.authService.SignToken(&Claims{
+		TokenUse: TokenUseAccountUnlock,
+		UserID:   user.ID,
+		Email:    email,
+	}, t.unlockTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := appendQuery(t.unlockURL, url.Values{"token": {token}})
+	return t.mailer.Send(Message{
+		To:      user.Email,
+		Subject: "Your account has been locked",
+		Body: fmt.Sprintf("Hi %s,\n\nYour account was locked for %s after several failed sign-in attempts. "+
+			"If that was you, open the link below to unlock it now:\n\n%s\n\n"+
+			"If it wasn't you, someone may be trying to guess your password; consider changing it "+
+			"once you're signed in. The link expires in %s.",
+			user.Username, t.lockoutDuration, link, t.unlockTokenTTL),
+	})
+}
+
+// runCleanup periodically drops the counters that no longer matter
+func (t *LoginThrottle) runCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := t.store.DeleteExpired(time.Now()); err != nil {
+			log.Printf("Failed to delete expired login attempts: %v", err)
+		}
+	}
+}
+
+// accountAttemptKey returns the counter key of the account with the given email.
+// Failures are counted by address so that unknown addresses behave like real accounts.
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestLoginThrottle builds a LoginThrottle on an in-memory store without delays
+func newTestLoginThrottle() *LoginThrottle {
+	return &LoginThrottle{
+		store:              NewMemoryAttemptStore(),
+		window:             15 * time.Minute,
+		lockoutDuration:    15 * time.Minute,
+		maxAccountFailures: 3,
+		maxIPFailures:      5,
+		maxSubnetFailures:  8,
+	}
+}
+
+// failLogin reserves a login and records it as a wrong password
+func failLogin(t *testing.T, throttle *LoginThrottle, email, ip string) bool {
+	t.Helper()
+
+	locked, err := throttle.Reserve(email, ip)
+	if err != nil {
+		t.Fatalf("Reserve(%s, %s) error: %v", email, ip, err)
+	}
+	if err := throttle.RecordFailure(ip); err != nil {
+		t.Fatalf("RecordFailure(%s) error: %v", ip, err)
+	}
+	return locked
+}
+
+func TestLoginThrottleLocksAccount(t *testing.T) {
+	throttle := newTestLoginThrottle()
+
+	for i := 1; i <= 3; i++ {
+		if locked := failLogin(t, throttle, "ada@example.com", fmt.Sprintf("192.0.2.%d", i)); locked != (i == 3) {
+			t.Errorf("failure %d: locked = %v", i, locked)
+		}
+	}
+
+	_, err := throttle.Reserve("ADA@example.com ", "198.51.100.1")
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) || !throttled.AccountLocked || throttled.RetryAfter <= 0 {
+		t.Fatalf("Reserve after lockout = %v, want an account lockout", err)
+	}
+	if until, err := throttle.AccountLockedUntil("ada@example.com"); err != nil || until == nil {
+		t.Errorf("AccountLockedUntil = %v, %v, want a time", until, err)
+	}
+	if _, err := throttle.Reserve("bob@example.com", "198.51.100.1"); err != nil {
+		t.Errorf("other account refused: %v", err)
+	}
+}
+
+func TestLoginThrottleSuccessClearsAccountFailures(t *testing.T) {
+	throttle := newTestLoginThrottle()
+
+	failLogin(t, throttle, "ada@example.com", "192.0.2.1")
+	failLogin(t, throttle, "ada@example.com", "192.0.2.1")
+	if _, err := throttle.Reserve("ada@example.com", "192.0.2.1"); err != nil {
+		t.Fatalf("Reserve error: %v", err)
+	}
+	if err := throttle.RecordSuccess("ada@example.com"); err != nil {
+		t.Fatalf("RecordSuccess error: %v", err)
+	}
+
+	// The counter starts over, so two more failures don't lock the account
+	for i := 0; i < 2; i++ {
+		if failLogin(t, throttle, "ada@example.com", "192.0.2.1") {
+			t.Fatal("account locked after its failures were cleared")
+		}
+	}
+}
+
+func TestLoginThrottleSuccessfulLoginsDontLockNetworks(t *testing.T) {
+	throttle := newTestLoginThrottle()
+
+	// Many users signing in from behind one NAT address
+	for i := 0; i < 4*throttle.maxSubnetFailures; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if _, err := throttle.Reserve(email, "192.0.2.1"); err != nil {
+			t.Fatalf("login %d refused: %v", i, err)
+		}
+		if err := throttle.RecordSuccess(email); err != nil {
+			t.Fatalf("RecordSuccess error: %v", err)
+		}
+	}
+}
+
+func TestLoginThrottleLocksNetworks(t *testing.T) {
+	throttle := newTestLoginThrottle()
+
+	// Spraying one guess per account from a single address
+	for i := 0; i < throttle.maxIPFailures; i++ {
+		failLogin(t, throttle, fmt.Sprintf("user%d@example.com", i), "192.0.2.1")
+	}
+	_, err := throttle.Reserve("new@example.com", "192.0.2.1")
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) || throttled.AccountLocked {
+		t.Fatalf("Reserve from locked address = %v, want a network lockout", err)
+	}
+
+	// The rest of the subnet is locked once its failures add up
+	for i := 0; i < throttle.maxSubnetFailures-throttle.maxIPFailures; i++ {
+		failLogin(t, throttle, fmt.Sprintf("other%d@example.com", i), "192.0.2.2")
+	}
+	if _, err := throttle.Reserve("new@example.com", "192.0.2.3"); !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Errorf("Reserve from locked subnet = %v, want ErrTooManyLoginAttempts", err)
+	}
+	if _, err := throttle.Reserve("new@example.com", "198.51.100.1"); err != nil {
+		t.Errorf("other subnet refused: %v", err)
+	}
+}
+
+func TestLoginThrottleDelaysAccountAttempts(t *testing.T) {
+	throttle := newTestLoginThrottle()
+	throttle.delayAfter = 2
+	throttle.delayBase = time.Minute
+	throttle.delayMax = time.Hour
+
+	failLogin(t, throttle, "ada@example.com", "192.0.2.1")
+	failLogin(t, throttle, "ada@example.com", "192.0.2.1")
+
+	_, err := throttle.Reserve("ada@example.com", "192.0.2.1")
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) || throttled.AccountLocked {
+		t.Fatalf("Reserve during delay = %v, want a delay", err)
+	}
+	if throttled.RetryAfter <= 0 || throttled.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v, want at most the base delay", throttled.RetryAfter)
+	}
+}
+
+func TestLoginThrottleReservesConcurrentAttempts(t *testing.T) {
+	throttle := newTestLoginThrottle()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := throttle.Reserve("ada@example.com", "192.0.2.1"); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrTooManyLoginAttempts) {
+				t.Errorf("Reserve error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != throttle.maxAccountFailures {
+		t.Errorf("%d concurrent attempts allowed, want %d", allowed, throttle.maxAccountFailures)
+	}
+}
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle := &LoginThrottle{delayAfter: 2, delayBase: time.Second, delayMax: 5 * time.Second}
+
+	tests := map[int]time.Duration{0: 0, 1: 0, 2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second, 5: 5 * time.Second, 20: 5 * time.Second}
+	for failures, want := range tests {
+		if got := throttle.delay(failures); got != want {
+			t.Errorf("delay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
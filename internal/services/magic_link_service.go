@@ -0,0 +1,202 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// TokenUseMagicLink marks tokens sent in magic login links
+const TokenUseMagicLink = "magic_link"
+
+var (
+	// ErrInvalidMagicLink is returned for unknown, expired, used or foreign magic links
+	ErrInvalidMagicLink = errors.New("invalid or expired login link")
+
+	// ErrMagicLinkRateLimited is returned when too many links were requested for an address
+	ErrMagicLinkRateLimited = errors.New("too many login links requested, please try again later")
+)
+
+// magicLinkWindow counts the links requested for one address in the current window
+type magicLinkWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// magicLinkRequests rate limits link requests per address across all MagicLinkService instances
+var magicLinkRequests = struct {
+	sync.Mutex
+	byEmail map[string]*magicLinkWindow
+}{byEmail: make(map[string]*magicLinkWindow)}
+
+// MagicLinkService emails passwordless login links.
+// A link is a signed JWT carrying the hash of a nonce that only the requesting client
+// received, so a link opened on another device (or leaked from the mailbox) is useless.
+type MagicLinkService struct {
+	userRepo          interfaces.UserRepository
+	userService       *UserService
+	authService       *AuthService
+	revocationService *RevocationService
+	mailer            Mailer
+	ttl               time.Duration
+	loginURL          string
+	autoRegister      bool
+	rateLimit         int
+	rateWindow        time.Duration
+}
+
+// NewMagicLinkService creates a new MagicLinkService configured from the environment
+func NewMagicLinkService() *MagicLinkService {
+	factory := repositories.NewFactory()
+	return &MagicLinkService{
+		userRepo:          factory.GetUserRepository(),
+		userService:       NewUserService(),
+		authService:       NewAuthService(),
+		revocationService: GetRevocationService(),
+		mailer:            NewMailer(),
+		ttl:               config.Duration("MAGIC_LINK_TTL", 15*time.Minute),
+		loginURL:          config.String("MAGIC_LINK_URL", "http://localhost:8080/magic-link"),
+		autoRegister:      config.Bool("MAGIC_LINK_AUTO_REGISTER", false),
+		rateLimit:         config.Int("MAGIC_LINK_RATE_LIMIT", 3),
+		rateWindow:        config.Duration("MAGIC_LINK_RATE_WINDOW", 15*time.Minute),
+	}
+}
+
+// BeginRequest applies the per-address rate limit and returns the nonce the client must
+// keep to consume the link. It doesn't touch the database, so the result is the same
+// whether or not an account exists.
+func (s *MagicLinkService) BeginRequest(email string) (string, error) {
+	if !s.allowRequest(email) {
+		return "", ErrMagicLinkRateLimited
+	}
+	return generateRandomToken(32)
+}
+
+// SendLink emails a login link bound to nonce. Unknown addresses only get a link when
+// auto registration is enabled and are otherwise silently ignored.
+func (s *MagicLinkService) SendLink(email, nonce string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil && !s.autoRegister {
+		return nil
+	}
+
+	claims := &Claims{
+		TokenUse:  TokenUseMagicLink,
+		Email:     email,
+		Challenge: hashToken(nonce),
+	}
+	name := "there"
+	if user != nil {
+		claims.UserID = user.ID
+		name = user.Username
+	}
+	token, err := s.authService.SignToken(claims, s.ttl)
+	if err != nil {
+		return err
+	}
+
+	link := s.loginURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(Message{
+		To:      email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below on the same device to sign in:\n\n%s\n\n"+
+			"The link expires in %s and can only be used once. If you did not try to sign in, you can ignore this email.",
+			name, link, s.ttl),
+	})
+}
+
+// Consume exchanges a link token and the nonce from BeginRequest for the user it was sent to,
+// creating the account first if the link was sent to a new address.
+// Opening the link proves control of the mailbox, so the email is marked as verified.
+func (s *MagicLinkService) Consume(token, nonce string) (*models.User, error) {
+	claims, err := s.authService.ParseToken(token, TokenUseMagicLink)
+	if err != nil {
+		return nil, ErrInvalidMagicLink
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Challenge), []byte(hashToken(nonce))) != 1 {
+		return nil, ErrInvalidMagicLink
+	}
+
+	used, err := s.revocationService.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrInvalidMagicLink
+	}
+
+	user, err := s.userRepo.FindByEmail(claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	// A link sent to an existing account stays bound to it, even if the address moved since
+	if claims.UserID != 0 && (user == nil || user.ID != claims.UserID) {
+		return nil, ErrInvalidMagicLink
+	}
+	if user == nil && !s.autoRegister {
+		return nil, ErrInvalidMagicLink
+	}
+
+	// Consume the link before creating or updating anything so it cannot be replayed
+	if err := s.revocationService.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		if user, err = s.userService.CreatePasswordlessUser(claims.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	if !user.IsEmailVerified() {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+	}
+	if err := GetUserStatusService().ActivatePending(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// allowRequest counts a link request for the address and reports whether it is within the limit
+func (s *MagicLinkService) allowRequest(email string) bool {
+	key := strings.ToLower(strings.TrimSpace(email))
+
+	magicLinkRequests.Lock()
+	defer magicLinkRequests.Unlock()
+
+	// Drop windows that already ended
+	now := time.Now()
+	for address, window := range magicLinkRequests.byEmail {
+		if now.After(window.resetAt) {
+			delete(magicLinkRequests.byEmail, address)
+		}
+	}
+
+	window, ok := magicLinkRequests.byEmail[key]
+	if !ok {
+		window = &magicLinkWindow{resetAt: now.Add(s.rateWindow)}
+		magicLinkRequests.byEmail[key] = window
+	}
+	if window.count >= s.rateLimit {
+		return false
+	}
+	window.count++
+	return true
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+)
+
+// Message is an email sent to a user
+type Message struct {
+	To      string
+	Subject string
+	Body    string // Plain text
+}
+
+// Mailer delivers account emails (verification, password reset, ...)
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewMailer returns the Mailer selected by MAIL_DRIVER: "smtp", or "log" (the default)
+// which writes messages to MAIL_LOG_FILE or the standard logger
+func NewMailer() Mailer {
+	switch config.String("MAIL_DRIVER", "log") {
+	case "smtp":
+		return NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			config.String("SMTP_PORT", "587"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			config.String("MAIL_FROM", "no-reply@localhost"),
+		)
+	default:
+		return NewLogMailer(os.Getenv("MAIL_LOG_FILE"))
+	}
+}
+
+// SMTPMailer sends email through an SMTP server, using STARTTLS when the server offers it
+type SMTPMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// Ensure SMTPMailer implements Mailer
+var _ Mailer = (*SMTPMailer)(nil)
+
+// NewSMTPMailer creates a new SMTPMailer; authentication is skipped when username is empty
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr: host + ":" + port,
+		host: host,
+		auth: auth,
+		from: from,
+	}
+}
+
+// Send delivers the message over SMTP
+func (m *SMTPMailer) Send(msg Message) error {
+	to := sanitizeHeader(msg.To)
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, formatMessage(m.from, to, msg))
+}
+
+// LogMailer writes emails to a file, or to the standard logger when no path is set.
+// It is intended for development and tests, where links can be read back from the file.
+type LogMailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Ensure LogMailer implements Mailer
+var _ Mailer = (*LogMailer)(nil)
+
+// NewLogMailer creates a new LogMailer writing to path (or the standard logger if empty)
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+// Send writes the message to the file or log
+func (m *LogMailer) Send(msg Message) error {
+	if m.path == "" {
+		log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "Date: %s\n%s\n", time.Now().Format(time.RFC1123Z), formatMessage("", msg.To, msg))
+	return err
+}
+
+// formatMessage renders an RFC 5322 plain text message
+func formatMessage(from, to string, msg Message) []byte {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", sanitizeHeader(from))
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", sanitizeHeader(to))
+	fmt.Fprintf(&b, "Subject: %s\r\n", sanitizeHeader(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// sanitizeHeader strips line breaks so values cannot inject extra headers
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
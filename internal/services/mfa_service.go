@@ -0,0 +1,426 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// TokenUseMFAChallenge marks the intermediate token returned by a password login
+// that still needs a second factor
+const TokenUseMFAChallenge = "mfa_challenge"
+
+const recoveryCodeCount = 10
+
+var (
+	// ErrMFAAlreadyEnabled is returned when enrolling while TOTP is already active
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+	// ErrMFANotEnabled is returned for operations that need an active TOTP enrollment
+	ErrMFANotEnabled = errors.New("two-factor authentication is not enabled")
+
+	// ErrInvalidMFACode is returned when a TOTP or recovery code is wrong or was already used
+	ErrInvalidMFACode = errors.New("invalid authentication code")
+
+	// ErrInvalidMFAChallenge is returned for unknown, expired, used or exhausted challenge tokens
+	ErrInvalidMFAChallenge = errors.New("invalid or expired MFA challenge")
+
+	// ErrTooManyMFAAttempts is returned when a second factor is refused because of earlier failures
+	ErrTooManyMFAAttempts = errors.New("too many failed authentication codes, try again later")
+)
+
+// MFAThrottledError tells a refused client how long the user's second factor stays locked.
+// It matches ErrTooManyMFAAttempts with errors.Is.
+type MFAThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *MFAThrottledError) Error() string {
+	return ErrTooManyMFAAttempts.Error()
+}
+
+func (e *MFAThrottledError) Unwrap() error {
+	return ErrTooManyMFAAttempts
+}
+
+// TOTPEnrollment is returned when a user starts enrolling an authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAStatus summarizes a user's second factors
+type MFAStatus struct {
+	TOTPEnabled            bool `json:"totp_enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// MFAService handles TOTP enrollment, recovery codes and login challenges.
+// Second factor checks are limited per user: every check counts as a failure until it succeeds,
+// and MFA_MAX_FAILURES failures within MFA_FAILURE_WINDOW lock the user's second factor for
+// MFA_LOCKOUT_DURATION, whether they were made at login or to change the user's settings.
+type MFAService struct {
+	mfaRepo           interfaces.MFARepository
+	userRepo          interfaces.UserRepository
+	authService       *AuthService
+	revocationService *RevocationService
+	attemptStore      AttemptStore
+	issuer            string
+	challengeTTL      time.Duration
+	maxFailures       int
+	failureWindow     time.Duration
+	lockoutDuration   time.Duration
+}
+
+// NewMFAService creates a new MFAService with repositories from the factory
+func NewMFAService() *MFAService {
+	factory := repositories.NewFactory()
+	return &MFAService{
+		mfaRepo:           factory.GetMFARepository(),
+		userRepo:          factory.GetUserRepository(),
+		authService:       NewAuthService(),
+		revocationService: GetRevocationService(),
+		attemptStore:      GetAttemptStore(),
+		issuer:            config.String("MFA_ISSUER", "user-service"),
+		challengeTTL:      config.Duration("MFA_CHALLENGE_TTL", 5*time.Minute),
+		maxFailures:       config.Int("MFA_MAX_FAILURES", 5),
+		failureWindow:     config.Duration("MFA_FAILURE_WINDOW", 15*time.Minute),
+		lockoutDuration:   config.Duration("MFA_LOCKOUT_DURATION", 15*time.Minute),
+	}
+}
+
+// Status returns which second factors the user has set up
+func (s *MFAService) Status(userID uint) (*MFAStatus, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &MFAStatus{TOTPEnabled: enabled}
+	if enabled {
+		codes, err := s.mfaRepo.FindUnusedRecoveryCodes(userID)
+		if err != nil {
+			return nil, err
+		}
+		status.RecoveryCodesRemaining = len(codes)
+	}
+	return status, nil
+}
+
+// IsEnabled reports whether the user has a confirmed TOTP enrollment
+func (s *MFAService) IsEnabled(userID uint) (bool, error) {
+	credential, err := s.mfaRepo.FindTOTPByUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	return credential != nil && credential.ConfirmedAt != nil, nil
+}
+
+// BeginTOTPEnrollment generates a new secret for the user. It has no effect on login
+// until confirmed with a valid code; starting again replaces an unconfirmed secret.
+func (s *MFAService) BeginTOTPEnrollment(user *models.User) (*TOTPEnrollment, error) {
+	enabled, err := s.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SaveTOTP(&models.TOTPCredential{UserID: user.ID, EncryptedSecret: encrypted}); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: totpEncoding.EncodeToString(secret),
+		URI:    totpURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment activates the pending enrollment with a code from the
+// authenticator app and returns a fresh set of recovery codes (shown only once)
+func (s *MFAService) ConfirmTOTPEnrollment(userID uint, code string) ([]string, error) {
+	credential, err := s.mfaRepo.FindTOTPByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if credential.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.verifyTOTP(credential, code); err != nil {
+		return nil, err
+	}
+
+	// Only set confirmed_at: saving the whole credential would reset the step of the
+	// confirmation code and let it be replayed at login
+	now := time.Now()
+	if err := s.mfaRepo.ConfirmTOTP(credential.ID, now); err != nil {
+		return nil, err
+	}
+	credential.ConfirmedAt = &now
+
+	return s.replaceRecoveryCodes(userID)
+}
+
+// DisableTOTP removes the enrollment and recovery codes after checking a TOTP or recovery code
+func (s *MFAService) DisableTOTP(userID uint, code, recoveryCode string) error {
+	if err := s.VerifySecondFactor(userID, code, recoveryCode); err != nil {
+		return err
+	}
+	return s.mfaRepo.DeleteTOTP(userID)
+}
+
+// RegenerateRecoveryCodes invalidates the old recovery codes and returns new ones
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	if err := s.VerifySecondFactor(userID, code, ""); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(userID)
+}
+
+// VerifySecondFactor checks either a TOTP code or a recovery code for the user.
+// Accepted codes cannot be used again, and checks count towards the user's failure limit.
+func (s *MFAService) VerifySecondFactor(userID uint, code, recoveryCode string) error {
+	return s.limitAttempts(userID, func() error {
+		return s.checkSecondFactor(userID, code, recoveryCode)
+	})
+}
+
+// checkSecondFactor checks a TOTP code or a recovery code without counting the attempt
+func (s *MFAService) checkSecondFactor(userID uint, code, recoveryCode string) error {
+	credential, err := s.mfaRepo.FindTOTPByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if credential == nil || credential.ConfirmedAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	if recoveryCode != "" {
+		return s.useRecoveryCode(userID, recoveryCode)
+	}
+	return s.verifyTOTP(credential, code)
+}
+
+// IssueChallenge creates the short-lived token a password login returns when a second factor is needed
+func (s *MFAService) IssueChallenge(user *models.User) (string, error) {
+	return s.authService.SignToken(&Claims{
+		TokenUse: TokenUseMFAChallenge,
+		UserID:   user.ID,
+	}, s.challengeTTL)
+}
+
+// CompleteChallenge verifies a challenge token together with a TOTP or recovery code and
+// returns the user. Each challenge can be completed once, and attempts count towards the
+// user's failure limit.
+func (s *MFAService) CompleteChallenge(challenge, code, recoveryCode string) (*models.User, error) {
+	return s.CompleteChallengeWith(challenge, func(userID uint) error {
+		return s.checkSecondFactor(userID, code, recoveryCode)
+	})
+}
+
+// ChallengeUserID returns the user a pending challenge was issued to
+func (s *MFAService) ChallengeUserID(challenge string) (uint, error) {
+	claims, err := s.parseChallenge(challenge)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
+// CompleteChallengeWith completes a challenge using a custom second factor check, such as a
+// WebAuthn assertion. Every check counts towards the user's failure limit until one succeeds.
+func (s *MFAService) CompleteChallengeWith(challenge string, verify func(userID uint) error) (*models.User, error) {
+	claims, err := s.parseChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.limitAttempts(claims.UserID, func() error {
+		return verify(claims.UserID)
+	})
+	if err != nil {
+		if errors.Is(err, ErrTooManyMFAAttempts) {
+			// The second factor is locked: burn the challenge so the user has to log in again
+			if revokeErr := s.revocationService.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); revokeErr != nil {
+				return nil, revokeErr
+			}
+		}
+		return nil, err
+	}
+
+	if err := s.revocationService.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return user, nil
+}
+
+// parseChallenge verifies a challenge token and checks it hasn't been completed yet
+func (s *MFAService) parseChallenge(challenge string) (*Claims, error) {
+	claims, err := s.authService.ParseToken(challenge, TokenUseMFAChallenge)
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	used, err := s.revocationService.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return claims, nil
+}
+
+// verifyTOTP checks a code against the enrollment and records its time step so it can't be replayed
+func (s *MFAService) verifyTOTP(credential *models.TOTPCredential, code string) error {
+	secret, err := decryptSecret(credential.EncryptedSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := validateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	accepted, err := s.mfaRepo.UpdateTOTPLastUsedStep(credential.ID, step)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return ErrInvalidMFACode
+	}
+	credential.LastUsedStep = step
+	return nil
+}
+
+// useRecoveryCode consumes a matching unused recovery code
+func (s *MFAService) useRecoveryCode(userID uint, recoveryCode string) error {
+	codes, err := s.mfaRepo.FindUnusedRecoveryCodes(userID)
+	if err != nil {
+		return err
+	}
+
+	hash := hashToken(normalizeRecoveryCode(recoveryCode))
+	for _, candidate := range codes {
+		if subtle.ConstantTimeCompare([]byte(candidate.CodeHash), []byte(hash)) != 1 {
+			continue
+		}
+		used, err := s.mfaRepo.MarkRecoveryCodeUsed(candidate.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+	return ErrInvalidMFACode
+}
+
+// replaceRecoveryCodes generates a new set of recovery codes and stores their hashes
+func (s *MFAService) replaceRecoveryCodes(userID uint) ([]string, error) {
+	plaintext := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		plaintext = append(plaintext, code)
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, records); err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// limitAttempts counts a second factor check of the user as a failure before running it, so
+// that parallel guesses can't get past the limit together, and clears the user's failures once
+// a check succeeds. Checks are refused, without being counted, while the user is locked out.
+func (s *MFAService) limitAttempts(userID uint, verify func() error) error {
+	now := time.Now()
+	key := mfaAttemptKey(userID)
+	attempt, err := s.attemptStore.RecordFailure(key, now, s.failureWindow, func(current *models.LoginAttempt) error {
+		if current.IsLocked(now) {
+			return &MFAThrottledError{RetryAfter: current.LockedUntil.Sub(now)}
+		}
+		// Another check reached the limit and is about to lock the user
+		if s.maxFailures > 0 && current.CurrentFailures(now) >= s.maxFailures {
+			return &MFAThrottledError{RetryAfter: s.lockoutDuration}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if s.maxFailures > 0 && attempt.Failures >= s.maxFailures {
+		if err := s.attemptStore.Lock(key, now.Add(s.lockoutDuration)); err != nil {
+			return err
+		}
+	}
+
+	if err := verify(); err != nil {
+		return err
+	}
+	if err := s.attemptStore.Reset(key); err != nil {
+		log.Printf("Failed to reset second factor failures: %v", err)
+	}
+	return nil
+}
+
+// generateRecoveryCode returns a random code formatted as XXXX-XXXX-XXXX-XXXX
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	encoded := base32.StdEncoding.EncodeToString(raw) // 16 characters, no padding for 10 bytes
+	return encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16], nil
+}
+
+// mfaAttemptKey returns the counter key of the second factor of a user
+func mfaAttemptKey(userID uint) string {
+	return "mfa:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// normalizeRecoveryCode makes recovery code comparison ignore case, dashes and spaces
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// fakeMFARepository keeps one TOTP enrollment and its recovery codes in memory
+type fakeMFARepository struct {
+	interfaces.MFARepository
+	mu            sync.Mutex
+	totp          *models.TOTPCredential
+	recoveryCodes []models.RecoveryCode
+}
+
+func (r *fakeMFARepository) FindTOTPByUserID(userID uint) (*models.TOTPCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.totp == nil || r.totp.UserID != userID {
+		return nil, nil
+	}
+	credential := *r.totp
+	return &credential, nil
+}
+
+func (r *fakeMFARepository) UpdateTOTPLastUsedStep(id uint, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.totp == nil || r.totp.ID != id || step <= r.totp.LastUsedStep {
+		return false, nil
+	}
+	r.totp.LastUsedStep = step
+	return true, nil
+}
+
+func (r *fakeMFARepository) DeleteTOTP(userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totp = nil
+	r.recoveryCodes = nil
+	return nil
+}
+
+func (r *fakeMFARepository) ReplaceRecoveryCodes(userID uint, codes []models.RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recoveryCodes = codes
+	for i := range r.recoveryCodes {
+		r.recoveryCodes[i].ID = uint(i + 1)
+	}
+	return nil
+}
+
+func (r *fakeMFARepository) FindUnusedRecoveryCodes(userID uint) ([]models.RecoveryCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []models.RecoveryCode
+	for _, code := range r.recoveryCodes {
+		if code.UsedAt == nil {
+			unused = append(unused, code)
+		}
+	}
+	return unused, nil
+}
+
+func (r *fakeMFARepository) MarkRecoveryCodeUsed(id uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.recoveryCodes {
+		if r.recoveryCodes[i].ID == id && r.recoveryCodes[i].UsedAt == nil {
+			now := time.Now()
+			r.recoveryCodes[i].UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newTestMFAService builds an MFAService whose user 42 has confirmed TOTP with the returned secret
+func newTestMFAService(t *testing.T) (*MFAService, *fakeMFARepository, []byte) {
+	t.Helper()
+	t.Setenv("MFA_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	authService, revocationService := newTestAuthService(t)
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	encrypted, err := encryptSecret(secret)
+	if err != nil {
+		t.Fatalf("encrypt secret: %v", err)
+	}
+	confirmedAt := time.Now()
+	mfaRepo := &fakeMFARepository{totp: &models.TOTPCredential{ID: 1, UserID: 42, EncryptedSecret: encrypted, ConfirmedAt: &confirmedAt}}
+
+	service := &MFAService{
+		mfaRepo:           mfaRepo,
+		userRepo:          &fakeUserRepository{users: []*models.User{{ID: 42, Email: "ada@example.com", Username: "ada"}}},
+		authService:       authService,
+		revocationService: revocationService,
+		attemptStore:      NewMemoryAttemptStore(),
+		challengeTTL:      5 * time.Minute,
+		maxFailures:       5,
+		failureWindow:     15 * time.Minute,
+		lockoutDuration:   15 * time.Minute,
+	}
+	return service, mfaRepo, secret
+}
+
+// currentTOTPCode returns the code an authenticator app would show now
+func currentTOTPCode(secret []byte) string {
+	return totpCode(secret, time.Now().Unix()/totpPeriod)
+}
+
+// wrongTOTPCode returns a well-formed code that doesn't match the secret around now
+func wrongTOTPCode(t *testing.T, secret []byte) string {
+	t.Helper()
+	for _, code := range []string{"000000", "111111", "222222"} {
+		if _, ok := validateTOTP(secret, code, time.Now()); !ok {
+			return code
+		}
+	}
+	t.Fatal("no wrong code found")
+	return ""
+}
+
+func TestMFALocksAfterFailedCodes(t *testing.T) {
+	service, mfaRepo, secret := newTestMFAService(t)
+	wrong := wrongTOTPCode(t, secret)
+
+	for i := 0; i < service.maxFailures; i++ {
+		if err := service.DisableTOTP(42, wrong, ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+
+	err := service.DisableTOTP(42, currentTOTPCode(secret), "")
+	var throttled *MFAThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+		t.Fatalf("correct code while locked: got %v, want MFAThrottledError", err)
+	}
+	if mfaRepo.totp == nil {
+		t.Error("TOTP was disabled while locked")
+	}
+}
+
+func TestMFAFailuresAreSharedAcrossEntryPoints(t *testing.T) {
+	service, _, secret := newTestMFAService(t)
+	wrong := wrongTOTPCode(t, secret)
+
+	challenge, err := service.IssueChallenge(&models.User{ID: 42})
+	if err != nil {
+		t.Fatalf("IssueChallenge error: %v", err)
+	}
+
+	attempts := []func() error{
+		func() error { _, err := service.RegenerateRecoveryCodes(42, wrong); return err },
+		func() error { return service.DisableTOTP(42, "", "AAAA-BBBB-CCCC-DDDD") },
+		func() error { _, err := service.CompleteChallenge(challenge, wrong, ""); return err },
+		func() error { _, err := service.RegenerateRecoveryCodes(42, wrong); return err },
+		func() error { _, err := service.CompleteChallenge(challenge, wrong, ""); return err },
+	}
+	for i, attempt := range attempts {
+		if err := attempt(); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+
+	if _, err := service.CompleteChallenge(challenge, currentTOTPCode(secret), ""); !errors.Is(err, ErrTooManyMFAAttempts) {
+		t.Fatalf("correct code while locked: got %v, want ErrTooManyMFAAttempts", err)
+	}
+
+	// The locked out challenge is burned, even once the lockout is lifted
+	if err := service.attemptStore.Reset(mfaAttemptKey(42)); err != nil {
+		t.Fatalf("Reset error: %v", err)
+	}
+	if _, err := service.CompleteChallenge(challenge, currentTOTPCode(secret), ""); !errors.Is(err, ErrInvalidMFAChallenge) {
+		t.Errorf("burned challenge: got %v, want ErrInvalidMFAChallenge", err)
+	}
+}
+
+func TestMFASuccessClearsFailures(t *testing.T) {
+	service, _, secret := newTestMFAService(t)
+	wrong := wrongTOTPCode(t, secret)
+
+	for i := 0; i < service.maxFailures-1; i++ {
+		if _, err := service.RegenerateRecoveryCodes(42, wrong); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+	codes, err := service.RegenerateRecoveryCodes(42, currentTOTPCode(secret))
+	if err != nil {
+		t.Fatalf("correct code: %v", err)
+	}
+
+	for i := 0; i < service.maxFailures-1; i++ {
+		if _, err := service.RegenerateRecoveryCodes(42, wrong); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d after success: got %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+	if err := service.DisableTOTP(42, "", codes[0]); err != nil {
+		t.Errorf("recovery code refused: %v", err)
+	}
+}
+
+func TestMFAConcurrentGuessesStayWithinLimit(t *testing.T) {
+	service, _, secret := newTestMFAService(t)
+	wrong := wrongTOTPCode(t, secret)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	checked := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := service.DisableTOTP(42, wrong, "")
+			switch {
+			case errors.Is(err, ErrInvalidMFACode):
+				mu.Lock()
+				checked++
+				mu.Unlock()
+			case !errors.Is(err, ErrTooManyMFAAttempts):
+				t.Errorf("DisableTOTP error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if checked != service.maxFailures {
+		t.Errorf("%d concurrent codes checked, want %d", checked, service.maxFailures)
+	}
+}
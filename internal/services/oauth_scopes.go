@@ -0,0 +1,112 @@
+package services
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// Scopes understood by the OAuth provider. Individual preferences can be released
+// with "preferences.<key>" instead of the whole preferences object.
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopePreferences   = "preferences"
+	ScopeOfflineAccess = "offline_access"
+
+	preferenceScopePrefix = ScopePreferences + "."
+)
+
+// userScopes release data about a user and can't be granted to a client acting on its own behalf
+var userScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePreferences, ScopeOfflineAccess}
+
+// parseScope splits a space separated scope string, dropping duplicates
+func parseScope(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// isUserScope reports whether the scope releases user data
+func isUserScope(scope string) bool {
+	return slices.Contains(userScopes, scope) || strings.HasPrefix(scope, preferenceScopePrefix)
+}
+
+// scopeAllowed reports whether a scope is covered by the allowed scopes.
+// The "preferences" scope covers every individual preference scope.
+func scopeAllowed(scope string, allowed []string) bool {
+	if slices.Contains(allowed, scope) {
+		return true
+	}
+	return strings.HasPrefix(scope, preferenceScopePrefix) && slices.Contains(allowed, ScopePreferences)
+}
+
+// scopesAllowed reports whether every scope is covered by the allowed scopes
+func scopesAllowed(scopes, allowed []string) bool {
+	for _, scope := range scopes {
+		if !scopeAllowed(scope, allowed) {
+			return false
+		}
+	}
+	return true
+}
+
+// validScopeToken reports whether a scope is syntactically valid (RFC 6749 scope-token)
+func validScopeToken(scope string) bool {
+	if scope == "" {
+		return false
+	}
+	for _, r := range scope {
+		if r < 0x21 || r == '"' || r == '\\' || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// releasedUserClaims returns the user's claims the granted scopes allow a client to see
+func releasedUserClaims(user *models.User, scopes []string) map[string]any {
+	claims := map[string]any{"sub": subjectForUser(user.ID)}
+
+	if slices.Contains(scopes, ScopeProfile) {
+		claims["preferred_username"] = user.Username
+		claims["name"] = user.Username
+		if user.AvatarURL != "" {
+			claims["picture"] = user.AvatarURL
+		}
+	}
+
+	if slices.Contains(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.IsEmailVerified()
+	}
+
+	if slices.Contains(scopes, ScopePreferences) {
+		preferences := models.Preferences{}
+		maps.Copy(preferences, user.Preferences)
+		claims["preferences"] = preferences
+	} else {
+		preferences := models.Preferences{}
+		for _, scope := range scopes {
+			key, ok := strings.CutPrefix(scope, preferenceScopePrefix)
+			if !ok {
+				continue
+			}
+			if value, ok := user.Preferences[key]; ok {
+				preferences[key] = value
+			}
+		}
+		if len(preferences) > 0 {
+			claims["preferences"] = preferences
+		}
+	}
+
+	return claims
+}
@@ -0,0 +1,955 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Grant types supported by the token endpoint
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// Token type hints of introspection and revocation requests
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// Consent decisions sent with an authorization request
+const (
+	ConsentApprove = "approve"
+	ConsentDeny    = "deny"
+)
+
+// ErrInvalidAuthorizationClient is returned when the client or redirect URI of an
+// authorization request is invalid. The user must not be redirected in that case.
+var ErrInvalidAuthorizationClient = errors.New("unknown client or unregistered redirect URI")
+
+// OAuthError is an error defined by RFC 6749, returned to clients as {error, error_description}
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+// Error implements the error interface
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// newOAuthError creates an OAuthError with the given code and description
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// AuthorizationRequest holds the parameters of an authorization request
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Prompt              string `form:"prompt" json:"prompt"`
+	MaxAge              *int   `form:"max_age" json:"max_age"`
+}
+
+// AuthorizationResult tells the user agent what to do after an authorization request:
+// either go back to the client (RedirectTo), or ask the user to consent first
+type AuthorizationResult struct {
+	RedirectTo      string              `json:"redirect_to,omitempty"`
+	ConsentRequired bool                `json:"consent_required,omitempty"`
+	Client          *models.OAuthClient `json:"client,omitempty"`
+	Scopes          []string            `json:"scopes,omitempty"`
+}
+
+// TokenRequest holds the parameters of a token request
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	ClientID     string
+	ClientSecret string
+}
+
+// OAuthTokenResponse is the successful token endpoint response
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// IntrospectionResponse describes a token as defined by RFC 7662. Inactive tokens only
+// carry active=false so that callers learn nothing else about them.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	TokenID   string   `json:"jti,omitempty"`
+	AuthTime  int64    `json:"auth_time,omitempty"`
+}
+
+// ClientRegistration is a dynamic client registration request (RFC 7591)
+type ClientRegistration struct {
+	ClientName              string   `json:"client_name" binding:"required"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	Scope                   string   `json:"scope"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	FirstParty              bool     `json:"first_party"`
+}
+
+// RegisteredClient is returned once after registration; the secret can't be retrieved later
+type RegisteredClient struct {
+	ClientID                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	ClientName              string   `json:"client_name"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	Scope                   string   `json:"scope"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	FirstParty              bool     `json:"first_party"`
+	ClientIDIssuedAt        int64    `json:"client_id_issued_at"`
+}
+
+// OAuthService implements the OAuth 2.0 authorization server and OpenID Connect provider.
+// Users authenticate with our own login (the authorization endpoint is called with a
+// first-party access token), clients get authorization codes, tokens and ID tokens.
+type OAuthService struct {
+	oauthRepo           interfaces.OAuthRepository
+	userRepo            interfaces.UserRepository
+	authService         *AuthService
+	refreshTokenService *RefreshTokenService
+	revocationService   *RevocationService
+	keyManager          *KeyManager
+	issuer              string
+	loginURL            string
+	registrationToken   string
+	codeTTL             time.Duration
+	idTokenTTL          time.Duration
+	reauthMaxAge        time.Duration
+}
+
+// NewOAuthService creates a new OAuthService configured from the environment.
+// OAUTH_ISSUER must be the public base URL of this service, since clients compare it
+// with the issuer of discovery documents and ID tokens.
+func NewOAuthService() *OAuthService {
+	factory := repositories.NewFactory()
+	issuer := strings.TrimSuffix(config.String("OAUTH_ISSUER", "http://localhost:8080"), "/")
+	return &OAuthService{
+		oauthRepo:           factory.GetOAuthRepository(),
+		userRepo:            factory.GetUserRepository(),
+		authService:         NewAuthService(),
+		refreshTokenService: NewRefreshTokenService(),
+		revocationService:   GetRevocationService(),
+		keyManager:          GetKeyManager(),
+		issuer:              issuer,
+		loginURL:            config.String("OAUTH_LOGIN_URL", "http://localhost:8080/oauth/login"),
+		registrationToken:   config.String("OAUTH_REGISTRATION_TOKEN", ""),
+		codeTTL:             config.Duration("OAUTH_CODE_TTL", time.Minute),
+		idTokenTTL:          config.Duration("OAUTH_ID_TOKEN_TTL", time.Hour),
+		reauthMaxAge:        config.Duration("REAUTHENTICATION_MAX_AGE", 5*time.Minute),
+	}
+}
+
+// Issuer returns the issuer identifier of the provider
+func (s *OAuthService) Issuer() string {
+	return s.issuer
+}
+
+// LoginURL returns where the authorization endpoint sends the user agent to sign in and consent
+func (s *OAuthService) LoginURL() string {
+	return s.loginURL
+}
+
+// AuthorizeRegistration checks the initial access token required to register clients.
+// Registration is disabled when OAUTH_REGISTRATION_TOKEN is not set.
+func (s *OAuthService) AuthorizeRegistration(token string) bool {
+	return s.registrationToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.registrationToken)) == 1
+}
+
+// RegisterClient creates a client. Clients using the "none" auth method are public.
+func (s *OAuthService) RegisterClient(registration *ClientRegistration) (*RegisteredClient, error) {
+	grantTypes := registration.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+	}
+	for _, grantType := range grantTypes {
+		if grantType != GrantTypeAuthorizationCode && grantType != GrantTypeRefreshToken && grantType != GrantTypeClientCredentials {
+			return nil, newOAuthError("invalid_client_metadata", "unsupported grant type "+grantType)
+		}
+	}
+
+	authMethod := registration.TokenEndpointAuthMethod
+	if authMethod == "" {
+		authMethod = "client_secret_basic"
+	}
+	if authMethod != "client_secret_basic" && authMethod != "client_secret_post" && authMethod != "none" {
+		return nil, newOAuthError("invalid_client_metadata", "unsupported token_endpoint_auth_method")
+	}
+	if authMethod == "none" && slices.Contains(grantTypes, GrantTypeClientCredentials) {
+		return nil, newOAuthError("invalid_client_metadata", "public clients can't use client_credentials")
+	}
+
+	if slices.Contains(grantTypes, GrantTypeAuthorizationCode) && len(registration.RedirectURIs) == 0 {
+		return nil, newOAuthError("invalid_redirect_uri", "redirect_uris is required")
+	}
+	for _, redirectURI := range registration.RedirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, newOAuthError("invalid_redirect_uri", err.Error())
+		}
+	}
+
+	scope := registration.Scope
+	if scope == "" {
+		scope = strings.Join([]string{ScopeOpenID, ScopeProfile, ScopeEmail}, " ")
+	}
+	scopes := parseScope(scope)
+	for _, s := range scopes {
+		if !validScopeToken(s) {
+			return nil, newOAuthError("invalid_client_metadata", "invalid scope "+s)
+		}
+	}
+
+	clientID, err := generateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	client := &models.OAuthClient{
+		ClientID:     clientID,
+		Name:         registration.ClientName,
+		RedirectURIs: strings.Join(registration.RedirectURIs, " "),
+		GrantTypes:   strings.Join(grantTypes, " "),
+		Scopes:       strings.Join(scopes, " "),
+		FirstParty:   registration.FirstParty,
+	}
+
+	var secret string
+	if authMethod != "none" {
+		if secret, err = generateRandomToken(32); err != nil {
+			return nil, err
+		}
+		hash := hashToken(secret)
+		client.ClientSecretHash = &hash
+	}
+
+	if err := s.oauthRepo.CreateClient(client); err != nil {
+		return nil, err
+	}
+
+	return &RegisteredClient{
+		ClientID:                client.ClientID,
+		ClientSecret:            secret,
+		ClientName:              client.Name,
+		RedirectURIs:            client.RedirectURIList(),
+		GrantTypes:              client.GrantTypeList(),
+		Scope:                   client.Scopes,
+		TokenEndpointAuthMethod: authMethod,
+		FirstParty:              client.FirstParty,
+		ClientIDIssuedAt:        client.CreatedAt.Unix(),
+	}, nil
+}
+
+// ValidateClientRedirect checks the client and redirect URI of an authorization request and
+// returns the redirect URI to use. Any other problem is reported to the client by redirecting.
+func (s *OAuthService) ValidateClientRedirect(req *AuthorizationRequest) (*models.OAuthClient, string, error) {
+	client, err := s.oauthRepo.FindClientByClientID(req.ClientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if client == nil {
+		return nil, "", ErrInvalidAuthorizationClient
+	}
+
+	registered := client.RedirectURIList()
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(registered) == 1 {
+		redirectURI = registered[0]
+	}
+	if !slices.Contains(registered, redirectURI) {
+		return nil, "", ErrInvalidAuthorizationClient
+	}
+	return client, redirectURI, nil
+}
+
+// Authorize processes an authorization request for a signed in user. consent is the user's
+// decision on the consent screen, or empty when it hasn't been shown yet.
+// It returns ErrReauthenticationRequired when the client asked for a fresher login.
+func (s *OAuthService) Authorize(userID uint, authTime time.Time, req *AuthorizationRequest, consent string) (*AuthorizationResult, error) {
+	client, redirectURI, err := s.ValidateClientRedirect(req)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(code, description string) (*AuthorizationResult, error) {
+		return &AuthorizationResult{RedirectTo: errorRedirect(redirectURI, req.State, code, description)}, nil
+	}
+
+	if req.ResponseType != "code" {
+		return fail("unsupported_response_type", "only the code response type is supported")
+	}
+	if !slices.Contains(client.GrantTypeList(), GrantTypeAuthorizationCode) {
+		return fail("unauthorized_client", "client may not use the authorization code grant")
+	}
+
+	// PKCE is mandatory for public clients; only S256 is accepted
+	if req.CodeChallenge == "" && client.IsPublic() {
+		return fail("invalid_request", "code_challenge is required")
+	}
+	if req.CodeChallenge != "" && req.CodeChallengeMethod != "S256" {
+		return fail("invalid_request", "code_challenge_method must be S256")
+	}
+
+	scopes := parseScope(req.Scope)
+	if len(scopes) == 0 || !scopesAllowed(scopes, client.ScopeList()) {
+		return fail("invalid_scope", "requested scope is not allowed for this client")
+	}
+
+	prompts := strings.Fields(req.Prompt)
+	promptNone := slices.Contains(prompts, "none")
+	maxAge := time.Duration(-1)
+	if req.MaxAge != nil {
+		maxAge = time.Duration(*req.MaxAge) * time.Second
+	}
+	if slices.Contains(prompts, "login") && (maxAge < 0 || maxAge > s.reauthMaxAge) {
+		maxAge = s.reauthMaxAge
+	}
+	if maxAge >= 0 && time.Since(authTime) > maxAge {
+		if promptNone {
+			return fail("login_required", "the user must log in again")
+		}
+		return nil, ErrReauthenticationRequired
+	}
+
+	if consent == ConsentDeny {
+		return fail("access_denied", "the user denied the request")
+	}
+
+	if !client.FirstParty && consent != ConsentApprove {
+		granted, err := s.oauthRepo.FindConsent(userID, client.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		if granted == nil || !scopesAllowed(scopes, parseScope(granted.Scope)) || slices.Contains(prompts, "consent") {
+			if promptNone {
+				return fail("consent_required", "the user has not granted the requested scopes")
+			}
+			return &AuthorizationResult{ConsentRequired: true, Client: client, Scopes: scopes}, nil
+		}
+	}
+
+	if consent == ConsentApprove && !client.FirstParty {
+		if err := s.recordConsent(userID, client.ClientID, scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	code, err := generateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.oauthRepo.DeleteExpiredAuthorizationCodes(time.Now()); err != nil {
+		log.Printf("Failed to delete expired authorization codes: %v", err)
+	}
+	err = s.oauthRepo.CreateAuthorizationCode(&models.OAuthAuthorizationCode{
+		CodeHash:            hashToken(code),
+		ClientID:            client.ClientID,
+		UserID:              userID,
+		RedirectURI:         redirectURI,
+		Scope:               strings.Join(scopes, " "),
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            authTime,
+		ExpiresAt:           time.Now().Add(s.codeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"code": {code}, "iss": {s.issuer}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return &AuthorizationResult{RedirectTo: appendQuery(redirectURI, params)}, nil
+}
+
+// Token handles a token endpoint request
+func (s *OAuthService) Token(req *TokenRequest) (*OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(client.GrantTypeList(), req.GrantType) {
+		if req.GrantType != GrantTypeAuthorizationCode && req.GrantType != GrantTypeRefreshToken && req.GrantType != GrantTypeClientCredentials {
+			return nil, newOAuthError("unsupported_grant_type", "")
+		}
+		return nil, newOAuthError("unauthorized_client", "client may not use this grant type")
+	}
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return s.exchangeAuthorizationCode(client, req)
+	case GrantTypeRefreshToken:
+		return s.exchangeRefreshToken(client, req)
+	default:
+		return s.clientCredentials(client, req)
+	}
+}
+
+// UserInfo returns the claims about the token's user its scopes release
+func (s *OAuthService) UserInfo(accessToken string) (map[string]any, error) {
+	claims, err := s.authService.ValidateOAuthAccessToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID == 0 || !slices.Contains(claims.Scopes, ScopeOpenID) {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+	return releasedUserClaims(user, claims.Scopes), nil
+}
+
+// Introspect describes a token to a confidential client such as the API gateway.
+// Access tokens of any client are described, honoring server-side revocation;
+// refresh tokens only to the client they were issued to.
+func (s *OAuthService) Introspect(clientID, clientSecret, token, tokenTypeHint string) (*IntrospectionResponse, error) {
+	client, err := s.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if client.IsPublic() {
+		return nil, newOAuthError("invalid_client", "public clients can't introspect tokens")
+	}
+	if token == "" {
+		return nil, newOAuthError("invalid_request", "token is required")
+	}
+
+	lookups := []func(*models.OAuthClient, string) (*IntrospectionResponse, error){
+		s.introspectAccessToken, s.introspectRefreshToken,
+	}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		slices.Reverse(lookups)
+	}
+	for _, lookup := range lookups {
+		response, err := lookup(client, token)
+		if err != nil || response != nil {
+			return response, err
+		}
+	}
+	return &IntrospectionResponse{Active: false}, nil
+}
+
+// Revoke revokes an access or refresh token issued to the client (RFC 7009). Revoking a
+// refresh token revokes its whole family. Invalid tokens and tokens of other clients are
+// silently ignored, so the response doesn't reveal whether a token existed.
+func (s *OAuthService) Revoke(clientID, clientSecret, token, tokenTypeHint string) error {
+	client, err := s.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return newOAuthError("invalid_request", "token is required")
+	}
+
+	revokeRefresh := func() (bool, error) {
+		return s.refreshTokenService.RevokeForClient(token, client.ClientID)
+	}
+	revokeAccess := func() (bool, error) {
+		claims, err := s.authService.validateToken(token, TokenUseOAuthAccess)
+		if err != nil || claims.ClientID != client.ClientID {
+			return false, nil
+		}
+		return true, s.revocationService.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time)
+	}
+
+	revokers := []func() (bool, error){revokeRefresh, revokeAccess}
+	if tokenTypeHint == TokenTypeHintAccessToken {
+		slices.Reverse(revokers)
+	}
+	for _, revoke := range revokers {
+		if found, err := revoke(); err != nil || found {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discovery returns the OpenID Provider metadata document
+func (s *OAuthService) Discovery() map[string]any {
+	document := map[string]any{
+		"issuer":                                        s.issuer,
+		"authorization_endpoint":                        s.issuer + "/oauth/authorize",
+		"token_endpoint":                                s.issuer + "/oauth/token",
+		"userinfo_endpoint":                             s.issuer + "/userinfo",
+		"jwks_uri":                                      s.issuer + "/.well-known/jwks.json",
+		"scopes_supported":                              userScopes,
+		"response_types_supported":                      []string{"code"},
+		"response_modes_supported":                      []string{"query"},
+		"grant_types_supported":                         []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials},
+		"subject_types_supported":                       []string{"public"},
+		"id_token_signing_alg_values_supported":         []string{s.keyManager.Algorithm()},
+		"token_endpoint_auth_methods_supported":         []string{"client_secret_basic", "client_secret_post", "none"},
+		"introspection_endpoint":                        s.issuer + "/oauth/introspect",
+		"revocation_endpoint":                           s.issuer + "/oauth/revoke",
+		"introspection_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"revocation_endpoint_auth_methods_supported":    []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":              []string{"S256"},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"preferred_username", "name", "picture", "email", "email_verified", "preferences",
+		},
+		"authorization_response_iss_parameter_supported": true,
+	}
+	if s.registrationToken != "" {
+		document["registration_endpoint"] = s.issuer + "/oauth/clients"
+	}
+	return document
+}
+
+// introspectAccessToken describes a valid first-party or OAuth access token, or returns nil
+func (s *OAuthService) introspectAccessToken(_ *models.OAuthClient, token string) (*IntrospectionResponse, error) {
+	var claims *Claims
+	for _, tokenUse := range []string{TokenUseAccess, TokenUseOAuthAccess} {
+		var err error
+		claims, err = s.authService.validateToken(token, tokenUse)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrTokenRevoked) {
+			return nil, err
+		}
+	}
+	if claims == nil {
+		return nil, nil
+	}
+
+	response := &IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(claims.Scopes, " "),
+		ClientID:  claims.ClientID,
+		Username:  claims.Username,
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		TokenID:   claims.ID,
+	}
+	if claims.NotBefore != nil {
+		response.NotBefore = claims.NotBefore.Unix()
+	}
+	if claims.AuthTime != nil {
+		response.AuthTime = claims.AuthTime.Unix()
+	}
+	return response, nil
+}
+
+// introspectRefreshToken describes a redeemable refresh token issued to the client, or returns nil
+func (s *OAuthService) introspectRefreshToken(client *models.OAuthClient, token string) (*IntrospectionResponse, error) {
+	record, err := s.refreshTokenService.Inspect(token)
+	if err != nil || record == nil || record.ClientID != client.ClientID {
+		return nil, err
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     record.Scope,
+		ClientID:  record.ClientID,
+		TokenType: TokenTypeHintRefreshToken,
+		ExpiresAt: record.ExpiresAt.Unix(),
+		IssuedAt:  record.CreatedAt.Unix(),
+		Subject:   subjectForUser(record.UserID),
+		Issuer:    s.issuer,
+		AuthTime:  record.AuthTime.Unix(),
+	}, nil
+}
+
+// exchangeAuthorizationCode redeems an authorization code. A replayed code revokes
+// the refresh tokens issued for it, as recommended by RFC 6749 section 4.1.2.
+func (s *OAuthService) exchangeAuthorizationCode(client *models.OAuthClient, req *TokenRequest) (*OAuthTokenResponse, error) {
+	if req.Code == "" {
+		return nil, newOAuthError("invalid_request", "code is required")
+	}
+	code, err := s.oauthRepo.FindAuthorizationCodeByHash(hashToken(req.Code))
+	if err != nil {
+		return nil, err
+	}
+	if code == nil || code.ClientID != client.ClientID || time.Now().After(code.ExpiresAt) {
+		return nil, newOAuthError("invalid_grant", "invalid or expired authorization code")
+	}
+	if code.RedirectURI != req.RedirectURI {
+		return nil, newOAuthError("invalid_grant", "redirect_uri does not match the authorization request")
+	}
+	if err := verifyCodeChallenge(code, req.CodeVerifier); err != nil {
+		return nil, err
+	}
+
+	used, err := s.oauthRepo.MarkAuthorizationCodeUsed(code.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		if code.RefreshFamilyID != "" {
+			if err := s.refreshTokenService.RevokeFamily(code.RefreshFamilyID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, newOAuthError("invalid_grant", "authorization code was already used")
+	}
+
+	user, err := s.userRepo.FindByID(code.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, newOAuthError("invalid_grant", "user no longer exists")
+	}
+	if err := UserStatusError(user.Status); err != nil {
+		return nil, newOAuthError("invalid_grant", err.Error())
+	}
+
+	scopes := parseScope(code.Scope)
+	response, err := s.issueUserTokens(client, user, scopes, code.AuthTime, code.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.refreshFamilyID != "" {
+		if err := s.oauthRepo.SetAuthorizationCodeFamily(code.ID, response.refreshFamilyID); err != nil {
+			return nil, err
+		}
+	}
+	return &response.OAuthTokenResponse, nil
+}
+
+// exchangeRefreshToken rotates a refresh token issued to the client. The client may ask
+// for a subset of the originally granted scopes.
+func (s *OAuthService) exchangeRefreshToken(client *models.OAuthClient, req *TokenRequest) (*OAuthTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, newOAuthError("invalid_request", "refresh_token is required")
+	}
+
+	// Check the request against the grant before rotating: a rejected request must leave the
+	// token redeemable, or the client's retry with it would be taken for a reuse
+	current, err := s.refreshTokenService.Inspect(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.ClientID != client.ClientID {
+		// Rotate rejects the token, revoking its family if it was already rotated
+		_, _, err := s.refreshTokenService.Rotate(req.RefreshToken, client.ClientID)
+		return nil, refreshTokenGrantError(err)
+	}
+
+	granted := parseScope(current.Scope)
+	scopes := granted
+	if req.Scope != "" {
+		scopes = parseScope(req.Scope)
+		if !scopesAllowed(scopes, granted) {
+			return nil, newOAuthError("invalid_scope", "requested scope exceeds the original grant")
+		}
+	}
+
+	user, err := s.userRepo.FindByID(current.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, newOAuthError("invalid_grant", "user no longer exists")
+	}
+	if err := UserStatusError(user.Status); err != nil {
+		return nil, newOAuthError("invalid_grant", err.Error())
+	}
+
+	newRefreshToken, record, err := s.refreshTokenService.Rotate(req.RefreshToken, client.ClientID)
+	if err != nil {
+		return nil, refreshTokenGrantError(err)
+	}
+
+	accessToken, err := s.signClientAccessToken(client, user.ID, scopes, record.AuthTime)
+	if err != nil {
+		return nil, err
+	}
+	response := &OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.authService.AccessTokenTTL().Seconds()),
+		RefreshToken: newRefreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}
+	if slices.Contains(scopes, ScopeOpenID) {
+		if response.IDToken, err = s.signIDToken(client, user, scopes, record.AuthTime, ""); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// refreshTokenGrantError maps the error of a refresh token that can't be rotated to invalid_grant.
+// A nil error, from a token Inspect already rejected, still means the grant is invalid.
+func refreshTokenGrantError(err error) error {
+	switch {
+	case err == nil:
+		return newOAuthError("invalid_grant", ErrInvalidRefreshToken.Error())
+	case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused):
+		return newOAuthError("invalid_grant", err.Error())
+	default:
+		return err
+	}
+}
+
+// clientCredentials issues an access token to a confidential client acting on its own behalf
+func (s *OAuthService) clientCredentials(client *models.OAuthClient, req *TokenRequest) (*OAuthTokenResponse, error) {
+	if client.IsPublic() {
+		return nil, newOAuthError("unauthorized_client", "public clients can't use client_credentials")
+	}
+
+	var allowed []string
+	for _, scope := range client.ScopeList() {
+		if !isUserScope(scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+	scopes := allowed
+	if req.Scope != "" {
+		scopes = parseScope(req.Scope)
+		if !scopesAllowed(scopes, allowed) {
+			return nil, newOAuthError("invalid_scope", "requested scope is not allowed for this client")
+		}
+	}
+
+	accessToken, err := s.signClientAccessToken(client, 0, scopes, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.authService.AccessTokenTTL().Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// userTokenResponse is a token response plus the refresh family created for it
+type userTokenResponse struct {
+	OAuthTokenResponse
+	refreshFamilyID string
+}
+
+// issueUserTokens creates the access, refresh and ID tokens for a user authorization
+func (s *OAuthService) issueUserTokens(client *models.OAuthClient, user *models.User, scopes []string, authTime time.Time, nonce string) (*userTokenResponse, error) {
+	accessToken, err := s.signClientAccessToken(client, user.ID, scopes, authTime)
+	if err != nil {
+		return nil, err
+	}
+	response := &userTokenResponse{OAuthTokenResponse: OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.authService.AccessTokenTTL().Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}}
+
+	// OpenID Connect only hands out refresh tokens for offline_access; plain OAuth
+	// clients get one whenever they are allowed to use the refresh grant
+	wantsRefresh := slices.Contains(client.GrantTypeList(), GrantTypeRefreshToken) &&
+		(!slices.Contains(scopes, ScopeOpenID) || slices.Contains(scopes, ScopeOfflineAccess))
+	if wantsRefresh {
+		refreshToken, record, err := s.refreshTokenService.IssueForClient(user.ID, client.ClientID, strings.Join(scopes, " "), authTime)
+		if err != nil {
+			return nil, err
+		}
+		response.RefreshToken = refreshToken
+		response.refreshFamilyID = record.FamilyID
+	}
+
+	if slices.Contains(scopes, ScopeOpenID) {
+		if response.IDToken, err = s.signIDToken(client, user, scopes, authTime, nonce); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// signClientAccessToken signs an access token limited to the client and scopes
+func (s *OAuthService) signClientAccessToken(client *models.OAuthClient, userID uint, scopes []string, authTime time.Time) (string, error) {
+	claims := &Claims{
+		TokenUse: TokenUseOAuthAccess,
+		UserID:   userID,
+		ClientID: client.ClientID,
+		Scopes:   scopes,
+	}
+	if userID == 0 {
+		claims.Subject = client.ClientID
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authTime)
+	}
+	return s.authService.SignToken(claims, s.authService.AccessTokenTTL())
+}
+
+// signIDToken signs an OpenID Connect ID token for the client
+func (s *OAuthService) signIDToken(client *models.OAuthClient, user *models.User, scopes []string, authTime time.Time, nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       s.issuer,
+		"aud":       client.ClientID,
+		"azp":       client.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.idTokenTTL).Unix(),
+		"auth_time": authTime.Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	for name, value := range releasedUserClaims(user, scopes) {
+		// Preferences can be large; clients fetch them from the userinfo endpoint
+		if name != "preferences" {
+			claims[name] = value
+		}
+	}
+	return s.keyManager.Sign(claims)
+}
+
+// authenticateClient checks the client's credentials. Public clients authenticate with
+// their client ID alone and must not send a secret.
+func (s *OAuthService) authenticateClient(clientID, clientSecret string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, newOAuthError("invalid_client", "client authentication failed")
+	}
+	client, err := s.oauthRepo.FindClientByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, newOAuthError("invalid_client", "client authentication failed")
+	}
+
+	if client.IsPublic() {
+		if clientSecret != "" {
+			return nil, newOAuthError("invalid_client", "client authentication failed")
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(*client.ClientSecretHash)) != 1 {
+		return nil, newOAuthError("invalid_client", "client authentication failed")
+	}
+	return client, nil
+}
+
+// recordConsent adds the scopes to what the user already granted the client
+func (s *OAuthService) recordConsent(userID uint, clientID string, scopes []string) error {
+	existing, err := s.oauthRepo.FindConsent(userID, clientID)
+	if err != nil {
+		return err
+	}
+	granted := scopes
+	if existing != nil {
+		granted = parseScope(existing.Scope + " " + strings.Join(scopes, " "))
+	}
+	return s.oauthRepo.SaveConsent(&models.OAuthConsent{
+		UserID:   userID,
+		ClientID: clientID,
+		Scope:    strings.Join(granted, " "),
+	})
+}
+
+// verifyCodeChallenge checks the PKCE verifier against the challenge of the authorization request
+func verifyCodeChallenge(code *models.OAuthAuthorizationCode, verifier string) error {
+	if code.CodeChallenge == "" {
+		if verifier != "" {
+			return newOAuthError("invalid_grant", "code_verifier sent without a code_challenge")
+		}
+		return nil
+	}
+	if verifier == "" {
+		return newOAuthError("invalid_grant", "code_verifier is required")
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(code.CodeChallenge)) != 1 {
+		return newOAuthError("invalid_grant", "code_verifier does not match the code_challenge")
+	}
+	return nil
+}
+
+// validateRedirectURI accepts absolute URIs without fragments. Plain http is only allowed
+// for loopback addresses; custom schemes are allowed for native apps.
+func validateRedirectURI(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || !parsed.IsAbs() {
+		return fmt.Errorf("redirect URI %q must be absolute", raw)
+	}
+	if parsed.Fragment != "" {
+		return fmt.Errorf("redirect URI %q must not contain a fragment", raw)
+	}
+	if parsed.Scheme == "http" {
+		host := parsed.Hostname()
+		if host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return fmt.Errorf("redirect URI %q must use https", raw)
+		}
+	}
+	return nil
+}
+
+// errorRedirect builds the redirect that reports an authorization error to the client
+func errorRedirect(redirectURI, state, code, description string) string {
+	params := url.Values{"error": {code}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return appendQuery(redirectURI, params)
+}
+
+// appendQuery adds params to the query of a URI that may already have one
+func appendQuery(uri string, params url.Values) string {
+	separator := "?"
+	if strings.Contains(uri, "?") {
+		separator = "&"
+	}
+	return uri + separator + params.Encode()
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// fakeRefreshTokenRepository keeps refresh tokens in memory; other methods are not used by these tests
+type fakeRefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens []*models.RefreshToken
+}
+
+func (r *fakeRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uint(len(r.tokens) + 1)
+	token.CreatedAt = time.Now()
+	stored := *token
+	r.tokens = append(r.tokens, &stored)
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRefreshTokenRepository) FindByUserID(userID uint) ([]models.RefreshToken, error) {
+	return nil, nil
+}
+
+func (r *fakeRefreshTokenRepository) MarkRotated(id uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.ID == id && token.RotatedAt == nil && token.RevokedAt == nil {
+			now := time.Now()
+			token.RotatedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeFamily(familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeAllForUser(userID uint) error {
+	return nil
+}
+
+// newTestOAuthService builds an OAuthService with an active user 42 and a confidential client
+func newTestOAuthService(t *testing.T) (*OAuthService, *models.OAuthClient, *models.User) {
+	t.Helper()
+	authService, revocationService := newTestAuthService(t)
+
+	user := &models.User{ID: 42, Email: "ada@example.com", Username: "ada", Status: models.UserStatusActive}
+	service := &OAuthService{
+		userRepo:            &fakeUserRepository{users: []*models.User{user}},
+		authService:         authService,
+		refreshTokenService: NewRefreshTokenServiceWithRepo(&fakeRefreshTokenRepository{}),
+		revocationService:   revocationService,
+		keyManager:          authService.keyManager,
+		issuer:              "https://id.example.com",
+		idTokenTTL:          time.Hour,
+	}
+	return service, &models.OAuthClient{ClientID: "client"}, user
+}
+
+// oauthErrorCode returns the OAuth error code of err, or "" if it isn't an OAuthError
+func oauthErrorCode(err error) string {
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
+
+func TestExchangeRefreshTokenRejectedScopeKeepsToken(t *testing.T) {
+	service, client, user := newTestOAuthService(t)
+	refreshToken, _, err := service.refreshTokenService.IssueForClient(user.ID, client.ClientID, "openid profile", time.Now())
+	if err != nil {
+		t.Fatalf("IssueForClient error: %v", err)
+	}
+
+	_, err = service.exchangeRefreshToken(client, &TokenRequest{RefreshToken: refreshToken, Scope: "openid email"})
+	if code := oauthErrorCode(err); code != "invalid_scope" {
+		t.Fatalf("broader scope: got %v, want invalid_scope", err)
+	}
+
+	// The retry with the same token is not mistaken for a reuse
+	response, err := service.exchangeRefreshToken(client, &TokenRequest{RefreshToken: refreshToken, Scope: "openid"})
+	if err != nil {
+		t.Fatalf("retry error: %v", err)
+	}
+	if response.Scope != "openid" || response.RefreshToken == "" || response.IDToken == "" {
+		t.Errorf("response = %+v, want openid tokens", response)
+	}
+
+	// Replaying the rotated token still revokes its family
+	if _, err := service.exchangeRefreshToken(client, &TokenRequest{RefreshToken: refreshToken}); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("replayed token: got %v, want invalid_grant", err)
+	}
+	if _, err := service.exchangeRefreshToken(client, &TokenRequest{RefreshToken: response.RefreshToken}); oauthErrorCode(err) != "invalid_grant" {
+		t.Errorf("token of a replayed family: got %v, want invalid_grant", err)
+	}
+}
+
+func TestExchangeRefreshTokenInactiveUserKeepsToken(t *testing.T) {
+	service, client, user := newTestOAuthService(t)
+	refreshToken, _, err := service.refreshTokenService.IssueForClient(user.ID, client.ClientID, "openid", time.Now())
+	if err != nil {
+		t.Fatalf("IssueForClient error: %v", err)
+	}
+
+	user.Status = models.UserStatusSuspended
+	if _, err := service.exchangeRefreshToken(client, &TokenRequest{RefreshToken: refreshToken}); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("suspended user: got %v, want invalid_grant", err)
+	}
+	if record, err := service.refreshTokenService.Inspect(refreshToken); err != nil || record == nil {
+		t.Errorf("token was consumed by a rejected request: %v, %v", record, err)
+	}
+}
+
+func TestExchangeRefreshTokenOtherClient(t *testing.T) {
+	service, client, user := newTestOAuthService(t)
+	refreshToken, _, err := service.refreshTokenService.IssueForClient(user.ID, client.ClientID, "openid", time.Now())
+	if err != nil {
+		t.Fatalf("IssueForClient error: %v", err)
+	}
+
+	other := &models.OAuthClient{ClientID: "other"}
+	if _, err := service.exchangeRefreshToken(other, &TokenRequest{RefreshToken: refreshToken}); oauthErrorCode(err) != "invalid_grant" {
+		t.Fatalf("other client: got %v, want invalid_grant", err)
+	}
+	if _, err := service.exchangeRefreshToken(client, &TokenRequest{RefreshToken: refreshToken}); err != nil {
+		t.Errorf("owning client refused after another client tried the token: %v", err)
+	}
+}
@@ -0,0 +1,432 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Provider kinds: standard OpenID Connect, or GitHub's plain OAuth 2.0 with its REST user API
+const (
+	OIDCProviderKindOIDC   = "oidc"
+	OIDCProviderKindGitHub = "github"
+)
+
+// ErrInvalidIDToken is returned when a provider's ID token fails verification
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// OIDCProviderConfig describes an external identity provider
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Kind         string
+	Issuer       string // Used for discovery; endpoints below override discovered values
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURLs []string // The first one is used when the client doesn't pick one
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	JWKSURL      string
+}
+
+// oidcPresets hold the well-known settings of popular providers; environment variables override them
+var oidcPresets = map[string]OIDCProviderConfig{
+	"google": {
+		DisplayName: "Google",
+		Kind:        OIDCProviderKindOIDC,
+		Issuer:      "https://accounts.google.com",
+		Scopes:      []string{"openid", "email", "profile"},
+	},
+	"microsoft": {
+		DisplayName: "Microsoft",
+		Kind:        OIDCProviderKindOIDC,
+		Issuer:      "https://login.microsoftonline.com/common/v2.0",
+		Scopes:      []string{"openid", "email", "profile"},
+	},
+	"github": {
+		DisplayName: "GitHub",
+		Kind:        OIDCProviderKindGitHub,
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		Scopes:      []string{"read:user", "user:email"},
+	},
+}
+
+// LoadOIDCProviderConfigs reads the providers listed in OIDC_PROVIDERS. Each provider NAME
+// is configured with OIDC_<NAME>_CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URLS and, for
+// providers without a preset, _ISSUER (plus optional _SCOPES, _DISPLAY_NAME and endpoint overrides).
+func LoadOIDCProviderConfigs() []OIDCProviderConfig {
+	var configs []OIDCProviderConfig
+	for _, name := range config.List("OIDC_PROVIDERS") {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		cfg := oidcPresets[name]
+		cfg.Name = name
+		cfg.DisplayName = config.String(prefix+"DISPLAY_NAME", cfg.DisplayName)
+		cfg.Kind = config.String(prefix+"KIND", cfg.Kind)
+		cfg.Issuer = config.String(prefix+"ISSUER", cfg.Issuer)
+		cfg.ClientID = config.String(prefix+"CLIENT_ID", "")
+		cfg.ClientSecret = config.String(prefix+"CLIENT_SECRET", "")
+		cfg.RedirectURLs = config.List(prefix + "REDIRECT_URLS")
+		cfg.AuthURL = config.String(prefix+"AUTH_URL", cfg.AuthURL)
+		cfg.TokenURL = config.String(prefix+"TOKEN_URL", cfg.TokenURL)
+		cfg.UserInfoURL = config.String(prefix+"USERINFO_URL", cfg.UserInfoURL)
+		cfg.JWKSURL = config.String(prefix+"JWKS_URL", cfg.JWKSURL)
+		if scopes := config.List(prefix + "SCOPES"); len(scopes) > 0 {
+			cfg.Scopes = scopes
+		}
+		if cfg.Kind == "" {
+			cfg.Kind = OIDCProviderKindOIDC
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = name
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"openid", "email", "profile"}
+		}
+		configs = append(configs, cfg)
+	}
+	return configs
+}
+
+// oidcDiscovery is the subset of the provider metadata document we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcTokenResponse is the token endpoint response
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// OIDCProvider runs the authorization code flow against one external provider.
+// Provider metadata is discovered lazily from the issuer and cached.
+type OIDCProvider struct {
+	config OIDCProviderConfig
+	client *http.Client
+	leeway time.Duration
+
+	mu        sync.Mutex
+	metadata  *oidcDiscovery
+	keys      KeySource
+	lastError time.Time
+}
+
+// NewOIDCProvider creates a provider from its configuration
+func NewOIDCProvider(cfg OIDCProviderConfig) *OIDCProvider {
+	return &OIDCProvider{
+		config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		leeway: config.Duration("OIDC_TOKEN_LEEWAY", time.Minute),
+	}
+}
+
+// Name returns the provider's configured name
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// DisplayName returns the name to show on login buttons
+func (p *OIDCProvider) DisplayName() string {
+	return p.config.DisplayName
+}
+
+// RedirectURL returns redirectURL if it is registered for the provider, or the default when it is empty
+func (p *OIDCProvider) RedirectURL(redirectURL string) (string, error) {
+	if len(p.config.RedirectURLs) == 0 {
+		return "", fmt.Errorf("provider %s has no redirect URL configured", p.config.Name)
+	}
+	if redirectURL == "" {
+		return p.config.RedirectURLs[0], nil
+	}
+	for _, allowed := range p.config.RedirectURLs {
+		if allowed == redirectURL {
+			return redirectURL, nil
+		}
+	}
+	return "", errors.New("redirect URL is not allowed for this provider")
+}
+
+// AuthCodeURL builds the URL the user is sent to, with PKCE (S256) and, for OIDC providers, a nonce
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier, redirectURL string) (string, error) {
+	metadata, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if p.config.Kind == OIDCProviderKindOIDC {
+		params.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified identity of the user
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, redirectURL, nonce string) (*FederatedIdentity, error) {
+	metadata, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens oidcTokenResponse
+	if err := p.doJSON(req, &tokens); err != nil {
+		return nil, fmt.Errorf("token exchange with %s failed: %w", p.config.Name, err)
+	}
+	if tokens.Error != "" {
+		return nil, fmt.Errorf("token exchange with %s failed: %s %s", p.config.Name, tokens.Error, tokens.Description)
+	}
+
+	if p.config.Kind == OIDCProviderKindGitHub {
+		return p.gitHubIdentity(ctx, tokens.AccessToken)
+	}
+	return p.oidcIdentity(ctx, metadata, &tokens, nonce)
+}
+
+// oidcIdentity verifies the ID token and, when it lacks an email, completes it from the userinfo endpoint
+func (p *OIDCProvider) oidcIdentity(ctx context.Context, metadata *oidcDiscovery, tokens *oidcTokenResponse, nonce string) (*FederatedIdentity, error) {
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: provider returned no ID token", ErrInvalidIDToken)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokens.IDToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.Key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "PS256"}),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(p.leeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	// Multi-tenant issuers (Microsoft "common") publish a templated issuer
+	issuer := strings.ReplaceAll(metadata.Issuer, "{tenantid}", stringClaim(claims, "tid"))
+	if stringClaim(claims, "iss") != issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	}
+	if stringClaim(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if azp := stringClaim(claims, "azp"); azp != "" && azp != p.config.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party", ErrInvalidIDToken)
+	}
+
+	identity := &FederatedIdentity{
+		Provider:          p.config.Name,
+		Subject:           stringClaim(claims, "sub"),
+		Email:             stringClaim(claims, "email"),
+		EmailVerified:     boolClaim(claims, "email_verified"),
+		PreferredUsername: stringClaim(claims, "preferred_username"),
+		Claims:            claims,
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	if identity.Email == "" && metadata.UserInfoEndpoint != "" && tokens.AccessToken != "" {
+		userInfo := map[string]any{}
+		if err := p.getJSON(ctx, metadata.UserInfoEndpoint, tokens.AccessToken, &userInfo); err != nil {
+			return nil, err
+		}
+		// The userinfo response must describe the same user as the ID token
+		if stringClaim(userInfo, "sub") == identity.Subject {
+			identity.Email = stringClaim(userInfo, "email")
+			identity.EmailVerified = boolClaim(userInfo, "email_verified")
+		}
+	}
+
+	return identity, nil
+}
+
+// gitHubIdentity loads the user and their primary verified email from the GitHub API
+func (p *OIDCProvider) gitHubIdentity(ctx context.Context, accessToken string) (*FederatedIdentity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Email string `json:"email"`
+	}
+	if err := p.getJSON(ctx, p.config.UserInfoURL, accessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("GitHub returned no user ID")
+	}
+
+	identity := &FederatedIdentity{
+		Provider:          p.config.Name,
+		Subject:           strconv.FormatInt(user.ID, 10),
+		PreferredUsername: user.Login,
+		Claims:            map[string]any{"id": user.ID, "login": user.Login},
+	}
+
+	// The profile email is optional and unverified; the emails endpoint says which ones are verified
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.UserInfoURL, "/user")+"/user/emails", accessToken, &emails); err == nil {
+		for _, email := range emails {
+			if email.Primary {
+				identity.Email = email.Email
+				identity.EmailVerified = email.Verified
+			}
+		}
+	}
+	if identity.Email == "" {
+		identity.Email = user.Email
+	}
+
+	return identity, nil
+}
+
+// discover loads the provider metadata and key set once. Failures are retried after a short delay.
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	if time.Since(p.lastError) < 10*time.Second {
+		return nil, fmt.Errorf("discovery for %s failed recently", p.config.Name)
+	}
+
+	metadata := &oidcDiscovery{Issuer: p.config.Issuer}
+	if p.config.Issuer != "" && p.config.Kind == OIDCProviderKindOIDC {
+		req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.doJSON(req, metadata); err != nil {
+			p.lastError = time.Now()
+			return nil, fmt.Errorf("discovery for %s failed: %w", p.config.Name, err)
+		}
+	}
+
+	// Explicitly configured endpoints win over discovered ones
+	if p.config.AuthURL != "" {
+		metadata.AuthorizationEndpoint = p.config.AuthURL
+	}
+	if p.config.TokenURL != "" {
+		metadata.TokenEndpoint = p.config.TokenURL
+	}
+	if p.config.UserInfoURL != "" {
+		metadata.UserInfoEndpoint = p.config.UserInfoURL
+	}
+	if p.config.JWKSURL != "" {
+		metadata.JWKSURI = p.config.JWKSURL
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		return nil, fmt.Errorf("provider %s has no authorization or token endpoint", p.config.Name)
+	}
+	if p.config.Kind == OIDCProviderKindOIDC {
+		if metadata.JWKSURI == "" || metadata.Issuer == "" {
+			return nil, fmt.Errorf("provider %s has no issuer or JWKS URI", p.config.Name)
+		}
+		p.keys = NewRemoteJWKS(metadata.JWKSURI, config.Duration("OIDC_JWKS_CACHE_TTL", 24*time.Hour))
+	}
+
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// getJSON performs an authenticated GET request and decodes the JSON response
+func (p *OIDCProvider) getJSON(ctx context.Context, endpoint, accessToken string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return p.doJSON(req, target)
+}
+
+// doJSON sends a request and decodes the JSON response, failing on non-2xx statuses
+// other than the 400 used by token endpoints to report OAuth errors
+func (p *OIDCProvider) doJSON(req *http.Request, target any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return json.Unmarshal(body, target)
+}
+
+// stringClaim returns a string claim or "" when it is missing or not a string
+func stringClaim(claims map[string]any, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// boolClaim interprets a boolean claim that may be encoded as a bool or a string
+func boolClaim(claims map[string]any, name string) bool {
+	return appleBool(claims[name])
+}
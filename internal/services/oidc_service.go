@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+var (
+	// ErrUnknownOIDCProvider is returned for providers that aren't configured
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+
+	// ErrInvalidOIDCState is returned for unknown, expired or already used authorization states
+	ErrInvalidOIDCState = errors.New("invalid or expired authorization state")
+)
+
+// OIDCProviderInfo describes a configured provider to clients
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// OIDCAuthorization is returned when a login with an external provider starts
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+// OIDCService runs authorization code + PKCE logins with the configured external providers.
+// The PKCE verifier and nonce stay in the database, keyed by the hash of the state parameter.
+type OIDCService struct {
+	identityRepo    interfaces.IdentityRepository
+	identityService *IdentityService
+	providers       map[string]*OIDCProvider
+	order           []string
+	stateTTL        time.Duration
+}
+
+// NewOIDCService creates a new OIDCService with the providers configured in the environment
+func NewOIDCService() *OIDCService {
+	factory := repositories.NewFactory()
+	service := &OIDCService{
+		identityRepo:    factory.GetIdentityRepository(),
+		identityService: NewIdentityService(),
+		providers:       make(map[string]*OIDCProvider),
+		stateTTL:        config.Duration("OIDC_STATE_TTL", 10*time.Minute),
+	}
+	for _, cfg := range LoadOIDCProviderConfigs() {
+		if cfg.ClientID == "" {
+			log.Printf("OIDC provider %s has no client ID, skipping it", cfg.Name)
+			continue
+		}
+		service.providers[cfg.Name] = NewOIDCProvider(cfg)
+		service.order = append(service.order, cfg.Name)
+	}
+	return service
+}
+
+// Providers lists the configured providers in configuration order
+func (s *OIDCService) Providers() []OIDCProviderInfo {
+	providers := make([]OIDCProviderInfo, 0, len(s.order))
+	for _, name := range s.order {
+		provider := s.providers[name]
+		providers = append(providers, OIDCProviderInfo{Name: provider.Name(), DisplayName: provider.DisplayName()})
+	}
+	return providers
+}
+
+// BeginAuthorization starts a login with the provider and returns the URL to send the user to.
+// redirectURL must be one of the provider's registered redirect URLs; empty selects the default.
+func (s *OIDCService) BeginAuthorization(providerName, redirectURL string) (*OIDCAuthorization, error) {
+	return s.begin(providerName, redirectURL, nil)
+}
+
+// BeginLink starts linking an identity at the provider to the user's account.
+// The flow is bound to the user so a code obtained by someone else can't be linked.
+func (s *OIDCService) BeginLink(userID uint, providerName, redirectURL string) (*OIDCAuthorization, error) {
+	return s.begin(providerName, redirectURL, &userID)
+}
+
+// begin creates an authorization request for a login (userID nil) or a link
+func (s *OIDCService) begin(providerName, redirectURL string, userID *uint) (*OIDCAuthorization, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+	redirectURL, err := provider.RedirectURL(redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := generateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := generateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := generateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, codeVerifier, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identityRepo.DeleteExpiredAuthRequests(time.Now()); err != nil {
+		log.Printf("Failed to delete expired OIDC authorization requests: %v", err)
+	}
+	err = s.identityRepo.CreateAuthRequest(&models.OIDCAuthRequest{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		UserID:       userID,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		RedirectURI:  redirectURL,
+		ExpiresAt:    time.Now().Add(s.stateTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCAuthorization{AuthorizationURL: authURL, State: state}, nil
+}
+
+// CompleteAuthorization redeems the code the provider returned with state and signs the user in
+func (s *OIDCService) CompleteAuthorization(ctx context.Context, providerName, code, state string) (*models.User, error) {
+	identity, err := s.redeem(ctx, providerName, code, state, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.identityService.SignIn(identity)
+}
+
+// CompleteLink redeems the code of a flow started with BeginLink and returns the verified identity
+func (s *OIDCService) CompleteLink(ctx context.Context, userID uint, providerName, code, state string) (*FederatedIdentity, error) {
+	return s.redeem(ctx, providerName, code, state, &userID)
+}
+
+// redeem consumes the authorization request matching state, checks it was started for the
+// same purpose (login or linking by userID), and exchanges the code for the identity
+func (s *OIDCService) redeem(ctx context.Context, providerName, code, state string, userID *uint) (*FederatedIdentity, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	request, err := s.identityRepo.ConsumeAuthRequest(hashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	if request == nil || request.Provider != providerName {
+		return nil, ErrInvalidOIDCState
+	}
+	if (request.UserID == nil) != (userID == nil) || (userID != nil && *request.UserID != *userID) {
+		return nil, ErrInvalidOIDCState
+	}
+
+	return provider.Exchange(ctx, code, request.CodeVerifier, request.RedirectURI, request.Nonce)
+}
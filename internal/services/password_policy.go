@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+)
+
+// bcrypt ignores everything after the first 72 bytes of a password
+const maxPasswordBytes = 72
+
+// PasswordPolicyError describes why a password was rejected
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password " + e.Reason
+}
+
+// ValidatePassword checks a new password against the password policy:
+// at least PASSWORD_MIN_LENGTH characters (default 8), at most 72 bytes,
+// and containing both a letter and a digit or symbol
+func ValidatePassword(password string) error {
+	minLength := config.Int("PASSWORD_MIN_LENGTH", 8)
+
+	if len([]rune(password)) < minLength {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("must be at least %d characters long", minLength)}
+	}
+	if len(password) > maxPasswordBytes {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("must be at most %d bytes long", maxPasswordBytes)}
+	}
+
+	var hasLetter, hasOther bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		} else if !unicode.IsSpace(r) {
+			hasOther = true
+		}
+	}
+	if !hasLetter || !hasOther {
+		return &PasswordPolicyError{Reason: "must contain a letter and a digit or symbol"}
+	}
+
+	return nil
+}
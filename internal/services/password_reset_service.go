@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// ErrInvalidResetToken is returned for unknown, expired or already used reset tokens
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetService handles the forgot-password flow
+type PasswordResetService struct {
+	userRepo            interfaces.UserRepository
+	resetTokenRepo      interfaces.PasswordResetTokenRepository
+	refreshTokenService *RefreshTokenService
+	revocationService   *RevocationService
+	mailer              Mailer
+	ttl                 time.Duration
+	resetURL            string
+}
+
+// NewPasswordResetService creates a new PasswordResetService with repositories from the factory
+func NewPasswordResetService() *PasswordResetService {
+	factory := repositories.NewFactory()
+	return &PasswordResetService{
+		userRepo:            factory.GetUserRepository(),
+		resetTokenRepo:      factory.GetPasswordResetTokenRepository(),
+		refreshTokenService: NewRefreshTokenService(),
+		revocationService:   GetRevocationService(),
+		mailer:              NewMailer(),
+		ttl:                 config.Duration("PASSWORD_RESET_TTL", time.Hour),
+		resetURL:            config.String("PASSWORD_RESET_URL", "http://localhost:8080/reset-password"),
+	}
+}
+
+// RequestReset emails a reset link to the account with the given address.
+// Unknown addresses are silently ignored so callers cannot probe for accounts.
+func (s *PasswordResetService) RequestReset(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+	return s.SendResetEmail(user)
+}
+
+// SendResetEmail creates a reset token for the user and emails the link.
+// Previously issued tokens are invalidated so only the newest link works.
+func (s *PasswordResetService) SendResetEmail(user *models.User) error {
+	plaintext, err := generateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	if err := s.resetTokenRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+	err = s.resetTokenRepo.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(plaintext),
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.resetURL + "?token=" + url.QueryEscape(plaintext)
+	return s.mailer.Send(Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. "+
+			"Open the link below to choose a new one:\n\n%s\n\n"+
+			"The link expires in %s. If you did not ask for this, you can ignore this email.",
+			user.Username, link, s.ttl),
+	})
+}
+
+// ResetPassword consumes a reset token, sets the new password and signs the user out everywhere
+func (s *PasswordResetService) ResetPassword(plaintext, newPassword string) (*models.User, error) {
+	if err := ValidatePassword(newPassword); err != nil {
+		return nil, err
+	}
+
+	token, err := s.resetTokenRepo.FindByHash(hashToken(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.FindByID(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidResetToken
+	}
+
+	// Consume the token first so concurrent requests cannot both use it
+	used, err := s.resetTokenRepo.MarkUsed(token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidResetToken
+	}
+
+	if err := user.SetPassword(newPassword); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	// Whoever knew the old password may still hold a session
+	if err := s.refreshTokenService.RevokeAllForUser(user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.revocationService.RevokeAllForUser(user.ID); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, which tells them apart
+// from JWTs and makes leaked tokens easy to find with secret scanners
+const PersonalAccessTokenPrefix = "usp_"
+
+// TokenUsePersonalAccess marks the claims of requests authenticated with a personal access token
+const TokenUsePersonalAccess = "personal_access"
+
+// Scopes that can be granted to personal access tokens
+const (
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+)
+
+// PersonalAccessTokenScopes lists the scopes that can be granted to personal access tokens
+var PersonalAccessTokenScopes = []string{ScopeProfileRead, ScopeProfileWrite}
+
+// lastUsedResolution limits how often the last-used timestamp of a token is written
+const lastUsedResolution = time.Minute
+
+var (
+	// ErrInvalidPersonalAccessTokenScope is returned when creating a token with an unknown scope
+	ErrInvalidPersonalAccessTokenScope = errors.New("invalid personal access token scope")
+
+	// ErrPersonalAccessTokenNotFound is returned when deleting a token the user doesn't have
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+)
+
+// PersonalAccessTokenService handles creating, listing and validating personal access tokens
+type PersonalAccessTokenService struct {
+	tokenRepo interfaces.PersonalAccessTokenRepository
+	userRepo  interfaces.UserRepository
+}
+
+// NewPersonalAccessTokenService creates a new PersonalAccessTokenService instance with repositories from the factory
+func NewPersonalAccessTokenService() *PersonalAccessTokenService {
+	factory := repositories.NewFactory()
+	return &PersonalAccessTokenService{
+		tokenRepo: factory.GetPersonalAccessTokenRepository(),
+		userRepo:  factory.GetUserRepository(),
+	}
+}
+
+// IsPersonalAccessToken reports whether a bearer token looks like a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// Create creates a token for the user. The returned string is the only copy of the plaintext token.
+func (s *PersonalAccessTokenService) Create(userID uint, name string, scopes []string, expiresAt *time.Time) (string, *models.PersonalAccessToken, error) {
+	if len(scopes) == 0 {
+		return "", nil, ErrInvalidPersonalAccessTokenScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(PersonalAccessTokenScopes, scope) {
+			return "", nil, ErrInvalidPersonalAccessTokenScope
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, errors.New("expiry must be in the future")
+	}
+
+	secret, err := generateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	plaintext := PersonalAccessTokenPrefix + secret
+
+	slices.Sort(scopes)
+	token := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashToken(plaintext),
+		Prefix:    plaintext[:len(PersonalAccessTokenPrefix)+6],
+		Scopes:    strings.Join(slices.Compact(scopes), " "),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.tokenRepo.Create(token); err != nil {
+		return "", nil, err
+	}
+
+	return plaintext, token, nil
+}
+
+// List returns the tokens of a user
+func (s *PersonalAccessTokenService) List(userID uint) ([]models.PersonalAccessToken, error) {
+	return s.tokenRepo.FindByUserID(userID)
+}
+
+// Delete revokes a token of the user
+func (s *PersonalAccessTokenService) Delete(userID, id uint) error {
+	deleted, err := s.tokenRepo.Delete(id, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate validates a personal access token and returns claims equivalent to those
+// of an access token, limited to the token's scopes. It returns ErrInvalidToken for
+// unknown and expired tokens.
+func (s *PersonalAccessTokenService) Authenticate(plaintext string) (*Claims, error) {
+	token, err := s.tokenRepo.FindByHash(hashToken(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.IsExpired() {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.FindByID(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+	if err := UserStatusError(user.Status); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedResolution {
+		if err := s.tokenRepo.UpdateLastUsed(token.ID, now); err != nil {
+			log.Printf("Failed to record use of personal access token %d: %v", token.ID, err)
+		}
+	}
+
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  subjectForUser(user.ID),
+			IssuedAt: jwt.NewNumericDate(token.CreatedAt),
+		},
+		TokenUse:      TokenUsePersonalAccess,
+		UserID:        user.ID,
+		Email:         user.Email,
+		EmailVerified: user.IsEmailVerified(),
+		Username:      user.Username,
+		Scopes:        token.ScopeList(),
+	}
+	if token.ExpiresAt != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*token.ExpiresAt)
+	}
+	return claims, nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// Permissions checked by the routes of this service
+const (
+	PermissionUsersRead  = "users:read"
+	PermissionUsersWrite = "users:write"
+	PermissionRolesRead  = "roles:read"
+	PermissionRolesWrite = "roles:write"
+	PermissionAuditRead  = "audit:read"
+)
+
+// RoleAdmin is the built-in role holding every permission
+const RoleAdmin = "admin"
+
+// builtInPermissions are created at startup and granted to the admin role
+var builtInPermissions = []models.Permission{
+	{Name: PermissionUsersRead, Description: "View user accounts"},
+	{Name: PermissionUsersWrite, Description: "Manage user accounts"},
+	{Name: PermissionRolesRead, Description: "View roles and role assignments"},
+	{Name: PermissionRolesWrite, Description: "Assign and remove roles"},
+	{Name: PermissionAuditRead, Description: "View and export the audit log"},
+}
+
+var (
+	// ErrRoleNotFound is returned for unknown role names
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrRoleNotAssigned is returned when removing a role the user doesn't have
+	ErrRoleNotAssigned = errors.New("role is not assigned to the user")
+
+	// ErrLastAdmin is returned when removing the admin role from its last holder
+	ErrLastAdmin = errors.New("the last admin can't be removed")
+
+	// ErrAdminNotEligible is returned when bootstrapping an admin whose account can't hold the role
+	ErrAdminNotEligible = errors.New("the first admin must have a verified email and an active account")
+)
+
+// RBACService manages roles, permissions and their assignment to users
+type RBACService struct {
+	roleRepo          interfaces.RoleRepository
+	userRepo          interfaces.UserRepository
+	revocationService *RevocationService
+}
+
+// NewRBACService creates a new RBACService instance with repositories from the factory
+func NewRBACService() *RBACService {
+	factory := repositories.NewFactory()
+	return &RBACService{
+		roleRepo:          factory.GetRoleRepository(),
+		userRepo:          factory.GetUserRepository(),
+		revocationService: GetRevocationService(),
+	}
+}
+
+// SeedBuiltInRoles creates the built-in permissions and admin role.
+// It is safe to run on every startup and never assigns the role to anyone.
+func (s *RBACService) SeedBuiltInRoles() error {
+	admin := &models.Role{
+		Name:        RoleAdmin,
+		Description: "Full access to user and role administration",
+		BuiltIn:     true,
+	}
+	return s.roleRepo.UpsertRole(admin, slices.Clone(builtInPermissions))
+}
+
+// BootstrapAdmin assigns the admin role to a user picked by an operator, for installations
+// where nobody can assign roles yet. It is run by cmd/grant-admin, never on startup.
+// The account must be active and own its email address.
+func (s *RBACService) BootstrapAdmin(userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if !user.IsEmailVerified() || UserStatusError(user.Status) != nil {
+		return ErrAdminNotEligible
+	}
+
+	role, err := s.roleRepo.FindByName(RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return ErrRoleNotFound
+	}
+
+	if err := s.roleRepo.Assign(&models.UserRole{UserID: userID, RoleID: role.ID}); err != nil {
+		return err
+	}
+	return s.expireTokens(userID)
+}
+
+// ListRoles returns every role with its permissions
+func (s *RBACService) ListRoles() ([]models.Role, error) {
+	return s.roleRepo.FindAll()
+}
+
+// UserRoles returns the roles assigned to a user
+func (s *RBACService) UserRoles(userID uint) ([]models.Role, error) {
+	return s.roleRepo.FindByUserID(userID)
+}
+
+// Authorization returns the names of the user's roles and the union of their permissions,
+// as embedded in access tokens
+func (s *RBACService) Authorization(userID uint) ([]string, []string, error) {
+	roles, err := s.roleRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var roleNames, permissions []string
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+		permissions = append(permissions, role.PermissionNames()...)
+	}
+	slices.Sort(permissions)
+	return roleNames, slices.Compact(permissions), nil
+}
+
+// AssignRole assigns a role to a user on behalf of actorID
+func (s *RBACService) AssignRole(actorID, userID uint, roleName string) error {
+	role, err := s.findRoleAndUser(userID, roleName)
+	if err != nil {
+		return err
+	}
+	if err := s.roleRepo.Assign(&models.UserRole{UserID: userID, RoleID: role.ID, AssignedBy: &actorID}); err != nil {
+		return err
+	}
+	return s.expireTokens(userID)
+}
+
+// UnassignRole removes a role from a user. The admin role can't be removed from its last holder.
+func (s *RBACService) UnassignRole(userID uint, roleName string) error {
+	role, err := s.findRoleAndUser(userID, roleName)
+	if err != nil {
+		return err
+	}
+
+	if role.Name == RoleAdmin {
+		outcome, err := s.roleRepo.UnassignUnlessLast(userID, role.ID)
+		if err != nil {
+			return err
+		}
+		switch outcome {
+		case interfaces.RoleNotAssigned:
+			return ErrRoleNotAssigned
+		case interfaces.RoleLastHolder:
+			return ErrLastAdmin
+		}
+		return s.expireTokens(userID)
+	}
+
+	removed, err := s.roleRepo.Unassign(userID, role.ID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrRoleNotAssigned
+	}
+	return s.expireTokens(userID)
+}
+
+// findRoleAndUser loads a role by name after checking the user exists
+func (s *RBACService) findRoleAndUser(userID uint, roleName string) (*models.Role, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	role, err := s.roleRepo.FindByName(roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// expireTokens revokes the user's access tokens so that the next refresh picks up
+// the new roles instead of the ones embedded in the current token
+func (s *RBACService) expireTokens(userID uint) error {
+	return s.revocationService.RevokeAllForUser(userID)
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again.
+	// The whole token family is revoked when this happens.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// RefreshTokenService handles issuing, rotating and revoking refresh tokens
+type RefreshTokenService struct {
+	refreshTokenRepo interfaces.RefreshTokenRepository
+	ttl              time.Duration
+}
+
+// NewRefreshTokenService creates a new RefreshTokenService instance with repositories from the factory
+func NewRefreshTokenService() *RefreshTokenService {
+	factory := repositories.NewFactory()
+	return NewRefreshTokenServiceWithRepo(factory.GetRefreshTokenRepository())
+}
+
+// NewRefreshTokenServiceWithRepo creates a new RefreshTokenService with a specific repository
+func NewRefreshTokenServiceWithRepo(refreshTokenRepo interfaces.RefreshTokenRepository) *RefreshTokenService {
+	return &RefreshTokenService{
+		refreshTokenRepo: refreshTokenRepo,
+		ttl:              config.Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	}
+}
+
+// Issue creates a refresh token for the user in a brand-new token family.
+// authTime is when the user actively authenticated and is carried through rotations.
+// The returned string is the only copy of the plaintext token.
+func (s *RefreshTokenService) Issue(userID uint, authTime time.Time) (string, *models.RefreshToken, error) {
+	return s.IssueForClient(userID, "", "", authTime)
+}
+
+// IssueForClient creates a refresh token in a new family for an OAuth client acting on
+// behalf of the user with the granted scope. An empty clientID means a first-party session.
+func (s *RefreshTokenService) IssueForClient(userID uint, clientID, scope string, authTime time.Time) (string, *models.RefreshToken, error) {
+	familyID, err := generateRandomHex(16)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.create(&models.RefreshToken{
+		UserID:   userID,
+		FamilyID: familyID,
+		ClientID: clientID,
+		Scope:    scope,
+		AuthTime: authTime,
+	})
+}
+
+// Rotate exchanges a refresh token issued to clientID (empty for first-party sessions)
+// for a new one in the same family.
+// Presenting a token that was already rotated revokes the entire family.
+func (s *RefreshTokenService) Rotate(plaintext, clientID string) (string, *models.RefreshToken, error) {
+	current, err := s.refreshTokenRepo.FindByHash(hashToken(plaintext))
+	if err != nil {
+		return "", nil, err
+	}
+	if current == nil || current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+		return "", nil, ErrInvalidRefreshToken
+	}
+	// A token is only redeemable by the client it was issued to
+	if current.ClientID != clientID {
+		return "", nil, ErrInvalidRefreshToken
+	}
+	if current.RotatedAt != nil {
+		return "", nil, s.revokeReusedFamily(current.FamilyID)
+	}
+
+	// Guard against two requests racing to rotate the same token
+	rotated, err := s.refreshTokenRepo.MarkRotated(current.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !rotated {
+		return "", nil, s.revokeReusedFamily(current.FamilyID)
+	}
+
+	authTime := current.AuthTime
+	if authTime.IsZero() {
+		// Tokens created before auth_time was tracked
+		authTime = current.CreatedAt
+	}
+	return s.create(&models.RefreshToken{
+		UserID:   current.UserID,
+		FamilyID: current.FamilyID,
+		ClientID: current.ClientID,
+		Scope:    current.Scope,
+		AuthTime: authTime,
+	})
+}
+
+// RevokeToken revokes the family of a plaintext refresh token owned by the user.
+// Unknown tokens and tokens of other users are rejected.
+func (s *RefreshTokenService) RevokeToken(plaintext string, userID uint) error {
+	token, err := s.refreshTokenRepo.FindByHash(hashToken(plaintext))
+	if err != nil {
+		return err
+	}
+	if token == nil || token.UserID != userID {
+		return ErrInvalidRefreshToken
+	}
+	return s.refreshTokenRepo.RevokeFamily(token.FamilyID)
+}
+
+// Inspect returns the stored record of a refresh token that can still be redeemed,
+// or nil for unknown, expired, rotated and revoked tokens
+func (s *RefreshTokenService) Inspect(plaintext string) (*models.RefreshToken, error) {
+	token, err := s.refreshTokenRepo.FindByHash(hashToken(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.RevokedAt != nil || token.RotatedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// RevokeForClient revokes the family of a refresh token issued to the OAuth client.
+// It reports false, without revoking anything, for unknown tokens and tokens of other clients.
+func (s *RefreshTokenService) RevokeForClient(plaintext, clientID string) (bool, error) {
+	token, err := s.refreshTokenRepo.FindByHash(hashToken(plaintext))
+	if err != nil {
+		return false, err
+	}
+	if token == nil || clientID == "" || token.ClientID != clientID {
+		return false, nil
+	}
+	return true, s.refreshTokenRepo.RevokeFamily(token.FamilyID)
+}
+
+// RevokeFamily revokes every refresh token in the family of the given token
+func (s *RefreshTokenService) RevokeFamily(familyID string) error {
+	return s.refreshTokenRepo.RevokeFamily(familyID)
+}
+
+// RevokeAllForUser revokes every refresh token belonging to the user
+func (s *RefreshTokenService) RevokeAllForUser(userID uint) error {
+	return s.refreshTokenRepo.RevokeAllForUser(userID)
+}
+
+// create generates the token, fills in its hash and expiry and stores it
+func (s *RefreshTokenService) create(token *models.RefreshToken) (string, *models.RefreshToken, error) {
+	plaintext, err := generateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token.TokenHash = hashToken(plaintext)
+	token.ExpiresAt = time.Now().Add(s.ttl)
+	if err := s.refreshTokenRepo.Create(token); err != nil {
+		return "", nil, err
+	}
+
+	return plaintext, token, nil
+}
+
+// revokeReusedFamily revokes a family after a replayed token was detected
+func (s *RefreshTokenService) revokeReusedFamily(familyID string) error {
+	if err := s.refreshTokenRepo.RevokeFamily(familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// generateRandomToken returns n random bytes encoded as unpadded base64url
+func generateRandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// generateRandomHex returns n random bytes encoded as hex
+func generateRandomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashToken returns the hex encoded SHA-256 hash of an opaque token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
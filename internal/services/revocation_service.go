@@ -0,0 +1,263 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+var (
+	revocationServiceInstance *RevocationService
+	revocationServiceOnce     sync.Once
+)
+
+// cachedUserRevocation is a user-wide revocation cached in memory.
+// A zero revokedBefore means the user has no revocation.
+type cachedUserRevocation struct {
+	revokedBefore time.Time
+	checkedAt     time.Time
+}
+
+// cachedSessionState caches whether a session has ended
+type cachedSessionState struct {
+	revoked   bool
+	checkedAt time.Time
+}
+
+// cachedTokenState caches whether a token ID is revoked
+type cachedTokenState struct {
+	revoked   bool
+	expiresAt time.Time // For revoked tokens: when the token itself expires
+	checkedAt time.Time
+}
+
+// RevocationService tracks revoked access tokens, either individually, per user or
+// through the session they belong to. Postgres is the source of truth; an in-memory cache keeps JWTAuth from hitting the
+// database on every request. Revocations made by other replicas become visible once
+// the cached entry is older than REVOCATION_CACHE_TTL.
+type RevocationService struct {
+	revocationRepo interfaces.RevocationRepository
+	sessionRepo    interfaces.SessionRepository
+	cacheTTL       time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[string]cachedTokenState
+	users    map[uint]cachedUserRevocation
+	sessions map[uint]cachedSessionState
+}
+
+// GetRevocationService returns the process-wide RevocationService so every caller shares one cache
+func GetRevocationService() *RevocationService {
+	revocationServiceOnce.Do(func() {
+		factory := repositories.NewFactory()
+		revocationServiceInstance = NewRevocationServiceWithRepos(factory.GetRevocationRepository(), factory.GetSessionRepository())
+		go revocationServiceInstance.runCleanup(time.Hour)
+	})
+	return revocationServiceInstance
+}
+
+// NewRevocationServiceWithRepos creates a new RevocationService with specific repositories
+func NewRevocationServiceWithRepos(revocationRepo interfaces.RevocationRepository, sessionRepo interfaces.SessionRepository) *RevocationService {
+	return &RevocationService{
+		revocationRepo: revocationRepo,
+		sessionRepo:    sessionRepo,
+		cacheTTL:       config.Duration("REVOCATION_CACHE_TTL", 30*time.Second),
+		tokens:         make(map[string]cachedTokenState),
+		users:          make(map[uint]cachedUserRevocation),
+		sessions:       make(map[uint]cachedSessionState),
+	}
+}
+
+// RevokeToken revokes a single access token until it expires
+func (s *RevocationService) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	err := s.revocationRepo.RevokeToken(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = cachedTokenState{revoked: true, expiresAt: expiresAt, checkedAt: time.Now()}
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeAllForUser revokes every access token issued to the user up to now
+func (s *RevocationService) RevokeAllForUser(userID uint) error {
+	// Tokens carry second precision, so the cutoff is rounded up to the next second to also
+	// cover tokens issued earlier in the current one
+	now := time.Now()
+	cutoff := now.Truncate(time.Second).Add(time.Second)
+	if err := s.revocationRepo.RevokeUserTokensBefore(userID, cutoff); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.users[userID] = cachedUserRevocation{revokedBefore: cutoff, checkedAt: now}
+	s.mu.Unlock()
+	return nil
+}
+
+// IssueTime returns the issue time for a new token of the user: now, or the user's revocation
+// cutoff while it is still ahead of now, so that tokens issued in the second of a user-wide
+// revocation aren't revoked by it
+func (s *RevocationService) IssueTime(userID uint, now time.Time) (time.Time, error) {
+	revokedBefore, err := s.userRevokedBefore(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if now.Before(revokedBefore) {
+		return revokedBefore, nil
+	}
+	return now, nil
+}
+
+// SessionRevoked records in the cache that a session has ended, so its access tokens are
+// rejected right away. The session itself is ended by revoking its refresh token family.
+func (s *RevocationService) SessionRevoked(sessionID uint) {
+	s.mu.Lock()
+	s.sessions[sessionID] = cachedSessionState{revoked: true, checkedAt: time.Now()}
+	s.mu.Unlock()
+}
+
+// IsRevoked reports whether the token with the given ID, owner, session and issue time has been
+// revoked, either individually, through a user-wide revocation or because its session ended.
+// A zero sessionID means the token doesn't belong to a session.
+func (s *RevocationService) IsRevoked(jti string, userID, sessionID uint, issuedAt time.Time) (bool, error) {
+	revokedBefore, err := s.userRevokedBefore(userID)
+	if err != nil {
+		return false, err
+	}
+	if !revokedBefore.IsZero() && issuedAt.Before(revokedBefore) {
+		return true, nil
+	}
+
+	if sessionID != 0 {
+		revoked, err := s.isSessionRevoked(sessionID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	if jti == "" {
+		return false, nil
+	}
+	return s.IsTokenRevoked(jti)
+}
+
+// IsTokenRevoked reports whether a single token ID has been revoked,
+// checking the cache before falling back to the database
+func (s *RevocationService) IsTokenRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	state, ok := s.tokens[jti]
+	s.mu.RUnlock()
+	// Revocations are permanent, so only negative entries need refreshing
+	if ok && (state.revoked || time.Since(state.checkedAt) < s.cacheTTL) {
+		return state.revoked, nil
+	}
+
+	revoked, err := s.revocationRepo.IsTokenRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	s.tokens[jti] = cachedTokenState{revoked: revoked, checkedAt: time.Now()}
+	s.mu.Unlock()
+	return revoked, nil
+}
+
+// isSessionRevoked reports whether a session has ended, checking the cache before falling back
+// to the database. Sessions that no longer exist count as revoked.
+func (s *RevocationService) isSessionRevoked(sessionID uint) (bool, error) {
+	s.mu.RLock()
+	state, ok := s.sessions[sessionID]
+	s.mu.RUnlock()
+	if ok && (state.revoked || time.Since(state.checkedAt) < s.cacheTTL) {
+		return state.revoked, nil
+	}
+
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil {
+		return false, err
+	}
+	revoked := session == nil || session.RevokedAt != nil
+
+	s.mu.Lock()
+	s.sessions[sessionID] = cachedSessionState{revoked: revoked, checkedAt: time.Now()}
+	s.mu.Unlock()
+	return revoked, nil
+}
+
+// userRevokedBefore returns the user-wide revocation time, or the zero time if there is none
+func (s *RevocationService) userRevokedBefore(userID uint) (time.Time, error) {
+	s.mu.RLock()
+	cached, ok := s.users[userID]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.checkedAt) < s.cacheTTL {
+		return cached.revokedBefore, nil
+	}
+
+	revocation, err := s.revocationRepo.FindUserRevocation(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var revokedBefore time.Time
+	if revocation != nil {
+		revokedBefore = revocation.RevokedBefore
+	}
+
+	s.mu.Lock()
+	s.users[userID] = cachedUserRevocation{revokedBefore: revokedBefore, checkedAt: time.Now()}
+	s.mu.Unlock()
+	return revokedBefore, nil
+}
+
+// runCleanup periodically drops expired revocations from the database and stale cache entries
+func (s *RevocationService) runCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.revocationRepo.DeleteExpired(); err != nil {
+			log.Printf("Failed to delete expired token revocations: %v", err)
+		}
+
+		now := time.Now()
+		s.mu.Lock()
+		for jti, state := range s.tokens {
+			// Entries without a known expiry (loaded from the database) are re-checked later
+			expired := !state.expiresAt.IsZero() && now.After(state.expiresAt)
+			stale := state.expiresAt.IsZero() && now.Sub(state.checkedAt) >= s.cacheTTL
+			if expired || stale {
+				delete(s.tokens, jti)
+			}
+		}
+		for userID, cached := range s.users {
+			if now.Sub(cached.checkedAt) >= s.cacheTTL {
+				delete(s.users, userID)
+			}
+		}
+		for sessionID, state := range s.sessions {
+			// Ended sessions are loaded again from the database if a token still shows up
+			if now.Sub(state.checkedAt) >= s.cacheTTL {
+				delete(s.sessions, sessionID)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// NewTokenID returns a random identifier suitable for a JWT jti claim
+func NewTokenID() (string, error) {
+	return generateRandomHex(16)
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRevokeAllForUserCoversTokensFromTheSameSecond(t *testing.T) {
+	authService, revocationService := newTestAuthService(t)
+	sign := func(claims *Claims) string {
+		t.Helper()
+		token, err := authService.SignToken(claims, time.Minute)
+		if err != nil {
+			t.Fatalf("SignToken error: %v", err)
+		}
+		return token
+	}
+
+	before := sign(&Claims{TokenUse: TokenUseAccess, UserID: 42})
+	if err := revocationService.RevokeAllForUser(42); err != nil {
+		t.Fatalf("RevokeAllForUser error: %v", err)
+	}
+	claims := &Claims{TokenUse: TokenUseAccess, UserID: 42}
+	after := sign(claims)
+
+	if _, err := authService.ValidateAccessToken(before); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("token issued before the revocation: error = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := authService.ValidateAccessToken(after); err != nil {
+		t.Errorf("token issued after the revocation: error = %v", err)
+	}
+	if claims.IssuedAt.After(time.Now().Add(time.Second)) {
+		t.Errorf("iat = %v, more than a second ahead", claims.IssuedAt.Time)
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+)
+
+// ErrEncryptionNotConfigured is returned when MFA_ENCRYPTION_KEY is missing or invalid
+var ErrEncryptionNotConfigured = errors.New("secret encryption is not configured")
+
+// ErrSigningKeyEncryptionNotConfigured is returned when SIGNING_KEY_ENCRYPTION_KEY is missing or invalid
+var ErrSigningKeyEncryptionNotConfigured = errors.New("signing key encryption is not configured")
+
+// encryptSecret seals a secret with AES-256-GCM under MFA_ENCRYPTION_KEY
+// (a base64 encoded 32-byte key) and returns nonce||ciphertext as base64
+func encryptSecret(plaintext []byte) (string, error) {
+	aead, err := secretCipher("MFA_ENCRYPTION_KEY", ErrEncryptionNotConfigured)
+	if err != nil {
+		return "", err
+	}
+	return sealSecret(aead, plaintext, nil)
+}
+
+// decryptSecret opens a value produced by encryptSecret
+func decryptSecret(encoded string) ([]byte, error) {
+	aead, err := secretCipher("MFA_ENCRYPTION_KEY", ErrEncryptionNotConfigured)
+	if err != nil {
+		return nil, err
+	}
+	return openSecret(aead, encoded, nil)
+}
+
+// encryptSigningKey seals a token signing private key under SIGNING_KEY_ENCRYPTION_KEY
+// (a base64 encoded 32-byte key). The kid is authenticated as additional data, so a sealed
+// key only opens for the row it was written to.
+func encryptSigningKey(kid string, plaintext []byte) (string, error) {
+	aead, err := secretCipher("SIGNING_KEY_ENCRYPTION_KEY", ErrSigningKeyEncryptionNotConfigured)
+	if err != nil {
+		return "", err
+	}
+	return sealSecret(aead, plaintext, []byte(kid))
+}
+
+// decryptSigningKey opens a value produced by encryptSigningKey for the same kid
+func decryptSigningKey(kid, encoded string) ([]byte, error) {
+	aead, err := secretCipher("SIGNING_KEY_ENCRYPTION_KEY", ErrSigningKeyEncryptionNotConfigured)
+	if err != nil {
+		return nil, err
+	}
+	return openSecret(aead, encoded, []byte(kid))
+}
+
+// CheckSigningKeyEncryption reports whether SIGNING_KEY_ENCRYPTION_KEY is set to a valid key.
+// Tokens can't be signed without it, so it is checked at startup.
+func CheckSigningKeyEncryption() error {
+	_, err := secretCipher("SIGNING_KEY_ENCRYPTION_KEY", ErrSigningKeyEncryptionNotConfigured)
+	return err
+}
+
+// sealSecret encrypts plaintext with a random nonce and returns nonce||ciphertext as base64
+func sealSecret(aead cipher.AEAD, plaintext, additionalData []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret decrypts a value produced by sealSecret
+func openSecret(aead cipher.AEAD, encoded string, additionalData []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted secret is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// secretCipher builds the AEAD from the base64 encoded 32-byte key in the environment variable,
+// returning notConfigured when it is missing or invalid
+func secretCipher(keyVar string, notConfigured error) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv(keyVar))
+	if err != nil || len(key) != 32 {
+		return nil, notConfigured
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestSigningKeyEncryptionIsBoundToKID(t *testing.T) {
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+
+	sealed, err := encryptSigningKey("kid-a", []byte("private key"))
+	if err != nil {
+		t.Fatalf("encryptSigningKey error: %v", err)
+	}
+	if opened, err := decryptSigningKey("kid-a", sealed); err != nil || string(opened) != "private key" {
+		t.Fatalf("decryptSigningKey = %q, %v", opened, err)
+	}
+	if _, err := decryptSigningKey("kid-b", sealed); err == nil {
+		t.Error("key sealed for kid-a opened for kid-b")
+	}
+}
+
+func TestSigningKeyEncryptionDoesNotUseMFAKey(t *testing.T) {
+	t.Setenv("MFA_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "")
+
+	if err := CheckSigningKeyEncryption(); !errors.Is(err, ErrSigningKeyEncryptionNotConfigured) {
+		t.Errorf("CheckSigningKeyEncryption = %v, want ErrSigningKeyEncryptionNotConfigured", err)
+	}
+	if _, err := encryptSigningKey("kid", []byte("private key")); !errors.Is(err, ErrSigningKeyEncryptionNotConfigured) {
+		t.Errorf("encryptSigningKey = %v, want ErrSigningKeyEncryptionNotConfigured", err)
+	}
+}
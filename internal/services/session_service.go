@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// ErrSessionNotFound is returned for unknown sessions and sessions of other users
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionInfo describes where and how a user signed in
+type SessionInfo struct {
+	DeviceName string // Chosen by the client; derived from the user agent when empty
+	UserAgent  string
+	IP         string
+	AuthMethod string
+}
+
+// SessionService keeps track of the devices a user is signed in on.
+// A session follows a first-party refresh token family; revoking one revokes the other.
+type SessionService struct {
+	sessionRepo         interfaces.SessionRepository
+	refreshTokenService *RefreshTokenService
+	revocationService   *RevocationService
+	historyLimit        int
+}
+
+// NewSessionService creates a new SessionService instance with repositories from the factory
+func NewSessionService() *SessionService {
+	factory := repositories.NewFactory()
+	return &SessionService{
+		sessionRepo:         factory.GetSessionRepository(),
+		refreshTokenService: NewRefreshTokenService(),
+		revocationService:   GetRevocationService(),
+		historyLimit:        config.Int("SESSION_HISTORY_LIMIT", 100),
+	}
+}
+
+// Start records the session of a refresh token family created for a user who just signed in
+func (s *SessionService) Start(token *models.RefreshToken, info SessionInfo) (*models.Session, error) {
+	deviceName := strings.TrimSpace(info.DeviceName)
+	if deviceName == "" {
+		deviceName = describeUserAgent(info.UserAgent)
+	}
+
+	session := &models.Session{
+		UserID:     token.UserID,
+		FamilyID:   token.FamilyID,
+		DeviceName: truncate(deviceName, 100),
+		UserAgent:  truncate(info.UserAgent, 512),
+		IP:         info.IP,
+		AuthMethod: info.AuthMethod,
+		LastSeenAt: token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Refreshed records that the refresh token of a session was rotated into token.
+// It returns nil for families that started before sessions were tracked.
+func (s *SessionService) Refreshed(token *models.RefreshToken) (*models.Session, error) {
+	session, err := s.sessionRepo.FindByFamilyID(token.FamilyID)
+	if err != nil || session == nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err := s.sessionRepo.Touch(session.ID, token.CreatedAt, token.ExpiresAt); err != nil {
+		return nil, err
+	}
+	session.LastSeenAt = token.CreatedAt
+	session.ExpiresAt = token.ExpiresAt
+	return session, nil
+}
+
+// List returns the sessions of a user, most recently seen first.
+// Ended sessions are included, up to SESSION_HISTORY_LIMIT, if includeEnded is set.
+func (s *SessionService) List(userID uint, includeEnded bool) ([]models.Session, error) {
+	if includeEnded {
+		return s.sessionRepo.FindByUserID(userID, false, s.historyLimit)
+	}
+	return s.sessionRepo.FindByUserID(userID, true, 0)
+}
+
+// Revoke signs the user out of one of their sessions by revoking its refresh token family.
+// Access tokens already issued in the session are rejected from then on.
+func (s *SessionService) Revoke(userID, sessionID uint) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	if session.RevokedAt == nil {
+		if err := s.refreshTokenService.RevokeFamily(session.FamilyID); err != nil {
+			return err
+		}
+	}
+	s.revocationService.SessionRevoked(session.ID)
+	return nil
+}
+
+// userAgentBrowsers and userAgentPlatforms map user agent tokens to readable names.
+// They are checked in order, as most browsers also claim to be the ones listed after them.
+var (
+	userAgentBrowsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+	userAgentPlatforms = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Macintosh", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// describeUserAgent turns a user agent into a device name such as "Chrome on macOS".
+// Clients that aren't browsers are named after their product, e.g. "curl".
+func describeUserAgent(userAgent string) string {
+	var browser, platform string
+	for _, candidate := range userAgentBrowsers {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+	for _, candidate := range userAgentPlatforms {
+		if strings.Contains(userAgent, candidate.token) {
+			platform = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+
+	product, _, _ := strings.Cut(userAgent, "/")
+	if product = strings.TrimSpace(product); product != "" {
+		return product
+	}
+	return "Unknown device"
+}
+
+// truncate shortens a string to at most n bytes without splitting a UTF-8 sequence
+func truncate(value string, n int) string {
+	if len(value) <= n {
+		return value
+	}
+	value = value[:n]
+	for len(value) > 0 && !utf8.ValidString(value) {
+		value = value[:len(value)-1]
+	}
+	return value
+}
@@ -3,12 +3,29 @@ package services
 import (
 	"errors"
 	"maps"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/danigrb.dev/user-service/internal/database/interfaces"
 	"github.com/danigrb.dev/user-service/internal/database/repositories"
 	"github.com/danigrb.dev/user-service/internal/models"
 )
 
+var (
+	// ErrIncorrectPassword is returned when the current password does not match
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+
+	// ErrUserNotFound is returned when a user ID or email doesn't match any account
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrReauthenticationRequired is returned when an operation needs a recent login
+	ErrReauthenticationRequired = errors.New("please sign in again to continue")
+
+	// ErrInvalidCredentials is returned when an email and password don't match an account
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
 // UserService handles business logic related to users
 type UserService struct {
 	userRepo interfaces.UserRepository
@@ -32,6 +49,10 @@ func NewUserServiceWithRepo(userRepo interfaces.UserRepository) *UserService {
 
 // CreateUser creates a new user with the given email and password
 func (s *UserService) CreateUser(email, username, password string) (*models.User, error) {
+	if err := ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
 	// Check if email already exists
 	exists, err := s.userRepo.EmailExists(email)
 	if err != nil {
@@ -77,7 +98,7 @@ func (s *UserService) GetUserByID(id uint) (*models.User, error) {
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	return user, nil
 }
@@ -89,7 +110,7 @@ func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	return user, nil
 }
@@ -102,7 +123,7 @@ func (s *UserService) UpdateUserProfile(id uint, updates map[string]any) (*model
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 
 	// Check if email is being updated and is unique
@@ -115,6 +136,8 @@ func (s *UserService) UpdateUserProfile(id uint, updates map[string]any) (*model
 			return nil, errors.New("email already in use")
 		}
 		user.Email = email
+		// The new address has to be verified again
+		user.EmailVerifiedAt = nil
 	}
 
 	// Check if username is being updated and is unique
@@ -151,19 +174,10 @@ func (s *UserService) UpdateUserProfile(id uint, updates map[string]any) (*model
 	return user, nil
 }
 
-// DeleteUser deletes a user by their ID
-func (s *UserService) DeleteUser(id uint) error {
-	// Check if user exists
-	user, err := s.userRepo.FindByID(id)
-	if err != nil {
-		return err
-	}
-	if user == nil {
-		return errors.New("user not found")
-	}
-
-	// Delete the user
-	return s.userRepo.Delete(id)
+// DeleteUser deletes the account of a user. It is only purged after the grace period, until
+// which the user can restore it by signing in again.
+func (s *UserService) DeleteUser(id uint) (*models.User, error) {
+	return GetUserStatusService().ScheduleDeletion(id, "deleted by the user", nil)
 }
 
 // VerifyUserCredentials verifies email and password
@@ -173,41 +187,123 @@ func (s *UserService) VerifyUserCredentials(email, password string) (*models.Use
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
 	// Verify password
 	if !user.VerifyPassword(password) {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
+	}
+
+	// Only reveal the account status to someone who knows the password
+	if err := AccountStatusError(user); err != nil {
+		return nil, err
 	}
 
 	return user, nil
 }
 
-// CreateAppleUser creates a user with Apple credentials
-func (s *UserService) CreateAppleUser(appleID, email, username string) (*models.User, error) {
-	// Check if user already exists with this Apple ID
-	existingUser, err := s.userRepo.FindByAppleID(appleID)
+// ChangePassword sets a new password for the user.
+// Users with a password must provide it; users without one (e.g. Apple-only accounts)
+// may set an initial password only if they authenticated recently.
+func (s *UserService) ChangePassword(id uint, currentPassword, newPassword string, recentlyAuthenticated bool) (*models.User, error) {
+	user, err := s.userRepo.FindByID(id)
 	if err != nil {
 		return nil, err
 	}
-	if existingUser != nil {
-		return existingUser, nil // User already exists, return it
+	if user == nil {
+		return nil, ErrUserNotFound
 	}
 
-	// Create new user with Apple ID
-	user := &models.User{
-		Email:       email,
-		Username:    username,
-		AppleID:     &appleID,
-		AppleEmail:  &email,
-		Preferences: models.Preferences{},
+	if user.PasswordHash != nil {
+		if !user.VerifyPassword(currentPassword) {
+			return nil, ErrIncorrectPassword
+		}
+	} else if !recentlyAuthenticated {
+		return nil, ErrReauthenticationRequired
 	}
 
-	// Save user to database
+	if err := ValidatePassword(newPassword); err != nil {
+		return nil, err
+	}
+	if err := user.SetPassword(newPassword); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreatePasswordlessUser creates an account without a password for an address that
+// has already been proven to belong to the caller (e.g. through a magic link).
+// The username is derived from the local part of the email.
+func (s *UserService) CreatePasswordlessUser(email string) (*models.User, error) {
+	exists, err := s.userRepo.EmailExists(email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("email already in use")
+	}
+
+	username, err := s.availableUsername(email)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Email:           email,
+		Username:        username,
+		Preferences:     models.Preferences{},
+		EmailVerifiedAt: &now,
+	}
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
+
+// availableUsername turns a suggested name or the local part of an email into a free
+// username, appending a random suffix when the plain name is taken or too short
+func (s *UserService) availableUsername(suggestion string) (string, error) {
+	local, _, _ := strings.Cut(suggestion, "@")
+	base := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-') {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, local)
+	if len(base) > 21 {
+		base = base[:21]
+	}
+
+	if len(base) >= 3 {
+		exists, err := s.userRepo.UsernameExists(base)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return base, nil
+		}
+	}
+
+	for range 5 {
+		suffix, err := generateRandomHex(4)
+		if err != nil {
+			return "", err
+		}
+		candidate := base + "_" + suffix
+		exists, err := s.userRepo.UsernameExists(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("could not find an available username")
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// TokenUseAccountRestore marks the short-lived tokens that let the owner of an account
+// pending deletion restore it after signing in
+const TokenUseAccountRestore = "account_restore"
+
+var (
+	userStatusServiceInstance *UserStatusService
+	userStatusServiceOnce     sync.Once
+)
+
+var (
+	// ErrAccountSuspended is returned when a suspended user tries to sign in or use a token
+	ErrAccountSuspended = errors.New("account has been suspended")
+
+	// ErrAccountBanned is returned when a banned user tries to sign in or use a token
+	ErrAccountBanned = errors.New("account has been banned")
+
+	// ErrAccountDeleted is returned when a deleted user tries to sign in or use a token
+	ErrAccountDeleted = errors.New("account has been deleted")
+
+	// ErrInvalidStatusTransition is returned for status changes the lifecycle doesn't allow
+	ErrInvalidStatusTransition = errors.New("invalid account status transition")
+
+	// ErrInvalidRestoreToken is returned for unknown, expired or already used restore tokens
+	ErrInvalidRestoreToken = errors.New("invalid or expired restore token")
+)
+
+// PendingDeletionError is the status error of a deleted account that can still be restored.
+// It matches ErrAccountDeleted with errors.Is.
+type PendingDeletionError struct {
+	UserID  uint
+	PurgeAt time.Time
+}
+
+func (e *PendingDeletionError) Error() string {
+	return ErrAccountDeleted.Error()
+}
+
+func (e *PendingDeletionError) Unwrap() error {
+	return ErrAccountDeleted
+}
+
+// userStatusTransitions lists the statuses each status may change to
+var userStatusTransitions = map[models.UserStatus][]models.UserStatus{
+	models.UserStatusPending:   {models.UserStatusActive, models.UserStatusSuspended, models.UserStatusBanned, models.UserStatusDeleted},
+	models.UserStatusActive:    {models.UserStatusSuspended, models.UserStatusBanned, models.UserStatusDeleted},
+	models.UserStatusSuspended: {models.UserStatusActive, models.UserStatusBanned, models.UserStatusDeleted},
+	models.UserStatusBanned:    {models.UserStatusActive, models.UserStatusDeleted},
+	models.UserStatusDeleted:   {models.UserStatusActive, models.UserStatusPending},
+}
+
+// UserStatusError returns the error describing why accounts in the status can't sign in,
+// or nil if they can
+func UserStatusError(status models.UserStatus) error {
+	switch status {
+	case models.UserStatusSuspended:
+		return ErrAccountSuspended
+	case models.UserStatusBanned:
+		return ErrAccountBanned
+	case models.UserStatusDeleted:
+		return ErrAccountDeleted
+	}
+	return nil
+}
+
+// AccountStatusError is like UserStatusError, but tells the owner of a deleted account
+// until when it can be restored
+func AccountStatusError(user *models.User) error {
+	if user.Status == models.UserStatusDeleted && user.PurgeAt != nil {
+		return &PendingDeletionError{UserID: user.ID, PurgeAt: *user.PurgeAt}
+	}
+	return UserStatusError(user.Status)
+}
+
+// AccountStatusCode returns the machine-readable code clients use to explain a status
+// error ("account_suspended", ...), or "" if err isn't one
+func AccountStatusCode(err error) string {
+	switch {
+	case errors.Is(err, ErrAccountSuspended):
+		return "account_suspended"
+	case errors.Is(err, ErrAccountBanned):
+		return "account_banned"
+	case errors.Is(err, ErrAccountDeleted):
+		return "account_deleted"
+	}
+	return ""
+}
+
+// cachedUserStatus caches the status of a user
+type cachedUserStatus struct {
+	status    models.UserStatus
+	checkedAt time.Time
+}
+
+// UserStatusService manages the account lifecycle. Like the RevocationService it caches
+// statuses in memory so JWTAuth doesn't hit the database on every request; changes made
+// by other replicas become visible after USER_STATUS_CACHE_TTL.
+// Deleted accounts are kept for ACCOUNT_DELETION_GRACE_PERIOD before the purger erases them.
+type UserStatusService struct {
+	userRepo            interfaces.UserRepository
+	authService         *AuthService
+	refreshTokenService *RefreshTokenService
+	revocationService   *RevocationService
+	cacheTTL            time.Duration
+	deletionGracePeriod time.Duration
+	restoreTokenTTL     time.Duration
+
+	mu    sync.RWMutex
+	users map[uint]cachedUserStatus
+}
+
+// GetUserStatusService returns the process-wide UserStatusService so every caller shares one cache
+func GetUserStatusService() *UserStatusService {
+	userStatusServiceOnce.Do(func() {
+		factory := repositories.NewFactory()
+		userStatusServiceInstance = &UserStatusService{
+			userRepo:            factory.GetUserRepository(),
+			authService:         NewAuthService(),
+			refreshTokenService: NewRefreshTokenService(),
+			revocationService:   GetRevocationService(),
+			cacheTTL:            config.Duration("USER_STATUS_CACHE_TTL", 30*time.Second),
+			deletionGracePeriod: config.Duration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+			restoreTokenTTL:     config.Duration("ACCOUNT_RESTORE_TOKEN_TTL", 10*time.Minute),
+			users:               make(map[uint]cachedUserStatus),
+		}
+	})
+	return userStatusServiceInstance
+}
+
+// CheckUser returns the status error of a user whose token is being used, or ErrUserNotFound
+func (s *UserStatusService) CheckUser(userID uint) error {
+	s.mu.RLock()
+	cached, ok := s.users[userID]
+	s.mu.RUnlock()
+	if ok && time.Since(cached.checkedAt) < s.cacheTTL {
+		return UserStatusError(cached.status)
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	s.cache(user.ID, user.Status)
+	return UserStatusError(user.Status)
+}
+
+// Transition changes the status of a user and records why. actorID is the admin making
+// the change, or nil for the user or the system. Locking an account signs the user out everywhere.
+func (s *UserStatusService) Transition(userID uint, to models.UserStatus, reason string, actorID *uint) (*models.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.transition(user, to, reason, actorID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ScheduleDeletion deletes an account: the user is signed out everywhere and can't sign in,
+// and the account is purged once the grace period ends unless it is restored before
+func (s *UserStatusService) ScheduleDeletion(userID uint, reason string, actorID *uint) (*models.User, error) {
+	return s.Transition(userID, models.UserStatusDeleted, reason, actorID)
+}
+
+// Restore cancels the deletion of an account. It becomes active again, or pending if its
+// email was never verified.
+func (s *UserStatusService) Restore(userID uint, reason string, actorID *uint) (*models.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Status != models.UserStatusDeleted {
+		return nil, fmt.Errorf("%w: account is %s, not deleted", ErrInvalidStatusTransition, user.Status)
+	}
+
+	to := models.UserStatusPending
+	if user.IsEmailVerified() {
+		to = models.UserStatusActive
+	}
+	if err := s.transition(user, to, reason, actorID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// IssueRestoreToken returns a token the user of an account pending deletion can exchange
+// for restoring it. It is only handed out after the user proved who they are by signing in.
+func (s *UserStatusService) IssueRestoreToken(userID uint) (string, error) {
+	return s.authService.SignToken(&Claims{
+		TokenUse: TokenUseAccountRestore,
+		UserID:   userID,
+	}, s.restoreTokenTTL)
+}
+
+// RestoreWithToken restores the account a restore token was issued for. Tokens are single-use.
+func (s *UserStatusService) RestoreWithToken(token string) (*models.User, error) {
+	claims, err := s.authService.ParseToken(token, TokenUseAccountRestore)
+	if err != nil {
+		return nil, ErrInvalidRestoreToken
+	}
+	used, err := s.revocationService.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrInvalidRestoreToken
+	}
+	if err := s.revocationService.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	user, err := s.Restore(claims.UserID, "restored by the user", nil)
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidStatusTransition) {
+		return nil, ErrInvalidRestoreToken
+	}
+	return user, err
+}
+
+// ActivatePending moves a pending account to active once its email has been verified
+func (s *UserStatusService) ActivatePending(user *models.User) error {
+	if user.Status != models.UserStatusPending {
+		return nil
+	}
+	return s.transition(user, models.UserStatusActive, "email verified", nil)
+}
+
+// History returns the status transitions of a user, oldest first
+func (s *UserStatusService) History(userID uint) ([]models.UserStatusTransition, error) {
+	return s.userRepo.FindStatusTransitions(userID)
+}
+
+// transition applies a status change to a loaded user
+func (s *UserStatusService) transition(user *models.User, to models.UserStatus, reason string, actorID *uint) error {
+	from := user.Status
+	if from == to {
+		return fmt.Errorf("%w: account is already %s", ErrInvalidStatusTransition, to)
+	}
+	if !slices.Contains(userStatusTransitions[from], to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, to)
+	}
+
+	now := time.Now()
+	user.Status = to
+	user.StatusReason = reason
+	user.StatusChangedAt = &now
+	user.PurgeAt = nil
+	if to == models.UserStatusDeleted {
+		purgeAt := now.Add(s.deletionGracePeriod)
+		user.PurgeAt = &purgeAt
+	}
+	err := s.userRepo.UpdateStatus(user, &models.UserStatusTransition{
+		UserID:     user.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		ActorID:    actorID,
+	})
+	if err != nil {
+		return err
+	}
+	s.cache(user.ID, to)
+
+	if !to.CanSignIn() {
+		if err := s.refreshTokenService.RevokeAllForUser(user.ID); err != nil {
+			return err
+		}
+		return s.revocationService.RevokeAllForUser(user.ID)
+	}
+	return nil
+}
+
+// cache remembers the status of a user
+func (s *UserStatusService) cache(userID uint, status models.UserStatus) {
+	s.mu.Lock()
+	s.users[userID] = cachedUserStatus{status: status, checkedAt: time.Now()}
+	s.mu.Unlock()
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// WebAuthn protocol helpers (https://www.w3.org/TR/webauthn-2/): parsing client data,
+// authenticator data, attestation objects and COSE keys, and verifying assertion signatures.
+
+// Authenticator data flags
+const (
+	authDataFlagUserPresent    = 0x01
+	authDataFlagUserVerified   = 0x04
+	authDataFlagBackupEligible = 0x08
+	authDataFlagBackupState    = 0x10
+	authDataFlagAttestedData   = 0x40
+	authDataFlagExtensionData  = 0x80
+)
+
+// COSE algorithm identifiers we accept
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgRS256 = -257
+)
+
+// ErrInvalidWebAuthnResponse is returned when an authenticator response fails verification
+var ErrInvalidWebAuthnResponse = errors.New("invalid WebAuthn response")
+
+// collectedClientData is the JSON the browser signs over (clientDataJSON)
+type collectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// authenticatorData is the parsed binary authenticator data
+type authenticatorData struct {
+	raw           []byte
+	rpIDHash      []byte
+	flags         byte
+	signCount     uint32
+	aaguid        []byte
+	credentialID  []byte
+	credentialKey []byte // COSE encoded public key
+}
+
+func (a *authenticatorData) userPresent() bool  { return a.flags&authDataFlagUserPresent != 0 }
+func (a *authenticatorData) userVerified() bool { return a.flags&authDataFlagUserVerified != 0 }
+
+// base64URLBytes accepts the unpadded base64url encoding browsers use for binary fields,
+// tolerating padding from clients that add it
+type base64URLBytes []byte
+
+func (b *base64URLBytes) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(trimBase64Padding(encoded))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+func trimBase64Padding(value string) string {
+	for len(value) > 0 && value[len(value)-1] == '=' {
+		value = value[:len(value)-1]
+	}
+	return value
+}
+
+// parseClientData decodes clientDataJSON and checks type, challenge and origin
+func parseClientData(raw []byte, expectedType, expectedChallenge string, origins []string) error {
+	var clientData collectedClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return fmt.Errorf("%w: malformed client data", ErrInvalidWebAuthnResponse)
+	}
+	if clientData.Type != expectedType {
+		return fmt.Errorf("%w: unexpected client data type %q", ErrInvalidWebAuthnResponse, clientData.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(trimBase64Padding(clientData.Challenge)), []byte(expectedChallenge)) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidWebAuthnResponse)
+	}
+	if !slices.Contains(origins, clientData.Origin) {
+		return fmt.Errorf("%w: unexpected origin %q", ErrInvalidWebAuthnResponse, clientData.Origin)
+	}
+	return nil
+}
+
+// parseAuthenticatorData decodes the binary authenticator data structure
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidWebAuthnResponse)
+	}
+
+	data := &authenticatorData{
+		raw:       raw,
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	rest := raw[37:]
+	if data.flags&authDataFlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidWebAuthnResponse)
+		}
+		data.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return nil, fmt.Errorf("%w: credential ID truncated", ErrInvalidWebAuthnResponse)
+		}
+		data.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		// The COSE key has no length prefix; decode it to find where it ends
+		_, consumed, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed credential public key", ErrInvalidWebAuthnResponse)
+		}
+		data.credentialKey = rest[:consumed]
+		rest = rest[consumed:]
+	}
+
+	if data.flags&authDataFlagExtensionData != 0 {
+		_, consumed, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed extension data", ErrInvalidWebAuthnResponse)
+		}
+		rest = rest[consumed:]
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrInvalidWebAuthnResponse)
+	}
+	return data, nil
+}
+
+// parseAttestationObject decodes the attestation object and returns its authenticator data.
+// We request attestation conveyance "none", so attestation statements are not evaluated;
+// the credential is trusted on first use like a password.
+func parseAttestationObject(raw []byte) (*authenticatorData, error) {
+	decoded, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidWebAuthnResponse)
+	}
+	object, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidWebAuthnResponse)
+	}
+	authData, ok := object["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object has no authData", ErrInvalidWebAuthnResponse)
+	}
+	return parseAuthenticatorData(authData)
+}
+
+// verifyRPIDHash checks the authenticator data was produced for our relying party ID
+func verifyRPIDHash(data *authenticatorData, rpID string) error {
+	expected := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(data.rpIDHash, expected[:]) {
+		return fmt.Errorf("%w: relying party ID mismatch", ErrInvalidWebAuthnResponse)
+	}
+	return nil
+}
+
+// parseCOSEKey decodes a COSE_Key into a public key and its COSE algorithm
+func parseCOSEKey(raw []byte) (crypto.PublicKey, int, error) {
+	decoded, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	key, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, 0, errors.New("COSE key is not a map")
+	}
+
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == coseAlgES256: // EC2, P-256
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("invalid EC2 COSE key")
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !public.Curve.IsOnCurve(public.X, public.Y) {
+			return nil, 0, errors.New("EC point is not on the curve")
+		}
+		return public, coseAlgES256, nil
+
+	case kty == 1 && alg == coseAlgEdDSA: // OKP, Ed25519
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("invalid OKP COSE key")
+		}
+		return ed25519.PublicKey(x), coseAlgEdDSA, nil
+
+	case kty == 3 && alg == coseAlgRS256: // RSA
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RSA COSE key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, coseAlgRS256, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported COSE key (kty %d, alg %d)", kty, alg)
+}
+
+// verifyAssertionSignature checks the signature over authenticatorData || SHA-256(clientDataJSON)
+func verifyAssertionSignature(coseKey []byte, authData, clientDataJSON, signature []byte) error {
+	public, alg, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	var valid bool
+	switch alg {
+	case coseAlgES256:
+		digest := sha256.Sum256(signed)
+		valid = ecdsa.VerifyASN1(public.(*ecdsa.PublicKey), digest[:], signature)
+	case coseAlgRS256:
+		digest := sha256.Sum256(signed)
+		valid = rsa.VerifyPKCS1v15(public.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case coseAlgEdDSA:
+		valid = ed25519.Verify(public.(ed25519.PublicKey), signed, signature)
+	}
+	if !valid {
+		return fmt.Errorf("%w: bad signature", ErrInvalidWebAuthnResponse)
+	}
+	return nil
+}
+
+// formatAAGUID renders an authenticator's AAGUID in UUID form
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(aaguid)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
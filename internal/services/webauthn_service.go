@@ -0,0 +1,485 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/config"
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/database/repositories"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+// Token uses of the signed ceremony tokens that carry WebAuthn challenges between begin and finish
+const (
+	TokenUseWebAuthnRegistration = "webauthn_registration"
+	TokenUseWebAuthnLogin        = "webauthn_login"
+	TokenUseWebAuthnMFA          = "webauthn_mfa"
+)
+
+var (
+	// ErrInvalidWebAuthnCeremony is returned for unknown, expired or used ceremony tokens
+	ErrInvalidWebAuthnCeremony = errors.New("invalid or expired WebAuthn ceremony")
+
+	// ErrWebAuthnCredentialExists is returned when registering a credential that is already registered
+	ErrWebAuthnCredentialExists = errors.New("credential is already registered")
+
+	// ErrWebAuthnCredentialNotFound is returned for unknown credentials
+	ErrWebAuthnCredentialNotFound = errors.New("credential not found")
+)
+
+// PublicKeyCredentialDescriptor identifies a credential in ceremony options
+type PublicKeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// PublicKeyCredentialParameters lists an acceptable credential algorithm
+type PublicKeyCredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// RelyingPartyEntity identifies this service to the authenticator
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity identifies the account a credential is created for
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// AuthenticatorSelection states the authenticator requirements for registration
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CredentialCreationOptions is the JSON form of PublicKeyCredentialCreationOptions
+type CredentialCreationOptions struct {
+	RP                     RelyingPartyEntity              `json:"rp"`
+	User                   UserEntity                      `json:"user"`
+	Challenge              string                          `json:"challenge"`
+	PubKeyCredParams       []PublicKeyCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                           `json:"timeout"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection          `json:"authenticatorSelection"`
+	Attestation            string                          `json:"attestation"`
+}
+
+// CredentialRequestOptions is the JSON form of PublicKeyCredentialRequestOptions
+type CredentialRequestOptions struct {
+	Challenge        string                          `json:"challenge"`
+	Timeout          int64                           `json:"timeout"`
+	RPID             string                          `json:"rpId"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification"`
+}
+
+// WebAuthnCeremony is returned by the begin endpoints: options for navigator.credentials
+// plus a signed token the client sends back with the authenticator response
+type WebAuthnCeremony struct {
+	Options       any    `json:"options"`
+	CeremonyToken string `json:"ceremony_token"`
+}
+
+// RegistrationResponse is the JSON form of a PublicKeyCredential returned by navigator.credentials.create
+type RegistrationResponse struct {
+	ID       string         `json:"id" binding:"required"`
+	RawID    base64URLBytes `json:"rawId" binding:"required"`
+	Type     string         `json:"type" binding:"required"`
+	Response struct {
+		ClientDataJSON    base64URLBytes `json:"clientDataJSON" binding:"required"`
+		AttestationObject base64URLBytes `json:"attestationObject" binding:"required"`
+		Transports        []string       `json:"transports"`
+	} `json:"response" binding:"required"`
+}
+
+// AssertionResponse is the JSON form of a PublicKeyCredential returned by navigator.credentials.get
+type AssertionResponse struct {
+	ID       string         `json:"id" binding:"required"`
+	RawID    base64URLBytes `json:"rawId" binding:"required"`
+	Type     string         `json:"type" binding:"required"`
+	Response struct {
+		ClientDataJSON    base64URLBytes `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData base64URLBytes `json:"authenticatorData" binding:"required"`
+		Signature         base64URLBytes `json:"signature" binding:"required"`
+		UserHandle        base64URLBytes `json:"userHandle"`
+	} `json:"response" binding:"required"`
+}
+
+// WebAuthnService implements the WebAuthn relying party: passkey registration,
+// passwordless login and passkeys as a second factor
+type WebAuthnService struct {
+	credentialRepo    interfaces.WebAuthnCredentialRepository
+	userRepo          interfaces.UserRepository
+	authService       *AuthService
+	revocationService *RevocationService
+	rpID              string
+	rpName            string
+	origins           []string
+	timeout           time.Duration
+}
+
+// NewWebAuthnService creates a new WebAuthnService configured from the environment.
+// WEBAUTHN_RP_ID is the registrable domain (e.g. example.com) and WEBAUTHN_ORIGINS the
+// comma separated origins allowed to run ceremonies (e.g. https://app.example.com).
+func NewWebAuthnService() *WebAuthnService {
+	factory := repositories.NewFactory()
+	rpID := config.String("WEBAUTHN_RP_ID", "localhost")
+	origins := config.List("WEBAUTHN_ORIGINS")
+	if len(origins) == 0 {
+		origins = []string{"http://localhost:8080"}
+	}
+
+	return &WebAuthnService{
+		credentialRepo:    factory.GetWebAuthnCredentialRepository(),
+		userRepo:          factory.GetUserRepository(),
+		authService:       NewAuthService(),
+		revocationService: GetRevocationService(),
+		rpID:              rpID,
+		rpName:            config.String("WEBAUTHN_RP_NAME", rpID),
+		origins:           origins,
+		timeout:           config.Duration("WEBAUTHN_TIMEOUT", 5*time.Minute),
+	}
+}
+
+// BeginRegistration starts registering a new passkey for the user
+func (s *WebAuthnService) BeginRegistration(user *models.User) (*WebAuthnCeremony, error) {
+	existing, err := s.credentialRepo.FindByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, token, err := s.newCeremony(TokenUseWebAuthnRegistration, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnCeremony{
+		Options: CredentialCreationOptions{
+			RP: RelyingPartyEntity{ID: s.rpID, Name: s.rpName},
+			User: UserEntity{
+				ID:          base64.RawURLEncoding.EncodeToString(webAuthnUserHandle(user.ID)),
+				Name:        user.Email,
+				DisplayName: user.Username,
+			},
+			Challenge: challenge,
+			PubKeyCredParams: []PublicKeyCredentialParameters{
+				{Type: "public-key", Alg: coseAlgES256},
+				{Type: "public-key", Alg: coseAlgEdDSA},
+				{Type: "public-key", Alg: coseAlgRS256},
+			},
+			Timeout:            s.timeout.Milliseconds(),
+			ExcludeCredentials: credentialDescriptors(existing),
+			AuthenticatorSelection: AuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: "preferred",
+			},
+			Attestation: "none",
+		},
+		CeremonyToken: token,
+	}, nil
+}
+
+// FinishRegistration verifies the authenticator's response and stores the new credential
+func (s *WebAuthnService) FinishRegistration(userID uint, ceremonyToken string, response *RegistrationResponse, name string) (*models.WebAuthnCredential, error) {
+	claims, err := s.parseCeremony(ceremonyToken, TokenUseWebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID != userID {
+		return nil, ErrInvalidWebAuthnCeremony
+	}
+	if response.Type != "public-key" {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	if err := parseClientData(response.Response.ClientDataJSON, "webauthn.create", claims.Challenge, s.origins); err != nil {
+		return nil, err
+	}
+	authData, err := parseAttestationObject(response.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyRPIDHash(authData, s.rpID); err != nil {
+		return nil, err
+	}
+	if !authData.userPresent() {
+		return nil, fmt.Errorf("%w: user presence missing", ErrInvalidWebAuthnResponse)
+	}
+	if len(authData.credentialID) == 0 || string(authData.credentialID) != string(response.RawID) {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	_, alg, err := parseCOSEKey(authData.credentialKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebAuthnResponse, err)
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(authData.credentialID)
+	existing, err := s.credentialRepo.FindByCredentialID(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrWebAuthnCredentialExists
+	}
+
+	if err := s.consumeCeremony(claims); err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+	credential := &models.WebAuthnCredential{
+		UserID:         userID,
+		CredentialID:   credentialID,
+		PublicKey:      authData.credentialKey,
+		Algorithm:      alg,
+		SignCount:      authData.signCount,
+		AAGUID:         formatAAGUID(authData.aaguid),
+		Transports:     strings.Join(response.Response.Transports, ","),
+		Name:           name,
+		BackupEligible: authData.flags&authDataFlagBackupEligible != 0,
+		BackupState:    authData.flags&authDataFlagBackupState != 0,
+	}
+	if err := s.credentialRepo.Create(credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// BeginLogin starts a passwordless login. Without an email the browser offers any
+// discoverable passkey for this site. Unknown emails get the same empty allow list so
+// the endpoint can't be used to probe for accounts.
+func (s *WebAuthnService) BeginLogin(email string) (*WebAuthnCeremony, error) {
+	var userID uint
+	var allowed []models.WebAuthnCredential
+	if email != "" {
+		user, err := s.userRepo.FindByEmail(email)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			userID = user.ID
+			if allowed, err = s.credentialRepo.FindByUserID(user.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return s.beginAssertion(TokenUseWebAuthnLogin, userID, allowed, "required")
+}
+
+// FinishLogin verifies a passwordless assertion and returns the authenticated user.
+// User verification (PIN or biometrics) is required since no other factor is involved.
+func (s *WebAuthnService) FinishLogin(ceremonyToken string, response *AssertionResponse) (*models.User, error) {
+	claims, err := s.parseCeremony(ceremonyToken, TokenUseWebAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.verifyAssertion(claims, response, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.consumeCeremony(claims); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(credential.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	return user, nil
+}
+
+// BeginSecondFactor starts an assertion restricted to the user's registered credentials
+func (s *WebAuthnService) BeginSecondFactor(userID uint) (*WebAuthnCeremony, error) {
+	credentials, err := s.credentialRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	return s.beginAssertion(TokenUseWebAuthnMFA, userID, credentials, "preferred")
+}
+
+// VerifySecondFactor verifies an assertion made with one of the user's credentials
+func (s *WebAuthnService) VerifySecondFactor(userID uint, ceremonyToken string, response *AssertionResponse) error {
+	claims, err := s.parseCeremony(ceremonyToken, TokenUseWebAuthnMFA)
+	if err != nil {
+		return err
+	}
+	if claims.UserID != userID {
+		return ErrInvalidWebAuthnCeremony
+	}
+
+	if _, err := s.verifyAssertion(claims, response, false); err != nil {
+		return err
+	}
+	return s.consumeCeremony(claims)
+}
+
+// HasCredentials reports whether the user registered at least one credential
+func (s *WebAuthnService) HasCredentials(userID uint) (bool, error) {
+	credentials, err := s.credentialRepo.FindByUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	return len(credentials) > 0, nil
+}
+
+// ListCredentials returns the credentials registered by the user
+func (s *WebAuthnService) ListCredentials(userID uint) ([]models.WebAuthnCredential, error) {
+	return s.credentialRepo.FindByUserID(userID)
+}
+
+// beginAssertion creates request options and the matching ceremony token
+func (s *WebAuthnService) beginAssertion(tokenUse string, userID uint, allowed []models.WebAuthnCredential, userVerification string) (*WebAuthnCeremony, error) {
+	challenge, token, err := s.newCeremony(tokenUse, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnCeremony{
+		Options: CredentialRequestOptions{
+			Challenge:        challenge,
+			Timeout:          s.timeout.Milliseconds(),
+			RPID:             s.rpID,
+			AllowCredentials: credentialDescriptors(allowed),
+			UserVerification: userVerification,
+		},
+		CeremonyToken: token,
+	}, nil
+}
+
+// verifyAssertion checks an assertion against the stored credential and updates its sign count
+func (s *WebAuthnService) verifyAssertion(claims *Claims, response *AssertionResponse, requireUserVerification bool) (*models.WebAuthnCredential, error) {
+	if response.Type != "public-key" {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	credential, err := s.credentialRepo.FindByCredentialID(base64.RawURLEncoding.EncodeToString(response.RawID))
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	// A ceremony started for a specific user only accepts that user's credentials
+	if claims.UserID != 0 && credential.UserID != claims.UserID {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	if len(response.Response.UserHandle) > 0 && string(response.Response.UserHandle) != string(webAuthnUserHandle(credential.UserID)) {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	if err := parseClientData(response.Response.ClientDataJSON, "webauthn.get", claims.Challenge, s.origins); err != nil {
+		return nil, err
+	}
+	authData, err := parseAuthenticatorData(response.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyRPIDHash(authData, s.rpID); err != nil {
+		return nil, err
+	}
+	if !authData.userPresent() {
+		return nil, fmt.Errorf("%w: user presence missing", ErrInvalidWebAuthnResponse)
+	}
+	if requireUserVerification && !authData.userVerified() {
+		return nil, fmt.Errorf("%w: user verification missing", ErrInvalidWebAuthnResponse)
+	}
+
+	if err := verifyAssertionSignature(credential.PublicKey, authData.raw, response.Response.ClientDataJSON, response.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	// A counter that doesn't increase suggests a cloned authenticator.
+	// Authenticators that don't implement counters always report zero.
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return nil, fmt.Errorf("%w: sign count did not increase, the authenticator may be cloned", ErrInvalidWebAuthnResponse)
+	}
+	updated, err := s.credentialRepo.UpdateSignCount(credential.ID, credential.SignCount, authData.signCount, authData.flags&authDataFlagBackupState != 0)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrInvalidWebAuthnResponse
+	}
+
+	return credential, nil
+}
+
+// newCeremony generates a challenge and signs it into a ceremony token
+func (s *WebAuthnService) newCeremony(tokenUse string, userID uint) (string, string, error) {
+	challenge, err := generateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	token, err := s.authService.SignToken(&Claims{
+		TokenUse:  tokenUse,
+		UserID:    userID,
+		Challenge: challenge,
+	}, s.timeout)
+	if err != nil {
+		return "", "", err
+	}
+	return challenge, token, nil
+}
+
+// parseCeremony verifies a ceremony token and checks it hasn't been used yet
+func (s *WebAuthnService) parseCeremony(token, tokenUse string) (*Claims, error) {
+	claims, err := s.authService.ParseToken(token, tokenUse)
+	if err != nil || claims.Challenge == "" {
+		return nil, ErrInvalidWebAuthnCeremony
+	}
+	used, err := s.revocationService.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrInvalidWebAuthnCeremony
+	}
+	return claims, nil
+}
+
+// consumeCeremony makes a ceremony token single-use
+func (s *WebAuthnService) consumeCeremony(claims *Claims) error {
+	return s.revocationService.RevokeToken(claims.ID, claims.UserID, claims.ExpiresAt.Time)
+}
+
+// webAuthnUserHandle is the opaque user.id given to authenticators
+func webAuthnUserHandle(userID uint) []byte {
+	return []byte(strconv.FormatUint(uint64(userID), 10))
+}
+
+// credentialDescriptors converts stored credentials for allow/exclude lists
+func credentialDescriptors(credentials []models.WebAuthnCredential) []PublicKeyCredentialDescriptor {
+	descriptors := make([]PublicKeyCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptor := PublicKeyCredentialDescriptor{Type: "public-key", ID: credential.CredentialID}
+		if credential.Transports != "" {
+			descriptor.Transports = slices.DeleteFunc(strings.Split(credential.Transports, ","), func(t string) bool { return t == "" })
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors
+}
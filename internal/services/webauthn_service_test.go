@@ -0,0 +1,642 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danigrb.dev/user-service/internal/database/interfaces"
+	"github.com/danigrb.dev/user-service/internal/models"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// fakeWebAuthnCredentialRepository keeps credentials in memory
+type fakeWebAuthnCredentialRepository struct {
+	mu          sync.Mutex
+	credentials []models.WebAuthnCredential
+}
+
+func (r *fakeWebAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential.ID = uint(len(r.credentials) + 1)
+	credential.CreatedAt = time.Now()
+	r.credentials = append(r.credentials, *credential)
+	return nil
+}
+
+func (r *fakeWebAuthnCredentialRepository) FindByCredentialID(credentialID string) (*models.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, credential := range r.credentials {
+		if credential.CredentialID == credentialID {
+			return &credential, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeWebAuthnCredentialRepository) FindByUserID(userID uint) ([]models.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []models.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			found = append(found, credential)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeWebAuthnCredentialRepository) UpdateSignCount(id uint, previous, current uint32, backupState bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.credentials {
+		if r.credentials[i].ID == id && r.credentials[i].SignCount == previous {
+			now := time.Now()
+			r.credentials[i].SignCount = current
+			r.credentials[i].BackupState = backupState
+			r.credentials[i].LastUsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeWebAuthnCredentialRepository) Delete(id, userID uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before := len(r.credentials)
+	r.credentials = slices.DeleteFunc(r.credentials, func(c models.WebAuthnCredential) bool {
+		return c.ID == id && c.UserID == userID
+	})
+	return len(r.credentials) != before, nil
+}
+
+// fakeUserRepository serves users from memory; other methods are not used by these tests
+type fakeUserRepository struct {
+	interfaces.UserRepository
+	users []*models.User
+}
+
+func (r *fakeUserRepository) FindByID(id uint) (*models.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserRepository) FindByEmail(email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+// fakeRevocationRepository records revoked token IDs and user-wide revocations in memory
+type fakeRevocationRepository struct {
+	interfaces.RevocationRepository
+	mu      sync.Mutex
+	revoked map[string]bool
+	users   map[uint]time.Time
+}
+
+func (r *fakeRevocationRepository) RevokeToken(token *models.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked[token.JTI] = true
+	return nil
+}
+
+func (r *fakeRevocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.revoked[jti], nil
+}
+
+func (r *fakeRevocationRepository) RevokeUserTokensBefore(userID uint, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.users == nil {
+		r.users = make(map[uint]time.Time)
+	}
+	r.users[userID] = before
+	return nil
+}
+
+func (r *fakeRevocationRepository) FindUserRevocation(userID uint) (*models.UserTokenRevocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before, ok := r.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &models.UserTokenRevocation{UserID: userID, RevokedBefore: before}, nil
+}
+
+// fakeSigningKeyRepository keeps signing keys in memory, newest first
+type fakeSigningKeyRepository struct {
+	mu   sync.Mutex
+	keys []models.SigningKey
+}
+
+func (r *fakeSigningKeyRepository) Create(key *models.SigningKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key.CreatedAt = time.Now()
+	r.keys = append([]models.SigningKey{*key}, r.keys...)
+	return nil
+}
+
+func (r *fakeSigningKeyRepository) FindUnexpired() ([]models.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.keys), nil
+}
+
+func (r *fakeSigningKeyRepository) RotateAllExcept(kid string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i := range r.keys {
+		if r.keys[i].KID != kid && r.keys[i].RotatedAt == nil {
+			r.keys[i].RotatedAt = &now
+			r.keys[i].ExpiresAt = &expiresAt
+		}
+	}
+	return nil
+}
+
+func (r *fakeSigningKeyRepository) UpdatePrivateKey(id uint, privateKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.keys {
+		if r.keys[i].ID == id {
+			r.keys[i].PrivateKey = privateKey
+		}
+	}
+	return nil
+}
+
+func (r *fakeSigningKeyRepository) DeleteExpired() error {
+	return nil
+}
+
+// newTestAuthService builds an AuthService signing with an in-memory ES256 key and revoking
+// tokens in memory
+func newTestAuthService(t *testing.T) (*AuthService, *RevocationService) {
+	t.Helper()
+	t.Setenv("JWT_SIGNING_ALG", "ES256")
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+
+	keyManager := NewKeyManagerWithRepo(&fakeSigningKeyRepository{})
+	if err := keyManager.RotateIfDue(); err != nil {
+		t.Fatalf("create signing key: %v", err)
+	}
+	revocationService := NewRevocationServiceWithRepos(&fakeRevocationRepository{revoked: make(map[string]bool)}, nil)
+
+	authService := &AuthService{
+		keyManager:        keyManager,
+		revocationService: revocationService,
+		issuer:            "user-service",
+		audience:          []string{"user-service"},
+		leeway:            time.Second,
+	}
+	return authService, revocationService
+}
+
+// newTestWebAuthnService builds a WebAuthnService on in-memory repositories with one user
+func newTestWebAuthnService(t *testing.T) (*WebAuthnService, *fakeWebAuthnCredentialRepository, *models.User) {
+	t.Helper()
+	authService, revocationService := newTestAuthService(t)
+
+	user := &models.User{ID: 42, Email: "ada@example.com", Username: "ada"}
+	credentialRepo := &fakeWebAuthnCredentialRepository{}
+	service := &WebAuthnService{
+		credentialRepo:    credentialRepo,
+		userRepo:          &fakeUserRepository{users: []*models.User{user, {ID: 7, Email: "bob@example.com"}}},
+		authService:       authService,
+		revocationService: revocationService,
+		rpID:              testRPID,
+		rpName:            "Example",
+		origins:           []string{testOrigin},
+		timeout:           time.Minute,
+	}
+	return service, credentialRepo, user
+}
+
+// creationChallenge returns the challenge of a registration ceremony
+func creationChallenge(t *testing.T, ceremony *WebAuthnCeremony) string {
+	t.Helper()
+	options, ok := ceremony.Options.(CredentialCreationOptions)
+	if !ok {
+		t.Fatalf("options are %T, want CredentialCreationOptions", ceremony.Options)
+	}
+	return options.Challenge
+}
+
+// requestChallenge returns the challenge of an authentication ceremony
+func requestChallenge(t *testing.T, ceremony *WebAuthnCeremony) string {
+	t.Helper()
+	options, ok := ceremony.Options.(CredentialRequestOptions)
+	if !ok {
+		t.Fatalf("options are %T, want CredentialRequestOptions", ceremony.Options)
+	}
+	return options.Challenge
+}
+
+// registerAuthenticator registers a new software authenticator for the user
+func registerAuthenticator(t *testing.T, service *WebAuthnService, user *models.User, alg int) *softAuthenticator {
+	t.Helper()
+	authenticator := newSoftAuthenticator(t, alg, testRPID, testOrigin)
+	ceremony, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration error: %v", err)
+	}
+	if _, err := service.FinishRegistration(user.ID, ceremony.CeremonyToken, authenticator.register(creationChallenge(t, ceremony)), "Laptop"); err != nil {
+		t.Fatalf("FinishRegistration error: %v", err)
+	}
+	return authenticator
+}
+
+func TestWebAuthnRegistration(t *testing.T) {
+	for _, alg := range []int{coseAlgES256, coseAlgEdDSA} {
+		service, credentialRepo, user := newTestWebAuthnService(t)
+		authenticator := newSoftAuthenticator(t, alg, testRPID, testOrigin)
+
+		ceremony, err := service.BeginRegistration(user)
+		if err != nil {
+			t.Fatalf("BeginRegistration error: %v", err)
+		}
+		options := ceremony.Options.(CredentialCreationOptions)
+		if options.RP.ID != testRPID || options.User.Name != user.Email {
+			t.Errorf("unexpected creation options: %+v", options)
+		}
+
+		credential, err := service.FinishRegistration(user.ID, ceremony.CeremonyToken, authenticator.register(options.Challenge), "")
+		if err != nil {
+			t.Fatalf("alg %d: FinishRegistration error: %v", alg, err)
+		}
+		if credential.UserID != user.ID || credential.Algorithm != alg || credential.Name != "Passkey" {
+			t.Errorf("alg %d: unexpected credential %+v", alg, credential)
+		}
+		if credential.Transports != "internal,hybrid" || credential.AAGUID == "" {
+			t.Errorf("alg %d: transports %q, AAGUID %q", alg, credential.Transports, credential.AAGUID)
+		}
+		if stored, _ := credentialRepo.FindByUserID(user.ID); len(stored) != 1 {
+			t.Errorf("alg %d: %d credentials stored, want 1", alg, len(stored))
+		}
+
+		// Registered credentials are excluded from new registrations and can't be added twice
+		ceremony, err = service.BeginRegistration(user)
+		if err != nil {
+			t.Fatalf("BeginRegistration error: %v", err)
+		}
+		if excluded := ceremony.Options.(CredentialCreationOptions).ExcludeCredentials; len(excluded) != 1 || excluded[0].ID != credential.CredentialID {
+			t.Errorf("alg %d: excluded credentials %+v", alg, excluded)
+		}
+		_, err = service.FinishRegistration(user.ID, ceremony.CeremonyToken, authenticator.register(creationChallenge(t, ceremony)), "")
+		if !errors.Is(err, ErrWebAuthnCredentialExists) {
+			t.Errorf("alg %d: duplicate registration: got %v, want ErrWebAuthnCredentialExists", alg, err)
+		}
+	}
+}
+
+func TestWebAuthnRegistrationRejectsCeremonyOfAnotherUser(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+	authenticator := newSoftAuthenticator(t, coseAlgES256, testRPID, testOrigin)
+
+	ceremony, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration error: %v", err)
+	}
+	_, err = service.FinishRegistration(7, ceremony.CeremonyToken, authenticator.register(creationChallenge(t, ceremony)), "")
+	if !errors.Is(err, ErrInvalidWebAuthnCeremony) {
+		t.Errorf("got %v, want ErrInvalidWebAuthnCeremony", err)
+	}
+}
+
+func TestWebAuthnPasswordlessLogin(t *testing.T) {
+	for _, alg := range []int{coseAlgES256, coseAlgEdDSA} {
+		service, credentialRepo, user := newTestWebAuthnService(t)
+		authenticator := registerAuthenticator(t, service, user, alg)
+
+		// With an email only that user's credentials are allowed
+		ceremony, err := service.BeginLogin(user.Email)
+		if err != nil {
+			t.Fatalf("BeginLogin error: %v", err)
+		}
+		if allowed := ceremony.Options.(CredentialRequestOptions).AllowCredentials; len(allowed) != 1 {
+			t.Errorf("alg %d: %d allowed credentials, want 1", alg, len(allowed))
+		}
+		loggedIn, err := service.FinishLogin(ceremony.CeremonyToken, authenticator.assert(requestChallenge(t, ceremony), webAuthnUserHandle(user.ID)))
+		if err != nil {
+			t.Fatalf("alg %d: FinishLogin error: %v", alg, err)
+		}
+		if loggedIn.ID != user.ID {
+			t.Errorf("alg %d: logged in as %d, want %d", alg, loggedIn.ID, user.ID)
+		}
+
+		// Without one, any discoverable credential of this site is accepted
+		ceremony, err = service.BeginLogin("")
+		if err != nil {
+			t.Fatalf("BeginLogin error: %v", err)
+		}
+		if allowed := ceremony.Options.(CredentialRequestOptions).AllowCredentials; len(allowed) != 0 {
+			t.Errorf("alg %d: %d allowed credentials for a discoverable login, want 0", alg, len(allowed))
+		}
+		if _, err := service.FinishLogin(ceremony.CeremonyToken, authenticator.assert(requestChallenge(t, ceremony), webAuthnUserHandle(user.ID))); err != nil {
+			t.Fatalf("alg %d: discoverable FinishLogin error: %v", alg, err)
+		}
+
+		stored, _ := credentialRepo.FindByUserID(user.ID)
+		if stored[0].SignCount != authenticator.signCount || stored[0].LastUsedAt == nil {
+			t.Errorf("alg %d: sign count %d, want %d", alg, stored[0].SignCount, authenticator.signCount)
+		}
+	}
+}
+
+func TestWebAuthnPasswordlessLoginRequiresUserVerification(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+	authenticator := registerAuthenticator(t, service, user, coseAlgES256)
+	authenticator.flags = authDataFlagUserPresent
+
+	ceremony, err := service.BeginLogin(user.Email)
+	if err != nil {
+		t.Fatalf("BeginLogin error: %v", err)
+	}
+	_, err = service.FinishLogin(ceremony.CeremonyToken, authenticator.assert(requestChallenge(t, ceremony), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+}
+
+func TestWebAuthnPasswordlessLoginRejectsWrongUserHandle(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+	authenticator := registerAuthenticator(t, service, user, coseAlgES256)
+
+	ceremony, err := service.BeginLogin("")
+	if err != nil {
+		t.Fatalf("BeginLogin error: %v", err)
+	}
+	_, err = service.FinishLogin(ceremony.CeremonyToken, authenticator.assert(requestChallenge(t, ceremony), webAuthnUserHandle(7)))
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+}
+
+func TestWebAuthnSecondFactor(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+	authenticator := registerAuthenticator(t, service, user, coseAlgEdDSA)
+	// A second factor only needs user presence
+	authenticator.flags = authDataFlagUserPresent
+
+	ceremony, err := service.BeginSecondFactor(user.ID)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor error: %v", err)
+	}
+	if err := service.VerifySecondFactor(user.ID, ceremony.CeremonyToken, authenticator.assert(requestChallenge(t, ceremony), nil)); err != nil {
+		t.Fatalf("VerifySecondFactor error: %v", err)
+	}
+
+	// The ceremony is bound to the user it was started for
+	ceremony, err = service.BeginSecondFactor(user.ID)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor error: %v", err)
+	}
+	err = service.VerifySecondFactor(7, ceremony.CeremonyToken, authenticator.assert(requestChallenge(t, ceremony), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnCeremony) {
+		t.Errorf("other user: got %v, want ErrInvalidWebAuthnCeremony", err)
+	}
+
+	// A passwordless ceremony can't be used as a second factor, nor the other way round
+	login, err := service.BeginLogin(user.Email)
+	if err != nil {
+		t.Fatalf("BeginLogin error: %v", err)
+	}
+	err = service.VerifySecondFactor(user.ID, login.CeremonyToken, authenticator.assert(requestChallenge(t, login), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnCeremony) {
+		t.Errorf("login ceremony: got %v, want ErrInvalidWebAuthnCeremony", err)
+	}
+	_, err = service.FinishLogin(ceremony.CeremonyToken, authenticator.assert(requestChallenge(t, ceremony), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnCeremony) {
+		t.Errorf("second factor ceremony: got %v, want ErrInvalidWebAuthnCeremony", err)
+	}
+
+	if _, err := service.BeginSecondFactor(7); !errors.Is(err, ErrWebAuthnCredentialNotFound) {
+		t.Errorf("user without passkeys: got %v, want ErrWebAuthnCredentialNotFound", err)
+	}
+}
+
+func TestWebAuthnSecondFactorRejectsCredentialOfAnotherUser(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+	registerAuthenticator(t, service, user, coseAlgES256)
+	other := registerAuthenticator(t, service, &models.User{ID: 7, Email: "bob@example.com"}, coseAlgES256)
+
+	ceremony, err := service.BeginSecondFactor(user.ID)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor error: %v", err)
+	}
+	err = service.VerifySecondFactor(user.ID, ceremony.CeremonyToken, other.assert(requestChallenge(t, ceremony), nil))
+	if !errors.Is(err, ErrWebAuthnCredentialNotFound) {
+		t.Errorf("got %v, want ErrWebAuthnCredentialNotFound", err)
+	}
+}
+
+func TestWebAuthnRejectsWrongOrigin(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+
+	evil := newSoftAuthenticator(t, coseAlgES256, testRPID, "https://evil.example")
+	ceremony, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration error: %v", err)
+	}
+	_, err = service.FinishRegistration(user.ID, ceremony.CeremonyToken, evil.register(creationChallenge(t, ceremony)), "")
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("registration: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+
+	authenticator := registerAuthenticator(t, service, user, coseAlgES256)
+	authenticator.origin = "https://evil.example"
+	login, err := service.BeginLogin(user.Email)
+	if err != nil {
+		t.Fatalf("BeginLogin error: %v", err)
+	}
+	_, err = service.FinishLogin(login.CeremonyToken, authenticator.assert(requestChallenge(t, login), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("login: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+}
+
+func TestWebAuthnRejectsWrongRPIDHash(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+
+	evil := newSoftAuthenticator(t, coseAlgES256, "evil.example", testOrigin)
+	ceremony, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration error: %v", err)
+	}
+	_, err = service.FinishRegistration(user.ID, ceremony.CeremonyToken, evil.register(creationChallenge(t, ceremony)), "")
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("registration: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+
+	authenticator := registerAuthenticator(t, service, user, coseAlgES256)
+	authenticator.rpID = "evil.example"
+	login, err := service.BeginSecondFactor(user.ID)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor error: %v", err)
+	}
+	err = service.VerifySecondFactor(user.ID, login.CeremonyToken, authenticator.assert(requestChallenge(t, login), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("assertion: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+}
+
+func TestWebAuthnRejectsReusedChallenge(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+
+	// A registration ceremony can only register one credential
+	ceremony, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration error: %v", err)
+	}
+	challenge := creationChallenge(t, ceremony)
+	authenticator := newSoftAuthenticator(t, coseAlgES256, testRPID, testOrigin)
+	if _, err := service.FinishRegistration(user.ID, ceremony.CeremonyToken, authenticator.register(challenge), ""); err != nil {
+		t.Fatalf("FinishRegistration error: %v", err)
+	}
+	second := newSoftAuthenticator(t, coseAlgES256, testRPID, testOrigin)
+	_, err = service.FinishRegistration(user.ID, ceremony.CeremonyToken, second.register(challenge), "")
+	if !errors.Is(err, ErrInvalidWebAuthnCeremony) {
+		t.Errorf("reused registration ceremony: got %v, want ErrInvalidWebAuthnCeremony", err)
+	}
+
+	// A login ceremony can only be finished once, even with a fresh assertion
+	login, err := service.BeginLogin(user.Email)
+	if err != nil {
+		t.Fatalf("BeginLogin error: %v", err)
+	}
+	if _, err := service.FinishLogin(login.CeremonyToken, authenticator.assert(requestChallenge(t, login), nil)); err != nil {
+		t.Fatalf("FinishLogin error: %v", err)
+	}
+	_, err = service.FinishLogin(login.CeremonyToken, authenticator.assert(requestChallenge(t, login), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnCeremony) {
+		t.Errorf("reused login ceremony: got %v, want ErrInvalidWebAuthnCeremony", err)
+	}
+
+	// An assertion over an old challenge doesn't satisfy a new ceremony
+	fresh, err := service.BeginLogin(user.Email)
+	if err != nil {
+		t.Fatalf("BeginLogin error: %v", err)
+	}
+	_, err = service.FinishLogin(fresh.CeremonyToken, authenticator.assert(requestChallenge(t, login), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("old challenge: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+}
+
+func TestWebAuthnRejectsMissingUserPresence(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+
+	absent := newSoftAuthenticator(t, coseAlgES256, testRPID, testOrigin)
+	absent.flags = 0
+	ceremony, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration error: %v", err)
+	}
+	_, err = service.FinishRegistration(user.ID, ceremony.CeremonyToken, absent.register(creationChallenge(t, ceremony)), "")
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("registration: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+
+	authenticator := registerAuthenticator(t, service, user, coseAlgES256)
+	// Verification without presence still isn't enough
+	authenticator.flags = authDataFlagUserVerified
+	login, err := service.BeginLogin(user.Email)
+	if err != nil {
+		t.Fatalf("BeginLogin error: %v", err)
+	}
+	_, err = service.FinishLogin(login.CeremonyToken, authenticator.assert(requestChallenge(t, login), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("login: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+
+	factor, err := service.BeginSecondFactor(user.ID)
+	if err != nil {
+		t.Fatalf("BeginSecondFactor error: %v", err)
+	}
+	err = service.VerifySecondFactor(user.ID, factor.CeremonyToken, authenticator.assert(requestChallenge(t, factor), nil))
+	if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("second factor: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+}
+
+func TestWebAuthnRejectsSignCountGoingBackwards(t *testing.T) {
+	service, credentialRepo, user := newTestWebAuthnService(t)
+	authenticator := registerAuthenticator(t, service, user, coseAlgES256)
+	authenticator.signCount = 10
+
+	login := func() error {
+		ceremony, err := service.BeginLogin(user.Email)
+		if err != nil {
+			t.Fatalf("BeginLogin error: %v", err)
+		}
+		_, err = service.FinishLogin(ceremony.CeremonyToken, authenticator.assert(requestChallenge(t, ceremony), nil))
+		return err
+	}
+
+	if err := login(); err != nil {
+		t.Fatalf("login error: %v", err)
+	}
+
+	// A clone replaying an older counter, or the same counter again, is refused
+	for _, signCount := range []uint32{4, 10} {
+		authenticator.signCount = signCount
+		if err := login(); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+			t.Errorf("sign count %d: got %v, want ErrInvalidWebAuthnResponse", signCount+1, err)
+		}
+	}
+	stored, _ := credentialRepo.FindByUserID(user.ID)
+	if stored[0].SignCount != 11 {
+		t.Errorf("stored sign count %d, want 11", stored[0].SignCount)
+	}
+
+	authenticator.signCount = 11
+	if err := login(); err != nil {
+		t.Errorf("increasing sign count rejected: %v", err)
+	}
+}
+
+func TestWebAuthnAcceptsAuthenticatorsWithoutCounters(t *testing.T) {
+	service, _, user := newTestWebAuthnService(t)
+	authenticator := registerAuthenticator(t, service, user, coseAlgEdDSA)
+
+	for range 2 {
+		ceremony, err := service.BeginLogin(user.Email)
+		if err != nil {
+			t.Fatalf("BeginLogin error: %v", err)
+		}
+		// Authenticators without counters always report zero
+		response := authenticator.assert(requestChallenge(t, ceremony), nil)
+		authenticator.signCount = 0
+		authData := authenticator.authenticatorData(false)
+		response.Response.AuthenticatorData = authData
+		response.Response.Signature = authenticator.sign(authData, response.Response.ClientDataJSON)
+
+		if _, err := service.FinishLogin(ceremony.CeremonyToken, response); err != nil {
+			t.Fatalf("FinishLogin error: %v", err)
+		}
+	}
+}
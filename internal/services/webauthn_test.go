@@ -0,0 +1,287 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// softAuthenticator is an in-memory WebAuthn authenticator producing the responses a browser
+// would return from navigator.credentials.create and navigator.credentials.get.
+// Its fields can be changed between ceremonies to produce invalid responses.
+type softAuthenticator struct {
+	signer       crypto.Signer
+	credentialID []byte
+	aaguid       []byte
+	rpID         string
+	origin       string
+	flags        byte
+	signCount    uint32
+}
+
+// newSoftAuthenticator creates an authenticator with a fresh ES256 or EdDSA key that
+// reports user presence and verification
+func newSoftAuthenticator(t *testing.T, alg int, rpID, origin string) *softAuthenticator {
+	t.Helper()
+
+	var signer crypto.Signer
+	var err error
+	switch alg {
+	case coseAlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case coseAlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("generate credential ID: %v", err)
+	}
+
+	return &softAuthenticator{
+		signer:       signer,
+		credentialID: credentialID,
+		aaguid:       []byte{0xad, 0xce, 0x00, 0x02, 0x35, 0xbc, 0xc6, 0x0a, 0x64, 0x8b, 0x0b, 0x25, 0xf1, 0xf0, 0x55, 0x03},
+		rpID:         rpID,
+		origin:       origin,
+		flags:        authDataFlagUserPresent | authDataFlagUserVerified,
+	}
+}
+
+// coseKey encodes the public key as a COSE_Key
+func (a *softAuthenticator) coseKey() []byte {
+	switch public := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		return encodeCBOR(cborMap{
+			{1, 2},            // kty: EC2
+			{3, coseAlgES256}, // alg
+			{-1, 1},           // crv: P-256
+			{-2, public.X.FillBytes(make([]byte, 32))},
+			{-3, public.Y.FillBytes(make([]byte, 32))},
+		})
+	case ed25519.PublicKey:
+		return encodeCBOR(cborMap{
+			{1, 1},            // kty: OKP
+			{3, coseAlgEdDSA}, // alg
+			{-1, 6},           // crv: Ed25519
+			{-2, []byte(public)},
+		})
+	}
+	panic("unsupported key")
+}
+
+// authenticatorData builds authenticator data, with attested credential data for registrations
+func (a *softAuthenticator) authenticatorData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+
+	flags := a.flags
+	if attested {
+		flags |= authDataFlagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if attested {
+		data = append(data, a.aaguid...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+// clientDataJSON builds the client data the browser would sign over
+func (a *softAuthenticator) clientDataJSON(ceremonyType, challenge string) []byte {
+	raw, _ := json.Marshal(collectedClientData{Type: ceremonyType, Challenge: challenge, Origin: a.origin})
+	return raw
+}
+
+// sign signs authenticatorData || SHA-256(clientDataJSON) like an authenticator does
+func (a *softAuthenticator) sign(authData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	var signature []byte
+	var err error
+	switch signer := a.signer.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(signed)
+		signature, err = ecdsa.SignASN1(rand.Reader, signer, digest[:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(signer, signed)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+// register answers a registration ceremony with attestation "none"
+func (a *softAuthenticator) register(challenge string) *RegistrationResponse {
+	attestationObject := encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", a.authenticatorData(true)},
+	})
+
+	response := &RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+	}
+	response.Response.ClientDataJSON = a.clientDataJSON("webauthn.create", challenge)
+	response.Response.AttestationObject = attestationObject
+	response.Response.Transports = []string{"internal", "hybrid"}
+	return response
+}
+
+// assert answers an authentication ceremony, increasing the sign count first like real
+// authenticators with counters do
+func (a *softAuthenticator) assert(challenge string, userHandle []byte) *AssertionResponse {
+	a.signCount++
+	authData := a.authenticatorData(false)
+	clientDataJSON := a.clientDataJSON("webauthn.get", challenge)
+
+	response := &AssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+	}
+	response.Response.ClientDataJSON = clientDataJSON
+	response.Response.AuthenticatorData = authData
+	response.Response.Signature = a.sign(authData, clientDataJSON)
+	response.Response.UserHandle = userHandle
+	return response
+}
+
+func TestParseAttestationObject(t *testing.T) {
+	for _, alg := range []int{coseAlgES256, coseAlgEdDSA} {
+		authenticator := newSoftAuthenticator(t, alg, "example.com", "https://example.com")
+		response := authenticator.register("challenge")
+
+		data, err := parseAttestationObject(response.Response.AttestationObject)
+		if err != nil {
+			t.Fatalf("alg %d: parseAttestationObject error: %v", alg, err)
+		}
+		if err := verifyRPIDHash(data, "example.com"); err != nil {
+			t.Errorf("alg %d: verifyRPIDHash error: %v", alg, err)
+		}
+		if !data.userPresent() || !data.userVerified() {
+			t.Errorf("alg %d: flags %#x, want UP and UV", alg, data.flags)
+		}
+		if string(data.credentialID) != string(authenticator.credentialID) {
+			t.Errorf("alg %d: credential ID mismatch", alg)
+		}
+		if got := formatAAGUID(data.aaguid); got != "adce0002-35bc-c60a-648b-0b25f1f05503" {
+			t.Errorf("alg %d: AAGUID %s", alg, got)
+		}
+
+		_, parsedAlg, err := parseCOSEKey(data.credentialKey)
+		if err != nil {
+			t.Fatalf("alg %d: parseCOSEKey error: %v", alg, err)
+		}
+		if parsedAlg != alg {
+			t.Errorf("parseCOSEKey alg = %d, want %d", parsedAlg, alg)
+		}
+	}
+}
+
+func TestParseAuthenticatorDataRejectsMalformedData(t *testing.T) {
+	authenticator := newSoftAuthenticator(t, coseAlgES256, "example.com", "https://example.com")
+	valid := authenticator.authenticatorData(true)
+
+	tests := map[string][]byte{
+		"too short":          valid[:36],
+		"truncated key":      valid[:len(valid)-3],
+		"trailing data":      append(append([]byte(nil), valid...), 0x00),
+		"truncated ID":       valid[:37+18+4],
+		"missing extensions": append(append([]byte(nil), valid[:32]...), append([]byte{valid[32] | authDataFlagExtensionData}, valid[33:]...)...),
+	}
+	for name, raw := range tests {
+		if _, err := parseAuthenticatorData(raw); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+			t.Errorf("%s: got %v, want ErrInvalidWebAuthnResponse", name, err)
+		}
+	}
+}
+
+func TestParseClientData(t *testing.T) {
+	authenticator := newSoftAuthenticator(t, coseAlgES256, "example.com", "https://example.com")
+	origins := []string{"https://example.com"}
+
+	if err := parseClientData(authenticator.clientDataJSON("webauthn.get", "abc"), "webauthn.get", "abc", origins); err != nil {
+		t.Fatalf("valid client data rejected: %v", err)
+	}
+
+	tests := map[string]struct {
+		raw       []byte
+		challenge string
+	}{
+		"wrong type":      {authenticator.clientDataJSON("webauthn.create", "abc"), "abc"},
+		"wrong challenge": {authenticator.clientDataJSON("webauthn.get", "abd"), "abc"},
+		"malformed":       {[]byte("{"), "abc"},
+	}
+	for name, tt := range tests {
+		if err := parseClientData(tt.raw, "webauthn.get", tt.challenge, origins); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+			t.Errorf("%s: got %v, want ErrInvalidWebAuthnResponse", name, err)
+		}
+	}
+
+	authenticator.origin = "https://evil.example"
+	if err := parseClientData(authenticator.clientDataJSON("webauthn.get", "abc"), "webauthn.get", "abc", origins); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+		t.Errorf("wrong origin: got %v, want ErrInvalidWebAuthnResponse", err)
+	}
+}
+
+func TestVerifyAssertionSignature(t *testing.T) {
+	for _, alg := range []int{coseAlgES256, coseAlgEdDSA} {
+		authenticator := newSoftAuthenticator(t, alg, "example.com", "https://example.com")
+		response := authenticator.assert("challenge", nil)
+		authData := response.Response.AuthenticatorData
+		clientDataJSON := response.Response.ClientDataJSON
+
+		if err := verifyAssertionSignature(authenticator.coseKey(), authData, clientDataJSON, response.Response.Signature); err != nil {
+			t.Errorf("alg %d: valid signature rejected: %v", alg, err)
+		}
+
+		tampered := append([]byte(nil), clientDataJSON...)
+		tampered[len(tampered)-2] ^= 0x01
+		if err := verifyAssertionSignature(authenticator.coseKey(), authData, tampered, response.Response.Signature); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+			t.Errorf("alg %d: tampered client data: got %v, want ErrInvalidWebAuthnResponse", alg, err)
+		}
+
+		other := newSoftAuthenticator(t, alg, "example.com", "https://example.com")
+		if err := verifyAssertionSignature(other.coseKey(), authData, clientDataJSON, response.Response.Signature); !errors.Is(err, ErrInvalidWebAuthnResponse) {
+			t.Errorf("alg %d: other key: got %v, want ErrInvalidWebAuthnResponse", alg, err)
+		}
+	}
+}
+
+func TestParseCOSEKeyRejectsInvalidKeys(t *testing.T) {
+	tests := map[string][]byte{
+		"not a map":       encodeCBOR([]any{1, 2}),
+		"unsupported alg": encodeCBOR(cborMap{{1, 2}, {3, -35}, {-1, 2}}),
+		"short EC2 point": encodeCBOR(cborMap{{1, 2}, {3, coseAlgES256}, {-1, 1}, {-2, make([]byte, 31)}, {-3, make([]byte, 32)}}),
+		"point off curve": encodeCBOR(cborMap{{1, 2}, {3, coseAlgES256}, {-1, 1}, {-2, make([]byte, 32)}, {-3, append(make([]byte, 31), 1)}}),
+		"wrong OKP curve": encodeCBOR(cborMap{{1, 1}, {3, coseAlgEdDSA}, {-1, 4}, {-2, make([]byte, 32)}}),
+		"short RSA key":   encodeCBOR(cborMap{{1, 3}, {3, coseAlgRS256}, {-1, make([]byte, 128)}, {-2, []byte{1, 0, 1}}}),
+	}
+	for name, raw := range tests {
+		if _, _, err := parseCOSEKey(raw); err == nil {
+			t.Errorf("%s: parseCOSEKey succeeded, want an error", name)
+		}
+	}
+}